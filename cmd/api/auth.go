@@ -0,0 +1,364 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/jwt"
+)
+
+// clientIDContextKey holds the ID of the client a request authenticated as
+const clientIDContextKey = contextKey("client_id")
+
+// claimsContextKey holds the verified claims of a request's bearer token in jwt auth mode
+const claimsContextKey = contextKey("jwt_claims")
+
+// identityContextKey holds the *requestIdentity that logRequest reads once the request is served
+const identityContextKey = contextKey("identity")
+
+// requestIdentity is who authenticate found a request to come from. logRequest runs outside
+// authentication, so it passes this holder down for authenticate to fill in.
+type requestIdentity struct {
+	clientID string
+	claims   *jwt.Claims
+}
+
+// apiKeyCacheSize bounds the keys cached by apiKeyAuthenticator, and apiKeyMissCacheSize the
+// unknown keys. Misses are kept apart so that a flood of made-up keys only evicts other misses.
+const (
+	apiKeyCacheSize     = 10000
+	apiKeyMissCacheSize = 1000
+)
+
+// publicPaths are served without credentials so probes and scrapers need no API key
+var publicPaths = map[string]bool{
+	"/v1/healthcheck":  true,
+	"/v1/health/live":  true,
+	"/v1/health/ready": true,
+	"/metrics":         true,
+}
+
+// defaultRouteScopes maps each route to the scope a bearer token needs to call it in jwt auth
+// mode. Computing a sequence records a statistics hit, hence fizzbuzz:write. Routes that are
+// not listed accept any valid token.
+var defaultRouteScopes = map[string]string{
+	"/v1/fizzbuzz":           "fizzbuzz:write",
+	"/v1/fizzbuzz/batch":     "fizzbuzz:write",
+	"/v1/statistics":         "statistics:read",
+	"/v1/statistics/top":     "statistics:read",
+	"/v1/statistics/summary": "statistics:read",
+	"/v1/statistics/clients": "statistics:read",
+}
+
+// parseRouteScopes applies comma-separated route=scope overrides to defaultRouteScopes.
+// An empty scope lets any valid token call the route.
+func parseRouteScopes(overrides string) (map[string]string, error) {
+	scopes := maps.Clone(defaultRouteScopes)
+
+	for _, pair := range strings.Split(overrides, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		route, scope, found := strings.Cut(pair, "=")
+		route, scope = strings.TrimSpace(route), strings.TrimSpace(scope)
+		if !found || !strings.HasPrefix(route, "/") || strings.ContainsAny(scope, " ,") {
+			return nil, fmt.Errorf("invalid route scope %q (want /route=scope)", pair)
+		}
+
+		if scope == "" {
+			delete(scopes, route)
+		} else {
+			scopes[route] = scope
+		}
+	}
+	return scopes, nil
+}
+
+// jwtAuthenticator verifies bearer tokens and the scopes they grant
+type jwtAuthenticator struct {
+	validator *jwt.Validator
+	// scopes maps routes to the scope they require
+	scopes map[string]string
+}
+
+// newJWTAuthenticator creates an authenticator checking tokens with validator and routes against scopes
+func newJWTAuthenticator(validator *jwt.Validator, scopes map[string]string) *jwtAuthenticator {
+	return &jwtAuthenticator{validator: validator, scopes: scopes}
+}
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// getClaims returns the verified claims of the request's bearer token, or nil without one
+func getClaims(r *http.Request) *jwt.Claims {
+	claims, _ := r.Context().Value(claimsContextKey).(*jwt.Claims)
+	return claims
+}
+
+// apiKeyAuthenticator resolves API keys to the clients they were issued to. Lookups, including
+// of unknown keys, are cached for ttl so a revocation takes up to ttl to apply.
+type apiKeyAuthenticator struct {
+	repository data.APIKeyRepository
+	header     string
+	ttl        time.Duration
+
+	mu     sync.Mutex
+	keys   *apiKeyCache // Hashes that matched a key, revoked or not
+	misses *apiKeyCache // Hashes that matched no key
+}
+
+// newAPIKeyAuthenticator creates an authenticator reading keys from header
+func newAPIKeyAuthenticator(repository data.APIKeyRepository, header string, ttl time.Duration) *apiKeyAuthenticator {
+	return &apiKeyAuthenticator{
+		repository: repository,
+		header:     header,
+		ttl:        ttl,
+		keys:       newAPIKeyCache(apiKeyCacheSize),
+		misses:     newAPIKeyCache(apiKeyMissCacheSize),
+	}
+}
+
+// apiKeyCache holds up to size lookups by key hash, evicting the least recently used when full.
+// It is not safe for concurrent use.
+type apiKeyCache struct {
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // Of *cachedAPIKey, most recently used first
+}
+
+// cachedAPIKey is a cached lookup; key is nil for a hash that matched no key
+type cachedAPIKey struct {
+	hash    string
+	key     *data.APIKey
+	expires time.Time
+}
+
+func newAPIKeyCache(size int) *apiKeyCache {
+	return &apiKeyCache{size: size, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+// get returns the lookup cached for hash unless it expired by now, in which case it is dropped
+func (c *apiKeyCache) get(hash string, now time.Time) (*cachedAPIKey, bool) {
+	element, exists := c.entries[hash]
+	if !exists {
+		return nil, false
+	}
+
+	cached := element.Value.(*cachedAPIKey)
+	if !now.Before(cached.expires) {
+		c.order.Remove(element)
+		delete(c.entries, hash)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return cached, true
+}
+
+// put caches a lookup, evicting the least recently used one if the cache is full
+func (c *apiKeyCache) put(cached *cachedAPIKey) {
+	if element, exists := c.entries[cached.hash]; exists {
+		element.Value = cached
+		c.order.MoveToFront(element)
+		return
+	}
+
+	if c.order.Len() >= c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedAPIKey).hash)
+	}
+	c.entries[cached.hash] = c.order.PushFront(cached)
+}
+
+// remove drops the lookup cached for hash, if any
+func (c *apiKeyCache) remove(hash string) {
+	if element, exists := c.entries[hash]; exists {
+		c.order.Remove(element)
+		delete(c.entries, hash)
+	}
+}
+
+// lookup returns the key matching plaintext, revoked or not, or data.ErrAPIKeyNotFound
+func (a *apiKeyAuthenticator) lookup(ctx context.Context, plaintext string) (*data.APIKey, error) {
+	hash := data.HashAPIKey(plaintext)
+	now := time.Now()
+
+	a.mu.Lock()
+	if cached, ok := a.keys.get(hash, now); ok {
+		a.mu.Unlock()
+		return cached.key, nil
+	}
+	_, missed := a.misses.get(hash, now)
+	a.mu.Unlock()
+	if missed {
+		return nil, data.ErrAPIKeyNotFound
+	}
+
+	key, err := a.repository.GetByHash(ctx, hash)
+	if err != nil && !errors.Is(err, data.ErrAPIKeyNotFound) {
+		return nil, err
+	}
+
+	if a.ttl > 0 {
+		cached := &cachedAPIKey{hash: hash, key: key, expires: now.Add(a.ttl)}
+		a.mu.Lock()
+		if key != nil {
+			a.misses.remove(hash)
+			a.keys.put(cached)
+		} else {
+			a.misses.put(cached)
+		}
+		a.mu.Unlock()
+	}
+	return key, err
+}
+
+// getClientID returns the ID of the client the request authenticated as, or "" if it did not
+func getClientID(r *http.Request) string {
+	clientID, _ := r.Context().Value(clientIDContextKey).(string)
+	return clientID
+}
+
+// withClientID appends the ID of the client r authenticated as to the log attributes attrs
+func withClientID(r *http.Request, attrs ...any) []any {
+	if clientID := getClientID(r); clientID != "" {
+		attrs = append(attrs, "client_id", clientID)
+	}
+	return attrs
+}
+
+// identify records the client a request authenticated as for logRequest
+func identify(r *http.Request, clientID string, claims *jwt.Claims) {
+	if identity, ok := r.Context().Value(identityContextKey).(*requestIdentity); ok {
+		identity.clientID = clientID
+		identity.claims = claims
+	}
+}
+
+// authRateLimit limits every client IP's requests that carry credentials to check, ahead of
+// authenticate, so a flood of made-up API keys or tokens from one address cannot hammer the key
+// store. Authenticated requests are then charged to their client's own bucket by rateLimit.
+func (app *application) authRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.authLimiter == nil || (app.apiKeys == nil && app.tokens == nil) || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		charge := &rateLimitCharge{limiters: app.authLimiter, target: app.authLimiter.policy.target(r, "")}
+		if retryAfter, ok := app.takeRateLimit(r, charge, 1); !ok {
+			app.rateLimitRejected(w, r, charge, 1, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate middleware requires a valid API key, or a bearer token in jwt auth mode, on every
+// route but publicPaths and stores the caller's client ID in the request context. Rejected
+// requests are logged and counted by reason here, and like any other by logRequest.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if (app.apiKeys == nil && app.tokens == nil) || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if app.tokens != nil {
+			app.authenticateBearer(w, r, next)
+			return
+		}
+
+		plaintext := r.Header.Get(app.apiKeys.header)
+		if plaintext == "" {
+			app.authenticationFailed(r, "missing")
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		key, err := app.apiKeys.lookup(r.Context(), plaintext)
+		switch {
+		case errors.Is(err, data.ErrAPIKeyNotFound):
+			app.authenticationFailed(r, "invalid")
+			app.invalidAPIKeyResponse(w, r)
+			return
+		case err != nil:
+			app.serverErrorResponse(w, r, err)
+			return
+		case key.Revoked():
+			app.authenticationFailed(r, "revoked")
+			app.revokedAPIKeyResponse(w, r)
+			return
+		}
+
+		identify(r, key.ClientID, nil)
+		ctx := context.WithValue(r.Context(), clientIDContextKey, key.ClientID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateBearer verifies the request's bearer token and the scope its route requires, then
+// serves it with the token's claims, and its subject as client ID, in the request context
+func (app *application) authenticateBearer(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	token, ok := bearerToken(r)
+	if !ok {
+		app.authenticationFailed(r, "missing")
+		app.bearerTokenRequiredResponse(w, r)
+		return
+	}
+
+	claims, err := app.tokens.validator.Validate(token)
+	if err != nil {
+		reason := "invalid"
+		if errors.Is(err, jwt.ErrExpired) {
+			reason = "expired"
+		}
+		app.authenticationFailed(r, reason)
+		app.invalidBearerTokenResponse(w, r, err)
+		return
+	}
+
+	if scope := app.tokens.scopes[r.URL.Path]; scope != "" && !claims.HasScope(scope) {
+		app.authenticationFailed(r, "insufficient_scope")
+		app.insufficientScopeResponse(w, r, scope)
+		return
+	}
+
+	identify(r, claims.Subject, claims)
+	ctx := context.WithValue(r.Context(), claimsContextKey, claims)
+	if claims.Subject != "" {
+		ctx = context.WithValue(ctx, clientIDContextKey, claims.Subject)
+	}
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// authenticationFailed logs and counts a request rejected for reason (missing, invalid, revoked,
+// expired or insufficient_scope)
+func (app *application) authenticationFailed(r *http.Request, reason string) {
+	app.logger.WarnWithContext(r.Context(), "request rejected by authentication",
+		"reason", reason,
+		"method", r.Method,
+		"uri", r.URL.RequestURI(),
+		"client_ip", getClientIP(r))
+
+	if app.metrics != nil {
+		app.metrics.authFailures.Inc(reason)
+	}
+}
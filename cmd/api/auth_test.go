@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/jsonlog"
+	"fizzbuzz/internal/jwt"
+)
+
+// issueTestKey issues a key for clientID and returns it with its ID
+func issueTestKey(t *testing.T, repository data.APIKeyRepository, clientID string) (string, int64) {
+	t.Helper()
+
+	plaintext, key, err := data.IssueAPIKey(context.Background(), repository, clientID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return plaintext, key.ID
+}
+
+func TestAuthenticateMiddleware(t *testing.T) {
+	repository := data.NewMemoryAPIKeyRepository()
+	active, _ := issueTestKey(t, repository, "acme")
+	revoked, revokedID := issueTestKey(t, repository, "globex")
+	if err := repository.Revoke(context.Background(), revokedID); err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(t)
+	app.apiKeys = newAPIKeyAuthenticator(repository, "X-API-Key", 0)
+	handler := app.routes()
+
+	tests := []struct {
+		name          string
+		path          string
+		apiKey        string
+		wantStatus    int
+		wantChallenge bool
+	}{
+		{"missing key", "/v1/statistics", "", http.StatusUnauthorized, true},
+		{"unknown key", "/v1/statistics", "fb_guess", http.StatusUnauthorized, true},
+		{"revoked key", "/v1/statistics", revoked, http.StatusForbidden, false},
+		{"valid key", "/v1/statistics", active, http.StatusOK, false},
+		{"public route without key", "/v1/health/live", "", http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if got := rr.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("expected WWW-Authenticate present %v, got %q", tt.wantChallenge, rr.Header().Get("WWW-Authenticate"))
+			}
+			if tt.wantStatus >= 400 && !strings.Contains(rr.Body.String(), `"error"`) {
+				t.Errorf("expected an error envelope, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthenticateAttributesRequests(t *testing.T) {
+	repository := data.NewMemoryAPIKeyRepository()
+	plaintext, _ := issueTestKey(t, repository, "acme")
+
+	var logs bytes.Buffer
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&logs, jsonlog.LevelInfo, "production")
+	app.apiKeys = newAPIKeyAuthenticator(repository, "X-API-Key", 0)
+
+	var got string
+	handler := app.logRequest(app.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = getClientID(r)
+		app.serverErrorResponse(w, r, errors.New("boom"))
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/statistics", nil)
+	req.Header.Set("X-API-Key", plaintext)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "acme" {
+		t.Errorf("expected client ID acme in context, got %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a server error and a request log line, got %s", logs.String())
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"client_id":"acme"`) {
+			t.Errorf("expected the log line to carry the client ID, got %s", line)
+		}
+	}
+}
+
+// countingAPIKeyRepository counts the key lookups that reach the repository
+type countingAPIKeyRepository struct {
+	data.APIKeyRepository
+	lookups int
+}
+
+func (c *countingAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*data.APIKey, error) {
+	c.lookups++
+	return c.APIKeyRepository.GetByHash(ctx, hash)
+}
+
+func TestAuthenticateInsideLoggingAndMetrics(t *testing.T) {
+	var logs bytes.Buffer
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&logs, jsonlog.LevelInfo, "production")
+	app.apiKeys = newAPIKeyAuthenticator(data.NewMemoryAPIKeyRepository(), "X-API-Key", 0)
+	app.metrics = newAPIMetrics(app)
+	handler := app.routes()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/statistics", nil)
+	req.Header.Set("X-API-Key", "fb_guess")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.Contains(logs.String(), `"msg":"HTTP request completed"`) || !strings.Contains(logs.String(), `"status":401`) {
+		t.Errorf("expected the rejected request in the request log, got %s", logs.String())
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if want := `fizzbuzz_http_requests_total{method="GET",route="/v1/statistics",status="401"} 1`; !strings.Contains(rr.Body.String(), want) {
+		t.Errorf("expected metrics to contain %q\n%s", want, rr.Body.String())
+	}
+}
+
+func TestAuthRateLimit(t *testing.T) {
+	repository := &countingAPIKeyRepository{APIKeyRepository: data.NewMemoryAPIKeyRepository()}
+	active, _ := issueTestKey(t, repository, "acme")
+
+	app := newTestApplication(t)
+	app.apiKeys = newAPIKeyAuthenticator(repository, "X-API-Key", 0)
+	app.authLimiter = newRateLimiterMap(0.001, 2)
+	app.config.limiter.enabled = true
+	app.rateLimiter = newRateLimiterMap(0.001, 5)
+	handler := app.routes()
+
+	request := func(key, ip string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/statistics", nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Guesses from one address are cut off before they reach the key store
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+		if got := request("fb_guess", "192.0.2.1"); got != want {
+			t.Errorf("request %d: expected status %d, got %d", i+1, want, got)
+		}
+	}
+	if repository.lookups != 2 {
+		t.Errorf("expected 2 lookups to reach the repository, got %d", repository.lookups)
+	}
+
+	// Another address still authenticates, and is charged to its own bucket as well
+	if got := request(active, "192.0.2.2"); got != http.StatusOK {
+		t.Errorf("expected status %d from another address, got %d", http.StatusOK, got)
+	}
+	if got := request("", "192.0.2.3"); got != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a key, got %d", http.StatusUnauthorized, got)
+	}
+	if got := request(active, "192.0.2.1"); got != http.StatusTooManyRequests {
+		t.Errorf("expected the exhausted address to stay limited, got %d", got)
+	}
+}
+
+func TestAPIKeyAuthenticatorCache(t *testing.T) {
+	ctx := context.Background()
+	repository := data.NewMemoryAPIKeyRepository()
+	plaintext, id := issueTestKey(t, repository, "acme")
+
+	cached := newAPIKeyAuthenticator(repository, "X-API-Key", time.Hour)
+	uncached := newAPIKeyAuthenticator(repository, "X-API-Key", 0)
+	for _, auth := range []*apiKeyAuthenticator{cached, uncached} {
+		if _, err := auth.lookup(ctx, plaintext); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repository.Revoke(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cached lookup keeps the key active until the TTL passes
+	if key, err := cached.lookup(ctx, plaintext); err != nil || key.Revoked() {
+		t.Errorf("expected the cached key to still be active, got %+v, %v", key, err)
+	}
+	if key, err := uncached.lookup(ctx, plaintext); err != nil || !key.Revoked() {
+		t.Errorf("expected the revocation to apply without a cache, got %+v, %v", key, err)
+	}
+}
+
+func TestAPIKeyAuthenticatorMisses(t *testing.T) {
+	ctx := context.Background()
+	repository := &countingAPIKeyRepository{APIKeyRepository: data.NewMemoryAPIKeyRepository()}
+	plaintext, _ := issueTestKey(t, repository, "acme")
+	auth := newAPIKeyAuthenticator(repository, "X-API-Key", time.Hour)
+
+	if _, err := auth.lookup(ctx, plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	// A flood of unknown keys fills the miss cache but leaves the known key cached
+	for i := 0; i < apiKeyMissCacheSize*2; i++ {
+		if _, err := auth.lookup(ctx, fmt.Sprintf("fb_guess_%d", i)); !errors.Is(err, data.ErrAPIKeyNotFound) {
+			t.Fatalf("expected ErrAPIKeyNotFound, got %v", err)
+		}
+	}
+	if auth.misses.order.Len() != apiKeyMissCacheSize {
+		t.Errorf("expected %d cached misses, got %d", apiKeyMissCacheSize, auth.misses.order.Len())
+	}
+
+	lookups := repository.lookups
+	if key, err := auth.lookup(ctx, plaintext); err != nil || key.ClientID != "acme" {
+		t.Fatalf("expected the key of acme, got %+v, %v", key, err)
+	}
+	if repository.lookups != lookups {
+		t.Error("expected the known key to be served from the cache")
+	}
+
+	// The most recent misses are still cached, the oldest were evicted
+	auth.lookup(ctx, fmt.Sprintf("fb_guess_%d", apiKeyMissCacheSize*2-1))
+	auth.lookup(ctx, "fb_guess_0")
+	if repository.lookups != lookups+1 {
+		t.Errorf("expected only the evicted miss to reach the repository, got %d lookups", repository.lookups-lookups)
+	}
+}
+
+func TestAPIKeyCache(t *testing.T) {
+	now := time.Now()
+	cache := newAPIKeyCache(2)
+
+	cache.put(&cachedAPIKey{hash: "a", expires: now.Add(time.Minute)})
+	cache.put(&cachedAPIKey{hash: "b", expires: now.Add(time.Minute)})
+	cache.get("a", now) // b is now the least recently used
+	cache.put(&cachedAPIKey{hash: "c", expires: now.Add(time.Minute)})
+
+	for hash, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.get(hash, now); ok != want {
+			t.Errorf("expected %q cached %v, got %v", hash, want, ok)
+		}
+	}
+
+	if _, ok := cache.get("a", now.Add(time.Minute)); ok {
+		t.Error("expected an expired lookup to be dropped")
+	}
+	if cache.order.Len() != 1 || len(cache.entries) != 1 {
+		t.Errorf("expected one entry left, got %d", cache.order.Len())
+	}
+}
+
+func TestClientKeyExtractor(t *testing.T) {
+	policy, err := loadRateLimitPolicy(writePolicy(t, `{
+		"key": "client",
+		"tiers": {"default": {"rps": 1, "burst": 2}, "pro": {"rps": 10, "burst": 20}},
+		"clients": {"acme": "pro"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		clientID   string
+		wantClient string
+		wantTier   string
+	}{
+		{"anonymous", "", "ip:192.0.2.1", "default"},
+		{"listed client", "acme", "client:acme", "pro"},
+		{"unlisted client", "globex", "client:globex", "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/fizzbuzz", nil)
+			if tt.clientID != "" {
+				req = req.WithContext(context.WithValue(req.Context(), clientIDContextKey, tt.clientID))
+			}
+
+			target := policy.target(req, "/v1/fizzbuzz")
+			if target.client != tt.wantClient || target.tier != tt.wantTier {
+				t.Errorf("unexpected target: %+v", target)
+			}
+		})
+	}
+}
+
+// testJWTSecret signs the HS256 tokens of the bearer tests
+const testJWTSecret = "test-secret-of-at-least-32-bytes!"
+
+// signTestToken returns an HS256 token over claims
+func signTestToken(t *testing.T, claims map[string]any) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(testJWTSecret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthenticateBearer(t *testing.T) {
+	var logs bytes.Buffer
+	app := newTestApplication(t)
+	app.logger = jsonlog.New(&logs, jsonlog.LevelInfo, "production")
+	app.tokens = newJWTAuthenticator(&jwt.Validator{
+		Keys:     jwt.NewSecretKeySet([]byte(testJWTSecret)),
+		Audience: "fizzbuzz",
+	}, defaultRouteScopes)
+	app.metrics = newAPIMetrics(app)
+	handler := app.routes()
+
+	token := func(scope string, exp time.Duration, aud string) string {
+		return signTestToken(t, map[string]any{
+			"sub":   "acme",
+			"iss":   "https://auth.example.com",
+			"aud":   aud,
+			"exp":   time.Now().Add(exp).Unix(),
+			"scope": scope,
+		})
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{"missing token", "/v1/statistics", "", http.StatusUnauthorized, `Bearer realm="fizzbuzz"`},
+		{"not a bearer token", "/v1/statistics", "Basic YWNtZTpzZWNyZXQ=", http.StatusUnauthorized, `Bearer realm="fizzbuzz"`},
+		{"malformed token", "/v1/statistics", "Bearer abc", http.StatusUnauthorized, `error="invalid_token", error_description="malformed token"`},
+		{"expired token", "/v1/statistics", "Bearer " + token("statistics:read", -time.Hour, "fizzbuzz"), http.StatusUnauthorized, `error_description="token is expired"`},
+		{"token without exp", "/v1/statistics", "Bearer " + signTestToken(t, map[string]any{"sub": "acme", "aud": "fizzbuzz", "scope": "statistics:read"}), http.StatusUnauthorized, `error_description="required claim is missing"`},
+		{"wrong audience", "/v1/statistics", "Bearer " + token("statistics:read", time.Hour, "billing"), http.StatusUnauthorized, `error="invalid_token"`},
+		{"missing scope", "/v1/statistics", "Bearer " + token("fizzbuzz:write", time.Hour, "fizzbuzz"), http.StatusForbidden, `error="insufficient_scope", scope="statistics:read"`},
+		{"granted scope", "/v1/statistics", "bearer " + token("statistics:read", time.Hour, "fizzbuzz"), http.StatusOK, ""},
+		{"route without scope", "/v1/ratelimit", "Bearer " + token("", time.Hour, "fizzbuzz"), http.StatusOK, ""},
+		{"public route without token", "/v1/health/live", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			challenge := rr.Header().Get("WWW-Authenticate")
+			if (tt.wantChallenge == "") != (challenge == "") || !strings.Contains(challenge, tt.wantChallenge) {
+				t.Errorf("expected WWW-Authenticate containing %q, got %q", tt.wantChallenge, challenge)
+			}
+		})
+	}
+
+	if !strings.Contains(logs.String(), `"client_id":"acme"`) || !strings.Contains(logs.String(), `"token_scopes":["statistics:read"]`) {
+		t.Errorf("expected the request log to carry the token's subject and scopes, got %s", logs.String())
+	}
+
+	// Only the token past its exp counts as expired; one without exp is invalid
+	if expired, invalid := app.metrics.authFailures.Value("expired"), app.metrics.authFailures.Value("invalid"); expired != 1 || invalid != 3 {
+		t.Errorf("expected 1 expired and 3 invalid failures, got %v and %v", expired, invalid)
+	}
+}
+
+func TestParseRouteScopes(t *testing.T) {
+	scopes, err := parseRouteScopes("/v1/ratelimit=ratelimit:read, /v1/statistics/summary=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scopes["/v1/ratelimit"] != "ratelimit:read" || scopes["/v1/fizzbuzz"] != "fizzbuzz:write" {
+		t.Errorf("unexpected scopes: %v", scopes)
+	}
+	if _, exists := scopes["/v1/statistics/summary"]; exists {
+		t.Errorf("expected an empty scope to remove the requirement, got %v", scopes)
+	}
+	if defaultRouteScopes["/v1/statistics/summary"] == "" {
+		t.Error("overrides must not change the defaults")
+	}
+
+	for _, invalid := range []string{"v1/fizzbuzz=fizzbuzz:write", "/v1/fizzbuzz", "/v1/fizzbuzz=a b"} {
+		if _, err := parseRouteScopes(invalid); err == nil {
+			t.Errorf("%q: expected an error", invalid)
+		}
+	}
+}
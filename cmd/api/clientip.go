@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIPContextKey holds the client IP resolved by the clientIP middleware
+const clientIPContextKey = contextKey("client_ip")
+
+// clientIPResolver finds the address of the client behind the reverse proxies we trust.
+// Forwarding headers are only believed when the connection comes from a trusted proxy, and the
+// forwarding chain is walked right to left, stopping at the first hop that is not trusted: every
+// entry to the left of it was written by a party we cannot vouch for.
+// A nil resolver trusts no proxies and always resolves to the connection's address.
+type clientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// newClientIPResolver parses a comma-separated list of trusted proxy CIDRs or single addresses
+func newClientIPResolver(list string) (*clientIPResolver, error) {
+	resolver := &clientIPResolver{}
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			addr = addr.Unmap()
+			resolver.trusted = append(resolver.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+
+	return resolver, nil
+}
+
+// isTrusted reports whether addr belongs to a trusted proxy
+func (res *clientIPResolver) isTrusted(addr netip.Addr) bool {
+	if res == nil {
+		return false
+	}
+	for _, prefix := range res.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the client IP of r. The RFC 7239 Forwarded header takes precedence over
+// X-Forwarded-For; X-Real-IP is used only when a trusted proxy sent neither.
+func (res *clientIPResolver) resolve(r *http.Request) string {
+	remote, ok := parseHostAddr(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !res.isTrusted(remote) {
+		return remote.String()
+	}
+
+	hops, present := forwardedFor(r.Header)
+	if !present {
+		hops, present = xForwardedFor(r.Header)
+	}
+	if !present {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return realIP.Unmap().String()
+		}
+		return remote.String()
+	}
+
+	// Walk from the proxy closest to us towards the client
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseHostAddr(hops[i])
+		if !ok {
+			break // Unknown or obfuscated hop: the last trusted address is as far as we can see
+		}
+		client = hop
+		if !res.isTrusted(hop) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedFor returns the for= values of the RFC 7239 Forwarded header, in order
+func forwardedFor(header http.Header) ([]string, bool) {
+	values := header.Values("Forwarded")
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	var hops []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			node := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					node = strings.Trim(val, `"`)
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+	return hops, true
+}
+
+// xForwardedFor returns the entries of the X-Forwarded-For header, in order
+func xForwardedFor(header http.Header) ([]string, bool) {
+	values := header.Values("X-Forwarded-For")
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	var hops []string
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			hops = append(hops, strings.TrimSpace(entry))
+		}
+	}
+	return hops, true
+}
+
+// parseHostAddr parses an address with or without a port, including bracketed IPv6
+// ("192.0.2.1", "192.0.2.1:80", "[2001:db8::1]:80", "2001:db8::1")
+func parseHostAddr(value string) (netip.Addr, bool) {
+	host := value
+	if h, _, err := net.SplitHostPort(value); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// getClientIP returns the client IP resolved by the clientIP middleware, or the connection's
+// address for requests that did not go through it
+func getClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip
+	}
+
+	// Fall back to RemoteAddr (remove port if present)
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// clientIP middleware resolves the client IP once per request and stores it in the request
+// context for rate limiting and logging
+func (app *application) clientIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := app.clientIPs.resolve(r)
+		ctx := context.WithValue(r.Context(), clientIPContextKey, ip)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewClientIPResolver(t *testing.T) {
+	resolver, err := newClientIPResolver(" 10.0.0.0/8, 192.0.2.7 ,2001:db8::/32,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resolver.trusted) != 3 {
+		t.Errorf("expected 3 trusted prefixes, got %v", resolver.trusted)
+	}
+
+	for _, list := range []string{"10.0.0.0/33", "proxy.internal", "10.0.0.1/8/8"} {
+		if _, err := newClientIPResolver(list); err == nil {
+			t.Errorf("expected an error for %q", list)
+		}
+	}
+}
+
+func TestClientIPResolverResolve(t *testing.T) {
+	resolver, err := newClientIPResolver("10.0.0.0/8,2001:db8:ffff::/48")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		resolver   *clientIPResolver
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"no proxies trusted", nil, "203.0.113.9:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.9"},
+		{"untrusted peer spoofing XFF", resolver, "203.0.113.9:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.9"},
+		{"untrusted peer spoofing X-Real-IP", resolver, "203.0.113.9:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "203.0.113.9"},
+		{"trusted proxy", resolver, "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entry left of the client", resolver, "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"all hops trusted", resolver, "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.3"}, "10.0.0.5"},
+		{"garbage hop stops the walk", resolver, "10.0.0.2:1234", map[string]string{"X-Forwarded-For": "198.51.100.1, nonsense, 10.0.0.3"}, "10.0.0.3"},
+		{"X-Real-IP from trusted proxy", resolver, "10.0.0.2:1234", map[string]string{"X-Real-IP": "198.51.100.1"}, "198.51.100.1"},
+		{"Forwarded", resolver, "10.0.0.2:1234", map[string]string{"Forwarded": `for=198.51.100.1;proto=https, for="10.0.0.3:8080"`}, "198.51.100.1"},
+		{"Forwarded IPv6", resolver, "[2001:db8:ffff::1]:443", map[string]string{"Forwarded": `For="[2001:db8:cafe::17]:4711"`}, "2001:db8:cafe::17"},
+		{"Forwarded wins over XFF", resolver, "10.0.0.2:1234", map[string]string{"Forwarded": "for=198.51.100.1", "X-Forwarded-For": "198.51.100.2"}, "198.51.100.1"},
+		{"Forwarded obfuscated hop", resolver, "10.0.0.2:1234", map[string]string{"Forwarded": "for=198.51.100.1, for=_hidden"}, "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			if got := tt.resolver.resolve(req); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	app := newTestApplication(t)
+	resolver, err := newClientIPResolver("192.0.2.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.clientIPs = resolver
+
+	var got string
+	handler := app.clientIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = getClientIP(r)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if got != "198.51.100.1" {
+		t.Errorf("expected the resolved IP in context, got %q", got)
+	}
+}
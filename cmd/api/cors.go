@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// corsExposedHeaders are the response headers browsers hide from scripts unless exposed
+const corsExposedHeaders = "X-Correlation-ID, ETag, Retry-After, WWW-Authenticate, " +
+	"RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, " +
+	"X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, X-RateLimit-Cost"
+
+// corsPolicy decides which browser origins may call the API. An origin is allowed when it
+// equals one of origins, matches one of wildcards, or when anyOrigin is set.
+// A nil policy disables CORS.
+type corsPolicy struct {
+	anyOrigin bool
+	origins   map[string]bool
+	wildcards []originWildcard
+	methods   string
+	headers   string
+	maxAge    time.Duration
+}
+
+// originWildcard matches the subdomains of an origin: "https://*.example.com" gives
+// prefix "https://" and suffix ".example.com"
+type originWildcard struct {
+	prefix string
+	suffix string
+}
+
+// newCORSPolicy parses comma-separated allowed origins, methods and headers. Origins are
+// "*", exact origins such as "https://dashboard.example.com", or a wildcard in place of the
+// leftmost labels such as "https://*.example.com". An empty origin list returns a nil policy.
+func newCORSPolicy(origins, methods, headers string, maxAge time.Duration) (*corsPolicy, error) {
+	if maxAge < 0 {
+		return nil, fmt.Errorf("invalid CORS max age %s: must not be negative", maxAge)
+	}
+	policy := &corsPolicy{origins: make(map[string]bool), maxAge: maxAge}
+
+	for _, origin := range splitList(origins) {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			policy.anyOrigin = true
+		case strings.Contains(origin, "*"):
+			prefix, suffix, ok := strings.Cut(origin, "://*.")
+			if !ok || strings.Contains(suffix, "*") || !validOrigin(prefix+"://"+suffix) {
+				return nil, fmt.Errorf("invalid CORS origin %q: a wildcard must replace the leftmost labels of the host, as in https://*.example.com", origin)
+			}
+			policy.wildcards = append(policy.wildcards, originWildcard{prefix: prefix + "://", suffix: "." + suffix})
+		default:
+			if !validOrigin(origin) {
+				return nil, fmt.Errorf("invalid CORS origin %q: expected scheme://host[:port]", origin)
+			}
+			policy.origins[origin] = true
+		}
+	}
+	if !policy.anyOrigin && len(policy.origins) == 0 && len(policy.wildcards) == 0 {
+		return nil, nil
+	}
+
+	methodList := splitList(methods)
+	for i, method := range methodList {
+		methodList[i] = strings.ToUpper(method)
+	}
+	policy.methods = strings.Join(methodList, ", ")
+	policy.headers = strings.Join(splitList(headers), ", ")
+	return policy, nil
+}
+
+// splitList splits a comma-separated list, dropping blank entries
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// validOrigin reports whether origin is a serialized origin: a scheme and host, an optional
+// port and nothing else
+func validOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Scheme != "" && u.Host != "" && u.User == nil && u.Path == "" && u.RawQuery == "" && u.Fragment == ""
+}
+
+// allows reports whether requests from origin may be read by the calling page
+func (p *corsPolicy) allows(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if !strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+			continue
+		}
+		// The wildcard stands for one or more labels, never for a port or a path
+		labels := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+		if labels != "" && !strings.ContainsAny(labels, ":/@") && !strings.HasPrefix(labels, ".") && !strings.HasSuffix(labels, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// cors middleware adds the CORS headers of allowed origins and answers preflight requests
+// itself, before authentication, rate limiting or the router's 405 for OPTIONS can reject them
+func (app *application) cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy := app.corsPolicy
+		if policy == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Responses differ by origin, so caches must not share them between origins
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		allowed := origin != "" && policy.allows(origin)
+
+		if allowed {
+			if policy.anyOrigin {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+		}
+
+		if !preflight || origin == "" {
+			if allowed {
+				w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflights are answered here whether or not the origin is allowed: without the
+		// Access-Control-Allow-Origin header the browser blocks the actual request itself
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Methods", policy.methods)
+			if policy.headers != "" {
+				w.Header().Set("Access-Control-Allow-Headers", policy.headers)
+			}
+			if policy.maxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.maxAge.Seconds())))
+			}
+		} else {
+			app.logger.DebugWithContext(r.Context(), "CORS preflight from disallowed origin", "origin", origin, "path", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
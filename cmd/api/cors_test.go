@@ -0,0 +1,138 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fizzbuzz/internal/jwt"
+)
+
+func TestNewCORSPolicy(t *testing.T) {
+	policy, err := newCORSPolicy(" https://dashboard.example.com, https://*.example.org:8443,", "get,post", "Content-Type, Authorization", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(policy.origins) != 1 || len(policy.wildcards) != 1 || policy.methods != "GET, POST" {
+		t.Errorf("unexpected policy: %+v", policy)
+	}
+
+	if policy, err := newCORSPolicy(" , ", "GET", "", 0); err != nil || policy != nil {
+		t.Errorf("expected no origins to disable CORS, got %+v, %v", policy, err)
+	}
+
+	for _, origins := range []string{"dashboard.example.com", "https://example.com/", "https://*example.com", "https://a.*.example.com", "*.example.com"} {
+		if _, err := newCORSPolicy(origins, "GET", "", 0); err == nil {
+			t.Errorf("expected an error for %q", origins)
+		}
+	}
+	if _, err := newCORSPolicy("*", "GET", "", -time.Second); err == nil {
+		t.Error("expected an error for a negative max age")
+	}
+}
+
+func TestCORSPolicyAllows(t *testing.T) {
+	policy, err := newCORSPolicy("https://dashboard.example.com,https://*.example.org,http://localhost:3000", "GET", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://dashboard.example.com", true},
+		{"https://Dashboard.Example.com", true},
+		{"http://dashboard.example.com", false},
+		{"https://dashboard.example.com:8443", false},
+		{"https://evil.example.com", false},
+		{"https://stats.example.org", true},
+		{"https://eu.stats.example.org", true},
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+		{"https://stats.example.org.evil.com", false},
+		{"https://stats.example.org:8443", false},
+		{"http://stats.example.org", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:3001", false},
+		{"null", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			if got := policy.allows(tt.origin); got != tt.want {
+				t.Errorf("expected allows(%q) = %v, got %v", tt.origin, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	policy, err := newCORSPolicy("https://*.example.com", "GET, POST", "Authorization, Content-Type", 10*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Preflights must get through even with an exhausted rate limit and bearer authentication
+	app := newTestApplication(t)
+	app.corsPolicy = policy
+	app.config.limiter.enabled = true
+	app.rateLimiter = newRateLimiterMap(0.001, 1)
+	app.tokens = newJWTAuthenticator(&jwt.Validator{Keys: jwt.NewSecretKeySet([]byte(testJWTSecret))}, defaultRouteScopes)
+	handler := app.routes()
+
+	token := signTestToken(t, map[string]any{"sub": "acme", "exp": time.Now().Add(time.Hour).Unix(), "scope": "fizzbuzz:write"})
+
+	tests := []struct {
+		name            string
+		method          string
+		origin          string
+		preflightMethod string
+		authorization   string
+		wantStatus      int
+		wantAllowOrigin string
+		wantMaxAge      string
+	}{
+		{"preflight", http.MethodOptions, "https://dashboard.example.com", http.MethodPost, "", http.StatusNoContent, "https://dashboard.example.com", "600"},
+		{"repeated preflight", http.MethodOptions, "https://dashboard.example.com", http.MethodGet, "", http.StatusNoContent, "https://dashboard.example.com", "600"},
+		{"preflight from disallowed origin", http.MethodOptions, "https://evil.com", http.MethodPost, "", http.StatusNoContent, "", ""},
+		{"actual request", http.MethodGet, "https://dashboard.example.com", "", "Bearer " + token, http.StatusOK, "https://dashboard.example.com", ""},
+		{"rejected request keeps CORS headers", http.MethodGet, "https://dashboard.example.com", "", "Bearer " + token, http.StatusTooManyRequests, "https://dashboard.example.com", ""},
+		{"unauthenticated request keeps CORS headers", http.MethodGet, "https://dashboard.example.com", "", "", http.StatusUnauthorized, "https://dashboard.example.com", ""},
+		{"request from disallowed origin", http.MethodGet, "https://evil.com", "", "", http.StatusUnauthorized, "", ""},
+		{"OPTIONS without preflight", http.MethodOptions, "https://dashboard.example.com", "", "", http.StatusUnauthorized, "https://dashboard.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflightMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.preflightMethod)
+				req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantAllowOrigin, got)
+			}
+			if got := rr.Header().Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("expected Access-Control-Max-Age %q, got %q", tt.wantMaxAge, got)
+			}
+			if tt.wantMaxAge != "" && rr.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+				t.Errorf("unexpected Access-Control-Allow-Methods %q", rr.Header().Get("Access-Control-Allow-Methods"))
+			}
+			if vary := rr.Header().Values("Vary"); len(vary) == 0 || vary[0] != "Origin" {
+				t.Errorf("expected Vary: Origin, got %v", vary)
+			}
+		})
+	}
+}
@@ -25,8 +25,8 @@ func TestCircuitBreakerFunctionality(t *testing.T) {
 
 	// Test 1: Circuit should be closed initially
 	stats := cbRepo.GetCircuitBreakerStats()
-	if stats.State.String() != "closed" {
-		t.Errorf("Expected circuit to be closed initially, got %s", stats.State.String())
+	if stats.Write.State.String() != "closed" || stats.Read.State.String() != "closed" {
+		t.Errorf("Expected circuits to be closed initially, got read %s, write %s", stats.Read.State, stats.Write.State)
 	}
 
 	// Test 2: Force failures to open circuit (default threshold is 5)
@@ -38,13 +38,23 @@ func TestCircuitBreakerFunctionality(t *testing.T) {
 		}
 	}
 
-	// Test 3: Circuit should be open after failures
+	// Test 3: Write circuit should be open after failures, without tripping reads
 	stats = cbRepo.GetCircuitBreakerStats()
-	if stats.State.String() != "open" {
-		t.Errorf("Expected circuit to be open after failures, got %s", stats.State.String())
+	if stats.Write.State.String() != "open" {
+		t.Errorf("Expected write circuit to be open after failures, got %s", stats.Write.State.String())
+	}
+	if stats.Read.State.String() != "closed" {
+		t.Errorf("Expected read circuit to stay closed after write failures, got %s", stats.Read.State.String())
+	}
+
+	// Test 4: Verify fallback behavior when the read circuit is open
+	for i := 0; i < 5; i++ {
+		cbRepo.GetMostFrequent(ctx)
+	}
+	if state := cbRepo.GetCircuitBreakerStats().Read.State.String(); state != "open" {
+		t.Errorf("Expected read circuit to be open after read failures, got %s", state)
 	}
 
-	// Test 4: Verify fallback behavior when circuit is open
 	entry, err := cbRepo.GetMostFrequent(ctx)
 	// Should get cache result or empty result, but no error propagation from underlying repo
 	if err != nil && err != data.ErrCircuitBreakerOpenWithFallback {
@@ -156,7 +166,7 @@ func TestDatabaseTimeoutBehavior(t *testing.T) {
 	input := data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
 
 	start := time.Now()
-	err := statsHandler.Record(ctx, &input)
+	err := statsHandler.Record(ctx, &input, "")
 	duration := time.Since(start)
 
 	// Should timeout quickly (within 200ms to account for overhead)
@@ -187,7 +197,7 @@ func TestConnectionLeakDetection(t *testing.T) {
 	input := data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
 
 	for i := 0; i < 10; i++ {
-		err := service.Record(ctx, &input)
+		err := service.Record(ctx, &input, "")
 		if err != nil {
 			t.Errorf("Record operation %d failed: %v", i, err)
 		}
@@ -223,6 +233,10 @@ func (m *mockFailingRepository) GetMostFrequent(ctx context.Context) (*data.Stat
 	return nil, &mockDatabaseError{message: "simulated database connection failure"}
 }
 
+func (m *mockFailingRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return nil, &mockDatabaseError{message: "simulated database connection failure"}
+}
+
 func (m *mockFailingRepository) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	return nil, &mockDatabaseError{message: "simulated database connection failure"}
 }
@@ -255,6 +269,10 @@ func (m *mockRepositoryWithPoolStats) GetMostFrequent(ctx context.Context) (*dat
 	}, nil
 }
 
+func (m *mockRepositoryWithPoolStats) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
 func (m *mockRepositoryWithPoolStats) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	return []*data.StatisticsEntry{
 		{
@@ -311,6 +329,10 @@ func (m *mockSlowRepository) GetMostFrequent(ctx context.Context) (*data.Statist
 	}
 }
 
+func (m *mockSlowRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
 func (m *mockSlowRepository) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	select {
 	case <-time.After(5 * time.Second):
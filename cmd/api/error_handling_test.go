@@ -265,12 +265,12 @@ func TestErrorHandling_MethodNotAllowed(t *testing.T) {
 		wantMessage string
 	}{
 		{
-			name:        "GET on POST-only endpoint",
-			method:      "GET",
+			name:        "PATCH on GET/POST endpoint",
+			method:      "PATCH",
 			path:        "/v1/fizzbuzz",
 			wantStatus:  http.StatusMethodNotAllowed,
-			wantAllow:   "POST",
-			wantMessage: "the GET method is not supported for this resource",
+			wantAllow:   "GET, POST",
+			wantMessage: "the PATCH method is not supported for this resource",
 		},
 		{
 			name:        "POST on GET-only endpoint",
@@ -285,7 +285,7 @@ func TestErrorHandling_MethodNotAllowed(t *testing.T) {
 			method:      "DELETE",
 			path:        "/v1/fizzbuzz",
 			wantStatus:  http.StatusMethodNotAllowed,
-			wantAllow:   "POST",
+			wantAllow:   "GET, POST",
 			wantMessage: "the DELETE method is not supported for this resource",
 		},
 	}
@@ -1,17 +1,35 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
 	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"fizzbuzz/internal/data"
 	"fizzbuzz/internal/validator"
 )
 
+// fizzbuzzRequest is the body of POST /v1/fizzbuzz: the FizzBuzz parameters plus optional paging.
+type fizzbuzzRequest struct {
+	data.FizzBuzzInput
+	data.Pagination
+}
+
 // fizzbuzzHandler handles POST requests to the /v1/fizzbuzz endpoint.
 // It processes FizzBuzz requests by parsing the JSON input, executing the algorithm,
-// and returning the result in the standard JSON envelope format.
+// and returning the result in the standard JSON envelope format, or as an NDJSON stream
+// when the client opts in (see wantsStream). Requests carrying paging parameters
+// receive a single page plus cursor metadata.
 func (app *application) fizzbuzzHandler(w http.ResponseWriter, r *http.Request) {
 	// Only allow POST method
 	if r.Method != http.MethodPost {
@@ -19,51 +37,136 @@ func (app *application) fizzbuzzHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Parse JSON request body into FizzBuzzInput struct
-	var input data.FizzBuzzInput
-	err := app.readJSON(w, r, &input)
+	// Parse JSON request body into FizzBuzzInput and paging parameters
+	var req fizzbuzzRequest
+	err := app.readJSON(w, r, &req)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
+	app.serveFizzBuzz(w, r, &req)
+}
+
+// fizzbuzzQueryHandler handles GET requests to the /v1/fizzbuzz endpoint.
+// Reads the same parameters as fizzbuzzHandler from the query string, with rules given as
+// "rules=3:fizz,5:buzz,7:bazz", so responses can be cached by browsers and CDNs.
+func (app *application) fizzbuzzQueryHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+
+	var req fizzbuzzRequest
+	req.FizzBuzzInput = app.readFizzBuzzInput(qs, v)
+	req.Offset = app.readInt(qs, "offset", 0, v)
+	req.PageSize = app.readInt(qs, "page_size", 0, v)
+	req.Cursor = app.readString(qs, "cursor", "")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.ErrorMap())
+		return
+	}
+
+	app.serveFizzBuzz(w, r, &req)
+}
+
+// readFizzBuzzInput reads the FizzBuzz parameters from a query string, with rules given as
+// "rules=3:fizz,5:buzz,7:bazz".
+func (app *application) readFizzBuzzInput(qs url.Values, v *validator.Validator) data.FizzBuzzInput {
+	return data.FizzBuzzInput{
+		Int1:  app.readInt(qs, "int1", 0, v),
+		Int2:  app.readInt(qs, "int2", 0, v),
+		Limit: app.readInt(qs, "limit", 0, v),
+		Str1:  app.readString(qs, "str1", ""),
+		Str2:  app.readString(qs, "str2", ""),
+		Rules: app.readRules(qs, "rules", v),
+	}
+}
+
+// fizzbuzzCacheControl is sent with GET responses; results are deterministic for their parameters.
+const fizzbuzzCacheControl = "public, max-age=86400"
+
+// serveFizzBuzz validates a parsed FizzBuzz request, records statistics for it (for the first
+// page only when paginated) and writes the result.
+// GET responses carry a strong ETag derived from the statistics key and are answered with
+// 304 Not Modified when the client's If-None-Match already matches.
+func (app *application) serveFizzBuzz(w http.ResponseWriter, r *http.Request, req *fizzbuzzRequest) {
+	input := req.FizzBuzzInput
+	paginated := req.Pagination.Enabled()
+
 	// Validate the input parameters
-	v := validateFizzBuzzInput(&input)
+	var v *validator.Validator
+	if paginated {
+		v = validatePaginatedFizzBuzzInput(&input, &req.Pagination)
+	} else {
+		v = validateFizzBuzzInput(&input)
+	}
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.ErrorMap())
 		return
 	}
 
-	// Execute the FizzBuzz algorithm using existing implementation
-	result := data.FizzBuzz(input.Int1, input.Int2, input.Limit, input.Str1, input.Str2)
+	// Resolve the slice of the sequence to compute: the whole sequence or a single page
+	start, end := 1, input.Limit
+	var metadata data.PageMetadata
+	if paginated {
+		start, end = req.Pagination.Bounds(input.Limit)
+		metadata = data.CalculatePageMetadata(input, req.Pagination)
+	}
 
-	// Story 4.6: Record statistics with context-aware PostgreSQL operations
-	// Use defensive programming to ensure statistics failure doesn't affect response
-	func() {
-		defer func() {
-			if rec := recover(); rec != nil {
-				// Log statistics recording failure but continue with response
-				app.logger.ErrorWithContext(r.Context(), "statistics recording failed",
-					"error", rec,
-					"method", "POST",
-					"uri", "/v1/fizzbuzz")
-			}
-		}()
+	stream := wantsStream(r)
 
-		// Create context with timeout for statistics recording
-		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
-		defer cancel()
+	// Conditional GET: the representation is fully determined by the parameters, page and format.
+	// It is evaluated first, so a revalidation costs only the middleware's token and is not
+	// recorded as a new request.
+	if r.Method == http.MethodGet {
+		etag := input.GenerateStatsKey()
+		if paginated {
+			etag += fmt.Sprintf("-%d-%d", req.Offset, req.PageSize)
+		}
+		if stream {
+			etag += "-ndjson"
+		}
+		etag = `"` + etag + `"`
 
-		err := app.statistics.Record(ctx, &input)
-		if err != nil {
-			// Log error but don't affect the main response
-			app.logger.WarnWithContext(ctx, "statistics recording failed",
-				"error", err,
-				"method", "POST",
-				"uri", "/v1/fizzbuzz",
-				"parameters", input)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", fizzbuzzCacheControl)
+		w.Header().Add("Vary", "Accept")
+
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
-	}()
+	}
+
+	// Charge for the elements computed, not for the request
+	if !app.chargeRateLimit(w, r, end-start+1) {
+		return
+	}
+
+	// A paginated walk is one request for its parameters: only the first page is recorded, so
+	// paging through a long sequence does not inflate its statistics
+	if start == 1 {
+		app.recordStatistics(r, &input)
+	}
+
+	// Opt-in streaming mode writes results incrementally instead of buffering them
+	if stream {
+		headers := make(http.Header)
+		if metadata.NextCursor != "" {
+			headers.Set("X-Next-Cursor", metadata.NextCursor)
+		}
+		app.streamFizzBuzz(w, r, data.Range(input.RuleSet(), start, end), headers)
+		return
+	}
+
+	// Execute the FizzBuzz algorithm over the effective rule set
+	result := data.FizzBuzzRange(input.RuleSet(), start, end)
 
 	// Create output struct with result
 	output := data.FizzBuzzOutput{
@@ -71,14 +174,211 @@ func (app *application) fizzbuzzHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Return success response using JSON envelope format
-	err = app.writeJSON(w, http.StatusOK, envelope{"data": output}, nil)
+	env := envelope{"data": output}
+	if paginated {
+		env["metadata"] = metadata
+	}
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// recordStatistics records a FizzBuzz request in the statistics store.
+// Story 4.6: Record statistics with context-aware PostgreSQL operations
+// Use defensive programming to ensure statistics failure doesn't affect response
+func (app *application) recordStatistics(r *http.Request, input *data.FizzBuzzInput) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			// Log statistics recording failure but continue with response
+			app.logger.ErrorWithContext(r.Context(), "statistics recording failed", withClientID(r,
+				"error", rec,
+				"method", r.Method,
+				"uri", r.URL.Path)...)
+		}
+	}()
+
+	// Create context with timeout for statistics recording
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	err := app.statistics.Record(ctx, input, statisticsClient(r))
+	if err != nil {
+		// Log error but don't affect the main response
+		app.logger.WarnWithContext(ctx, "statistics recording failed", withClientID(r,
+			"error", err,
+			"method", r.Method,
+			"uri", r.URL.Path,
+			"parameters", *input)...)
+	}
+}
+
+// statisticsClient returns the client a request's hit is attributed to: the API key's client ID
+// when the request is authenticated, its resolved IP address otherwise.
+func statisticsClient(r *http.Request) string {
+	if clientID := getClientID(r); clientID != "" {
+		return clientID
+	}
+	return getClientIP(r)
+}
+
+// maxBatchSize caps the number of inputs accepted by POST /v1/fizzbuzz/batch.
+const maxBatchSize = 100
+
+// maxBatchElements caps the sum of limits across a batch so one call cannot exceed
+// the work of ten maximum-size single requests.
+const maxBatchElements = 1_000_000
+
+// batchItemResult is the outcome of one input in a batch, reported in request order.
+type batchItemResult struct {
+	Index  int      `json:"index"`
+	Status int      `json:"status"`
+	Result []string `json:"result,omitempty"`
+	Error  any      `json:"error,omitempty"`
+}
+
+// fizzbuzzBatchHandler handles POST requests to the /v1/fizzbuzz/batch endpoint.
+// Accepts a JSON array of FizzBuzz inputs, validates each independently, computes the valid
+// ones concurrently and records statistics for each. Invalid items are reported with a 422
+// status and their validation errors without failing the rest of the batch.
+func (app *application) fizzbuzzBatchHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow POST method
+	if r.Method != http.MethodPost {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	var inputs []data.FizzBuzzInput
+	err := app.readJSON(w, r, &inputs)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	// Validate the batch as a whole before looking at individual items
+	v := validator.New()
+	v.Check(len(inputs) > 0, "batch", "must contain at least one request")
+	v.Check(len(inputs) <= maxBatchSize, "batch", fmt.Sprintf("must not contain more than %d requests", maxBatchSize))
+	// Items whose limit fails validation are reported individually and never computed, so only
+	// in-range limits count; this also keeps huge limits from overflowing the sum
+	total := 0
+	for _, input := range inputs {
+		if input.Limit > 0 && input.Limit <= maxLimit {
+			total += input.Limit
+		}
+	}
+	v.Check(total <= maxBatchElements, "batch", "must not request more than 1,000,000 elements in total")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.ErrorMap())
+		return
+	}
+
+	if !app.chargeRateLimit(w, r, total) {
+		return
+	}
+
+	results := make([]batchItemResult, len(inputs))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+
+	for i := range inputs {
+		results[i].Index = i
+
+		iv := validateFizzBuzzInput(&inputs[i])
+		if !iv.Valid() {
+			results[i].Status = http.StatusUnprocessableEntity
+			results[i].Error = map[string]any{
+				"message": "validation failed",
+				"details": iv.ErrorMap(),
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			input := &inputs[i]
+			app.recordStatistics(r, input)
+			results[i].Result = data.FizzBuzzRules(input.RuleSet(), input.Limit)
+			results[i].Status = http.StatusOK
+		}(i)
+	}
+
+	wg.Wait()
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": envelope{"results": results}}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
+// streamFlushInterval is the number of NDJSON lines written between flushes in streaming mode.
+const streamFlushInterval = 1024
+
+// wantsStream reports whether the client opted into streaming mode, either with an
+// Accept header listing application/x-ndjson or with the ?stream=true query parameter.
+func wantsStream(r *http.Request) bool {
+	if strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		return true
+	}
+	stream, err := strconv.ParseBool(r.URL.Query().Get("stream"))
+	return err == nil && stream
+}
+
+// streamFizzBuzz writes a FizzBuzz sequence as newline-delimited JSON, one string per line,
+// pulling each element from the lazy generator and flushing periodically so memory stays
+// flat and clients can start consuming before the sequence is complete.
+func (app *application) streamFizzBuzz(w http.ResponseWriter, r *http.Request, seq iter.Seq2[int, string], headers http.Header) {
+	flusher, canFlush := w.(http.Flusher)
+
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+	written := 0
+
+	for _, value := range seq {
+		// Encode appends the newline that terminates each NDJSON record
+		err := enc.Encode(value)
+		if err != nil {
+			app.logger.WarnWithContext(r.Context(), "fizzbuzz stream write failed",
+				"error", err,
+				"written", written)
+			return
+		}
+		written++
+
+		if written%streamFlushInterval == 0 {
+			// Stop early when the client has gone away
+			if r.Context().Err() != nil {
+				return
+			}
+			if buf.Flush() != nil {
+				return
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+	}
+
+	if buf.Flush() == nil && canFlush {
+		flusher.Flush()
+	}
+}
+
 // statisticsHandler handles GET requests to the /v1/statistics endpoint.
 // Returns the most frequently requested FizzBuzz parameters with hit count in JSON envelope format.
+// An optional ?window=24h or ?from=&to= (RFC 3339) restricts the count to requests in that period,
+// and ?client= to the requests of one client (an API key's client ID or an IP address).
 func (app *application) statisticsHandler(w http.ResponseWriter, r *http.Request) {
 	// Only allow GET method
 	if r.Method != http.MethodGet {
@@ -86,11 +386,38 @@ func (app *application) statisticsHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	v := validator.New()
+	qs := r.URL.Query()
+	from, to, windowed := app.readStatisticsWindow(qs, v)
+	client := app.readString(qs, "client", "")
+	v.Check(client == "" || !windowed, "client", "must not be combined with window, from or to")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.ErrorMap())
+		return
+	}
+
 	// Story 4.6: Get most frequent statistics with context-aware PostgreSQL operations
 	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
 	defer cancel()
 
-	mostFrequent, err := app.statistics.GetMostFrequent(ctx)
+	var mostFrequent *data.StatisticsEntry
+	var err error
+	switch {
+	case client != "":
+		var entries []*data.StatisticsEntry
+		entries, err = app.statistics.GetTopNForClient(ctx, client, 1)
+		if len(entries) > 0 {
+			mostFrequent = entries[0]
+		}
+	case windowed:
+		mostFrequent, err = app.statistics.GetMostFrequentInWindow(ctx, from, to)
+	default:
+		mostFrequent, err = app.statistics.GetMostFrequent(ctx)
+	}
+	if errors.Is(err, data.ErrClientStatisticsUnsupported) {
+		app.clientStatisticsUnsupportedResponse(w, r)
+		return
+	}
 	if err != nil {
 		app.logger.ErrorWithContext(ctx, "failed to retrieve statistics",
 			"error", err,
@@ -116,6 +443,13 @@ func (app *application) statisticsHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	if windowed {
+		responseData["window"] = envelope{"from": from, "to": to}
+	}
+	if client != "" {
+		responseData["client"] = client
+	}
+
 	// Return success response using JSON envelope format
 	err = app.writeJSON(w, http.StatusOK, envelope{"data": responseData}, nil)
 	if err != nil {
@@ -123,25 +457,273 @@ func (app *application) statisticsHandler(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// readStatisticsWindow resolves the time window requested on /v1/statistics.
+// ?window=<duration> covers the period ending now; ?from= (required) and ?to= (default now)
+// give explicit bounds. windowed is false when neither form is present.
+func (app *application) readStatisticsWindow(qs url.Values, v *validator.Validator) (from, to time.Time, windowed bool) {
+	window := app.readWindow(qs, "window", v)
+	from = app.readTime(qs, "from", v)
+	to = app.readTime(qs, "to", v)
+
+	hasWindow := qs.Get("window") != ""
+	hasRange := qs.Get("from") != "" || qs.Get("to") != ""
+	if !hasWindow && !hasRange {
+		return time.Time{}, time.Time{}, false
+	}
+
+	v.Check(!(hasWindow && hasRange), "window", "must not be combined with from or to")
+	if !v.Valid() {
+		return time.Time{}, time.Time{}, true
+	}
+
+	now := time.Now().UTC()
+	if hasWindow {
+		return now.Add(-window), now, true
+	}
+
+	v.Check(qs.Get("from") != "", "from", "must be provided")
+	if to.IsZero() {
+		to = now
+	}
+	v.Check(from.Before(to), "from", "must be before to")
+
+	return from, to, true
+}
+
+// maxTopN caps the number of entries returned by GET /v1/statistics/top.
+const maxTopN = 100
+
+// topStatisticsHandler handles GET requests to the /v1/statistics/top endpoint.
+// Returns the n most frequently requested parameter combinations (default 10, max 100)
+// ordered by hit count descending, optionally counting only the requests of ?client=.
+func (app *application) topStatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+	n := app.readInt(qs, "n", 10, v)
+	client := app.readString(qs, "client", "")
+	v.Check(n > 0, "n", "must be a positive integer")
+	v.Check(n <= maxTopN, "n", "must not be more than 100")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.ErrorMap())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	var entries []*data.StatisticsEntry
+	var err error
+	if client != "" {
+		entries, err = app.statistics.GetTopNForClient(ctx, client, n)
+	} else {
+		entries, err = app.statistics.GetTopN(ctx, n)
+	}
+	if errors.Is(err, data.ErrClientStatisticsUnsupported) {
+		app.clientStatisticsUnsupportedResponse(w, r)
+		return
+	}
+	if err != nil {
+		app.logger.ErrorWithContext(ctx, "failed to retrieve top statistics",
+			"error", err,
+			"method", "GET",
+			"uri", "/v1/statistics/top",
+			"n", n)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	topRequests := make([]envelope, 0, len(entries))
+	for _, entry := range entries {
+		topRequests = append(topRequests, envelope{
+			"request": entry.Parameters,
+			"hits":    entry.Hits,
+		})
+	}
+
+	responseData := envelope{"top_requests": topRequests}
+	if client != "" {
+		responseData["client"] = client
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": responseData}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// topClientsHandler handles GET requests to the /v1/statistics/clients endpoint.
+// Takes the FizzBuzz parameters of a request in the query string, as GET /v1/fizzbuzz does, and
+// returns the n clients (default 10, max 100) that made it most often, ordered by hit count descending.
+func (app *application) topClientsHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	qs := r.URL.Query()
+	input := app.readFizzBuzzInput(qs, v)
+	n := app.readInt(qs, "n", 10, v)
+	if v.Valid() {
+		checkFizzBuzzInput(v, &input)
+	}
+	v.Check(n > 0, "n", "must be a positive integer")
+	v.Check(n <= maxTopN, "n", "must not be more than 100")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.ErrorMap())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	clients, err := app.statistics.GetTopClients(ctx, &input, n)
+	if errors.Is(err, data.ErrClientStatisticsUnsupported) {
+		app.clientStatisticsUnsupportedResponse(w, r)
+		return
+	}
+	if err != nil {
+		app.logger.ErrorWithContext(ctx, "failed to retrieve top clients",
+			"error", err,
+			"method", "GET",
+			"uri", "/v1/statistics/clients",
+			"n", n)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": envelope{"request": input, "top_clients": clients}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// statisticsSummaryHandler handles GET requests to the /v1/statistics/summary endpoint.
+// Returns aggregate statistics: unique and total requests, average and maximum hits, and
+// the first and last request times.
+func (app *application) statisticsSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	// Only allow GET method
+	if r.Method != http.MethodGet {
+		app.methodNotAllowedResponse(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+
+	summary, err := app.statistics.GetStats(ctx)
+	if err != nil {
+		app.logger.ErrorWithContext(ctx, "failed to retrieve statistics summary",
+			"error", err,
+			"method", "GET",
+			"uri", "/v1/statistics/summary")
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": summary}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// maxRules caps the number of rules accepted in a single rule-based FizzBuzz request.
+const maxRules = 10
+
+// maxLimit caps sequences returned in a single response; it also caps the page size.
+const maxLimit = 100_000
+
+// maxPaginatedLimit caps sequences walked page by page, keeping limits within a 32-bit integer.
+const maxPaginatedLimit = 2_000_000_000
+
 // validateFizzBuzzInput performs comprehensive validation on FizzBuzz input parameters
 // according to the business rules and constraints defined in the acceptance criteria.
 func validateFizzBuzzInput(input *data.FizzBuzzInput) *validator.Validator {
 	v := validator.New()
 
-	// Integer parameter validation
-	v.Check(input.Int1 > 0, "int1", "must be a positive integer")
-	v.Check(input.Int1 <= 10000, "int1", "must not be more than 10,000")
-	v.Check(input.Int2 > 0, "int2", "must be a positive integer")
-	v.Check(input.Int2 <= 10000, "int2", "must not be more than 10,000")
-	v.Check(input.Int1 != input.Int2, "int1", "must be different from int2")
-	v.Check(input.Limit > 0, "limit", "must be a positive integer")
-	v.Check(input.Limit <= 100000, "limit", "must not be more than 100,000")
+	checkFizzBuzzInput(v, input)
+	v.Check(input.Limit <= maxLimit, "limit", "must not be more than 100,000")
+
+	return v
+}
+
+// validatePaginatedFizzBuzzInput validates a FizzBuzz request walked page by page.
+// A cursor, when present, is decoded into the page's offset (and page size if omitted)
+// before the paging bounds are checked.
+func validatePaginatedFizzBuzzInput(input *data.FizzBuzzInput, page *data.Pagination) *validator.Validator {
+	v := validator.New()
+
+	checkFizzBuzzInput(v, input)
+	v.Check(input.Limit <= maxPaginatedLimit, "limit", "must not be more than 2,000,000,000")
+
+	if page.Cursor != "" {
+		offset, pageSize, err := data.DecodeCursor(*input, page.Cursor)
+		if err != nil {
+			v.AddError("cursor", "is invalid or does not match the request parameters")
+			return v
+		}
+		page.Offset = offset
+		if page.PageSize == 0 {
+			page.PageSize = pageSize
+		}
+	}
 
-	// String parameter validation
-	v.Check(input.Str1 != "", "str1", "must be provided")
-	v.Check(len(input.Str1) <= 50, "str1", "must not be more than 50 characters")
-	v.Check(input.Str2 != "", "str2", "must be provided")
-	v.Check(len(input.Str2) <= 50, "str2", "must not be more than 50 characters")
+	v.Check(page.Offset >= 0, "offset", "must be zero or a positive integer")
+	v.Check(page.Offset < input.Limit || input.Limit <= 0, "offset", "must be less than limit")
+	v.Check(page.PageSize > 0, "page_size", "must be a positive integer")
+	v.Check(page.PageSize <= maxLimit, "page_size", "must not be more than 100,000")
 
 	return v
 }
+
+// checkFizzBuzzInput records validation errors for the rules and the lower bound of the limit;
+// callers apply the upper bound appropriate to their response mode.
+func checkFizzBuzzInput(v *validator.Validator, input *data.FizzBuzzInput) {
+	if len(input.Rules) > 0 {
+		validateRules(v, input)
+	} else {
+		// Integer parameter validation
+		v.Check(input.Int1 > 0, "int1", "must be a positive integer")
+		v.Check(input.Int1 <= 10000, "int1", "must not be more than 10,000")
+		v.Check(input.Int2 > 0, "int2", "must be a positive integer")
+		v.Check(input.Int2 <= 10000, "int2", "must not be more than 10,000")
+		v.Check(input.Int1 != input.Int2, "int1", "must be different from int2")
+
+		// String parameter validation
+		v.Check(input.Str1 != "", "str1", "must be provided")
+		v.Check(len(input.Str1) <= 50, "str1", "must not be more than 50 characters")
+		v.Check(input.Str2 != "", "str2", "must be provided")
+		v.Check(len(input.Str2) <= 50, "str2", "must not be more than 50 characters")
+	}
+
+	v.Check(input.Limit > 0, "limit", "must be a positive integer")
+}
+
+// validateRules checks a rule-based FizzBuzz input, reporting errors per rule index
+// (e.g. "rules[1].divisor") so clients can locate the offending entry.
+func validateRules(v *validator.Validator, input *data.FizzBuzzInput) {
+	v.Check(input.Int1 == 0 && input.Int2 == 0 && input.Str1 == "" && input.Str2 == "",
+		"rules", "must not be combined with int1, int2, str1 or str2")
+
+	divisors := make([]int, 0, len(input.Rules))
+	for i, rule := range input.Rules {
+		key := fmt.Sprintf("rules[%d]", i)
+
+		v.Check(rule.Divisor > 0, key+".divisor", "must be a positive integer")
+		v.Check(rule.Divisor <= 10000, key+".divisor", "must not be more than 10,000")
+		v.Check(rule.Word != "", key+".word", "must be provided")
+		v.Check(len(rule.Word) <= 50, key+".word", "must not be more than 50 characters")
+
+		divisors = append(divisors, rule.Divisor)
+	}
+
+	v.Check(validator.Unique(divisors), "rules", "must not contain duplicate divisors")
+	v.Check(len(input.Rules) <= maxRules, "rules", fmt.Sprintf("must not contain more than %d rules", maxRules))
+}
@@ -29,8 +29,8 @@ func (app *application) fizzbuzzHandlerNoStats(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// Execute the FizzBuzz algorithm using existing implementation
-	result := data.FizzBuzz(input.Int1, input.Int2, input.Limit, input.Str1, input.Str2)
+	// Execute the FizzBuzz algorithm over the effective rule set
+	result := data.FizzBuzzRules(input.RuleSet(), input.Limit)
 
 	// Create output struct with result
 	output := data.FizzBuzzOutput{
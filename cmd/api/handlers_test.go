@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"fizzbuzz/internal/data"
 )
 
 func TestFizzbuzzHandler(t *testing.T) {
@@ -140,8 +145,8 @@ func TestFizzbuzzHandler(t *testing.T) {
 		}
 	})
 
-	t.Run("GET request returns 405 Method Not Allowed", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodGet, "/v1/fizzbuzz", nil)
+	t.Run("PATCH request returns 405 Method Not Allowed", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPatch, "/v1/fizzbuzz", nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -155,7 +160,7 @@ func TestFizzbuzzHandler(t *testing.T) {
 		}
 
 		expectedError := `{
-	"error": "the GET method is not supported for this resource"
+	"error": "the PATCH method is not supported for this resource"
 }`
 		if strings.TrimSpace(rr.Body.String()) != strings.TrimSpace(expectedError) {
 			t.Errorf("expected body %s, got %s", expectedError, rr.Body.String())
@@ -319,6 +324,450 @@ func TestFizzbuzzHandler(t *testing.T) {
 	})
 }
 
+func TestFizzbuzzHandlerStreaming(t *testing.T) {
+	app := newTestApplication(t)
+
+	jsonBody := `{
+		"int1": 3,
+		"int2": 5,
+		"limit": 15,
+		"str1": "fizz",
+		"str2": "buzz"
+	}`
+	expected := []string{`"1"`, `"2"`, `"fizz"`, `"4"`, `"buzz"`, `"fizz"`, `"7"`, `"8"`, `"fizz"`, `"buzz"`, `"11"`, `"fizz"`, `"13"`, `"14"`, `"fizzbuzz"`}
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+	}{
+		{"Accept header", "/v1/fizzbuzz", "application/x-ndjson"},
+		{"stream query parameter", "/v1/fizzbuzz?stream=true", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, tt.target, strings.NewReader(jsonBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+			if contentType := rr.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+				t.Errorf("expected Content-Type application/x-ndjson, got %s", contentType)
+			}
+			if !rr.Flushed {
+				t.Error("expected streamed response to be flushed")
+			}
+
+			lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+			if strings.Join(lines, ",") != strings.Join(expected, ",") {
+				t.Errorf("expected lines %v, got %v", expected, lines)
+			}
+		})
+	}
+
+	t.Run("validation errors are not streamed", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/fizzbuzz?stream=true", strings.NewReader(`{"int1": 0, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("expected Content-Type application/json, got %s", contentType)
+		}
+	})
+}
+
+func TestFizzbuzzHandlerPagination(t *testing.T) {
+	app := newTestApplication(t)
+
+	post := func(t *testing.T, body string) (*httptest.ResponseRecorder, map[string]any) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/fizzbuzz", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+
+		var response map[string]any
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return rr, response
+	}
+
+	t.Run("walks pages with cursors", func(t *testing.T) {
+		rr, response := post(t, `{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz", "page_size": 10}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		result := response["data"].(map[string]any)["result"].([]any)
+		if len(result) != 10 || result[9] != "buzz" {
+			t.Errorf("unexpected first page: %v", result)
+		}
+
+		metadata := response["metadata"].(map[string]any)
+		cursor, ok := metadata["next_cursor"].(string)
+		if !ok || cursor == "" {
+			t.Fatalf("expected next_cursor in metadata, got %v", metadata)
+		}
+
+		rr, response = post(t, `{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz", "cursor": "`+cursor+`"}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		result = response["data"].(map[string]any)["result"].([]any)
+		expected := []any{"11", "fizz", "13", "14", "fizzbuzz"}
+		if len(result) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, result)
+		}
+		for i := range expected {
+			if result[i] != expected[i] {
+				t.Errorf("index %d: expected %v, got %v", i, expected[i], result[i])
+			}
+		}
+		if _, exists := response["metadata"].(map[string]any)["next_cursor"]; exists {
+			t.Error("expected no next_cursor on the last page")
+		}
+	})
+
+	t.Run("pages deep into large sequences", func(t *testing.T) {
+		rr, response := post(t, `{"int1": 3, "int2": 5, "limit": 2000000000, "str1": "fizz", "str2": "buzz", "offset": 1999999997, "page_size": 5}`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		result := response["data"].(map[string]any)["result"].([]any)
+		expected := []any{"fizz", "1999999999", "buzz"}
+		if len(result) != len(expected) {
+			t.Fatalf("expected %v, got %v", expected, result)
+		}
+		for i := range expected {
+			if result[i] != expected[i] {
+				t.Errorf("index %d: expected %v, got %v", i, expected[i], result[i])
+			}
+		}
+	})
+
+	t.Run("rejects cursor for different parameters", func(t *testing.T) {
+		_, response := post(t, `{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz", "page_size": 5}`)
+		cursor := response["metadata"].(map[string]any)["next_cursor"].(string)
+
+		rr, _ := post(t, `{"int1": 2, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz", "cursor": "`+cursor+`"}`)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), `"cursor"`) {
+			t.Errorf("expected cursor error, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("records a walk once", func(t *testing.T) {
+		repository := newMockRepository()
+		app.statistics = &statisticsHandler{service: data.NewStatisticsService(repository)}
+
+		body := `{"int1": 3, "int2": 7, "limit": 30, "str1": "fizz", "str2": "buzz", "page_size": 10`
+		_, response := post(t, body+`}`)
+		for cursor, _ := response["metadata"].(map[string]any)["next_cursor"].(string); cursor != ""; {
+			_, response = post(t, body+`, "cursor": "`+cursor+`"}`)
+			cursor, _ = response["metadata"].(map[string]any)["next_cursor"].(string)
+		}
+		post(t, body+`, "offset": 20}`)
+
+		summary, err := repository.GetStats(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.TotalRequests != 1 {
+			t.Errorf("expected the first page only to be recorded, got %d requests", summary.TotalRequests)
+		}
+	})
+
+	t.Run("validates paging bounds", func(t *testing.T) {
+		rr, _ := post(t, `{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz", "offset": 15, "page_size": 200000}`)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+		for _, content := range []string{`"offset": "must be less than limit"`, `"page_size": "must not be more than 100,000"`} {
+			if !strings.Contains(rr.Body.String(), content) {
+				t.Errorf("response should contain %q, got: %s", content, rr.Body.String())
+			}
+		}
+	})
+}
+
+func TestFizzbuzzQueryHandler(t *testing.T) {
+	app := newTestApplication(t)
+
+	get := func(t *testing.T, target string, headers map[string]string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("matches the POST response", func(t *testing.T) {
+		rr := get(t, "/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz", nil)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/fizzbuzz", strings.NewReader(`{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		post := httptest.NewRecorder()
+		app.routes().ServeHTTP(post, req)
+
+		if rr.Body.String() != post.Body.String() {
+			t.Errorf("expected GET body to match POST body, got %s and %s", rr.Body.String(), post.Body.String())
+		}
+		if rr.Header().Get("Cache-Control") != fizzbuzzCacheControl {
+			t.Errorf("expected Cache-Control %q, got %q", fizzbuzzCacheControl, rr.Header().Get("Cache-Control"))
+		}
+
+		input := data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+		if etag := rr.Header().Get("ETag"); etag != `"`+input.GenerateStatsKey()+`"` {
+			t.Errorf("expected ETag derived from statistics key, got %s", etag)
+		}
+	})
+
+	t.Run("If-None-Match returns 304", func(t *testing.T) {
+		target := "/v1/fizzbuzz?rules=3:fizz,5:buzz,7:bazz&limit=21"
+		rr := get(t, target, nil)
+		etag := rr.Header().Get("ETag")
+		if rr.Code != http.StatusOK || etag == "" {
+			t.Fatalf("expected 200 with ETag, got %d and %q", rr.Code, etag)
+		}
+
+		rr = get(t, target, map[string]string{"If-None-Match": `"other", ` + etag})
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+		}
+		if rr.Body.Len() != 0 {
+			t.Errorf("expected empty body, got %s", rr.Body.String())
+		}
+
+		rr = get(t, "/v1/fizzbuzz?rules=3:fizz,5:buzz,7:bazz&limit=22", map[string]string{"If-None-Match": etag})
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status %d for different parameters, got %d", http.StatusOK, rr.Code)
+		}
+	})
+
+	t.Run("304 is neither recorded nor charged for elements", func(t *testing.T) {
+		repository := newMockRepository()
+		app := newTestApplication(t)
+		app.statistics = &statisticsHandler{service: data.NewStatisticsService(repository)}
+		app.config.limiter.enabled = true
+		policy := defaultRateLimitPolicy(0.001, 10)
+		policy.Cost.ElementsPerToken = 1000
+		app.rateLimiter = newRateLimiterMapWithPolicy(policy)
+		handler := app.routes()
+
+		target := "/v1/fizzbuzz?int1=3&int2=5&limit=5000&str1=fizz&str2=buzz"
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Header().Get("X-RateLimit-Remaining") != "5" {
+			t.Fatalf("expected 200 costing 5 tokens, got %d with %v", rr.Code, rr.Header())
+		}
+
+		req = httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("If-None-Match", rr.Header().Get("ETag"))
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusNotModified {
+			t.Fatalf("expected status %d, got %d", http.StatusNotModified, rr.Code)
+		}
+		if remaining := rr.Header().Get("X-RateLimit-Remaining"); remaining != "4" {
+			t.Errorf("expected the revalidation to cost one token, got %s remaining", remaining)
+		}
+
+		summary, err := repository.GetStats(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.TotalRequests != 1 {
+			t.Errorf("expected the revalidation not to be recorded, got %d requests", summary.TotalRequests)
+		}
+	})
+
+	t.Run("pages have distinct ETags", func(t *testing.T) {
+		first := get(t, "/v1/fizzbuzz?int1=3&int2=5&limit=100&str1=fizz&str2=buzz&page_size=10", nil)
+		second := get(t, "/v1/fizzbuzz?int1=3&int2=5&limit=100&str1=fizz&str2=buzz&page_size=10&offset=10", nil)
+
+		if first.Header().Get("ETag") == second.Header().Get("ETag") {
+			t.Error("expected different ETags for different pages")
+		}
+	})
+
+	t.Run("invalid query parameters return 422", func(t *testing.T) {
+		rr := get(t, "/v1/fizzbuzz?int1=three&int2=5&limit=15&str1=fizz&str2=buzz", nil)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), `"int1": "must be an integer value"`) {
+			t.Errorf("expected integer error, got %s", rr.Body.String())
+		}
+
+		rr = get(t, "/v1/fizzbuzz?rules=3-fizz&limit=15", nil)
+		if !strings.Contains(rr.Body.String(), `"rules": "must be a comma-separated list of divisor:word pairs"`) {
+			t.Errorf("expected rules error, got %s", rr.Body.String())
+		}
+	})
+}
+
+func TestFizzbuzzBatchHandler(t *testing.T) {
+	post := func(t *testing.T, app *application, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/fizzbuzz/batch", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("returns per-item results in request order", func(t *testing.T) {
+		repository := newMockRepository()
+		app := newTestApplication(t)
+		app.statistics = &statisticsHandler{service: data.NewStatisticsService(repository)}
+
+		rr := post(t, app, `[
+			{"int1": 3, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz"},
+			{"int1": 0, "int2": 5, "limit": 15, "str1": "fizz", "str2": "buzz"},
+			{"limit": 7, "rules": [{"divisor": 7, "word": "bazz"}]}
+		]`)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var response struct {
+			Data struct {
+				Results []struct {
+					Index  int            `json:"index"`
+					Status int            `json:"status"`
+					Result []string       `json:"result"`
+					Error  map[string]any `json:"error"`
+				} `json:"results"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		results := response.Data.Results
+		if len(results) != 3 {
+			t.Fatalf("expected 3 results, got %d", len(results))
+		}
+		for i, result := range results {
+			if result.Index != i {
+				t.Errorf("expected index %d, got %d", i, result.Index)
+			}
+		}
+		if results[0].Status != http.StatusOK || results[0].Result[14] != "fizzbuzz" {
+			t.Errorf("unexpected first result: %+v", results[0])
+		}
+		if results[1].Status != http.StatusUnprocessableEntity || results[1].Error["details"] == nil {
+			t.Errorf("expected validation error for second item, got %+v", results[1])
+		}
+		if results[2].Status != http.StatusOK || results[2].Result[6] != "bazz" {
+			t.Errorf("unexpected third result: %+v", results[2])
+		}
+
+		// Only the two valid items are recorded
+		summary, err := repository.GetStats(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.TotalRequests != 2 {
+			t.Errorf("expected 2 recorded requests, got %d", summary.TotalRequests)
+		}
+	})
+
+	t.Run("rejects empty and oversized batches", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		rr := post(t, app, `[]`)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+
+		items := make([]string, maxBatchSize+1)
+		for i := range items {
+			items[i] = `{"int1": 3, "int2": 5, "limit": 1, "str1": "fizz", "str2": "buzz"}`
+		}
+		rr = post(t, app, "["+strings.Join(items, ",")+"]")
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+
+		items = make([]string, 11)
+		for i := range items {
+			items[i] = `{"int1": 3, "int2": 5, "limit": 100000, "str1": "fizz", "str2": "buzz"}`
+		}
+		rr = post(t, app, "["+strings.Join(items, ",")+"]")
+		if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "1,000,000 elements") {
+			t.Errorf("expected the element cap to apply, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("out-of-range limits cannot overflow the element total", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		// Two limits summing past the int range used to wrap the total negative, letting
+		// the eleven maximum-size items through the 1,000,000 element cap
+		items := []string{
+			`{"int1": 3, "int2": 5, "limit": 9000000000000000000, "str1": "fizz", "str2": "buzz"}`,
+			`{"int1": 3, "int2": 5, "limit": 9000000000000000000, "str1": "fizz", "str2": "buzz"}`,
+		}
+		for range 11 {
+			items = append(items, `{"int1": 3, "int2": 5, "limit": 100000, "str1": "fizz", "str2": "buzz"}`)
+		}
+		rr := post(t, app, "["+strings.Join(items, ",")+"]")
+		if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), "1,000,000 elements") {
+			t.Errorf("expected the element cap to apply, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+}
+
 // Benchmark tests for performance validation
 func BenchmarkFizzbuzzHandler(b *testing.B) {
 	app := newTestApplication(&testing.T{})
@@ -622,6 +1071,358 @@ func TestStatisticsHandler(t *testing.T) {
 	})
 }
 
+func TestStatisticsHandlerWindow(t *testing.T) {
+	app := newTestApplication(t)
+	popular := data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	other := data.FizzBuzzInput{Int1: 2, Int2: 7, Limit: 10, Str1: "foo", Str2: "bar"}
+	for i := 0; i < 3; i++ {
+		if err := app.statistics.Record(context.Background(), &popular, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.statistics.Record(context.Background(), &other, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	future := now.Add(time.Hour).Format(time.RFC3339)
+	farFuture := now.Add(2 * time.Hour).Format(time.RFC3339)
+	past := now.Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedHits int
+		expectedKeys []string
+	}{
+		{name: "window in hours", query: "window=24h", expectedCode: http.StatusOK, expectedHits: 3},
+		{name: "window in days", query: "window=7d", expectedCode: http.StatusOK, expectedHits: 3},
+		{name: "from only", query: "from=" + past, expectedCode: http.StatusOK, expectedHits: 3},
+		{name: "range without requests", query: "from=" + future + "&to=" + farFuture, expectedCode: http.StatusOK, expectedHits: 0},
+		{name: "invalid window", query: "window=soon", expectedCode: http.StatusUnprocessableEntity, expectedKeys: []string{"window"}},
+		{name: "negative window", query: "window=-1h", expectedCode: http.StatusUnprocessableEntity, expectedKeys: []string{"window"}},
+		{name: "window with range", query: "window=1h&from=" + past, expectedCode: http.StatusUnprocessableEntity, expectedKeys: []string{"window"}},
+		{name: "invalid from", query: "from=yesterday", expectedCode: http.StatusUnprocessableEntity, expectedKeys: []string{"from"}},
+		{name: "to without from", query: "to=" + future, expectedCode: http.StatusUnprocessableEntity, expectedKeys: []string{"from"}},
+		{name: "from after to", query: "from=" + farFuture + "&to=" + future, expectedCode: http.StatusUnprocessableEntity, expectedKeys: []string{"from"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/statistics?"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := httptest.NewRecorder()
+			app.routes().ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectedCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedCode, rr.Code, rr.Body.String())
+			}
+
+			if tt.expectedCode != http.StatusOK {
+				var response struct {
+					Error struct {
+						Details map[string]string `json:"details"`
+					} `json:"error"`
+				}
+				if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+					t.Fatalf("failed to decode response: %v", err)
+				}
+				for _, key := range tt.expectedKeys {
+					if _, ok := response.Error.Details[key]; !ok {
+						t.Errorf("expected validation error for %q, got %v", key, response.Error.Details)
+					}
+				}
+				return
+			}
+
+			var response struct {
+				Data struct {
+					MostFrequentRequest *data.FizzBuzzInput `json:"most_frequent_request"`
+					Hits                int                 `json:"hits"`
+					Window              struct {
+						From time.Time `json:"from"`
+						To   time.Time `json:"to"`
+					} `json:"window"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if response.Data.Hits != tt.expectedHits {
+				t.Errorf("expected %d hits, got %d", tt.expectedHits, response.Data.Hits)
+			}
+			if tt.expectedHits > 0 && (response.Data.MostFrequentRequest == nil || response.Data.MostFrequentRequest.Int1 != popular.Int1) {
+				t.Errorf("expected most frequent request %v, got %v", popular, response.Data.MostFrequentRequest)
+			}
+			if !response.Data.Window.From.Before(response.Data.Window.To) {
+				t.Errorf("expected window bounds in response, got %+v", response.Data.Window)
+			}
+		})
+	}
+}
+
+func TestTopStatisticsHandler(t *testing.T) {
+	get := func(t *testing.T, app *application, target string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("returns entries ordered by hits", func(t *testing.T) {
+		app := newTestApplication(t)
+		inputs := []data.FizzBuzzInput{
+			{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"},
+			{Int1: 2, Int2: 7, Limit: 10, Str1: "foo", Str2: "bar"},
+			{Int1: 4, Int2: 9, Limit: 20, Str1: "a", Str2: "b"},
+		}
+		for i, input := range inputs {
+			for j := 0; j <= i; j++ {
+				if err := app.statistics.Record(context.Background(), &input, ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		rr := get(t, app, "/v1/statistics/top?n=2")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var response struct {
+			Data struct {
+				TopRequests []struct {
+					Request data.FizzBuzzInput `json:"request"`
+					Hits    int                `json:"hits"`
+				} `json:"top_requests"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+
+		top := response.Data.TopRequests
+		if len(top) != 2 {
+			t.Fatalf("expected 2 entries, got %d", len(top))
+		}
+		if top[0].Hits != 3 || top[0].Request.Int1 != 4 {
+			t.Errorf("expected first entry int1=4 with 3 hits, got %+v", top[0])
+		}
+		if top[1].Hits != 2 || top[1].Request.Int1 != 2 {
+			t.Errorf("expected second entry int1=2 with 2 hits, got %+v", top[1])
+		}
+	})
+
+	t.Run("validates n", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		for _, target := range []string{"/v1/statistics/top?n=0", "/v1/statistics/top?n=101", "/v1/statistics/top?n=ten"} {
+			rr := get(t, app, target)
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("%s: expected status %d, got %d", target, http.StatusUnprocessableEntity, rr.Code)
+			}
+		}
+	})
+
+	t.Run("falls back when circuit breaker is open", func(t *testing.T) {
+		app := newTestApplication(t)
+		cbRepo := data.NewCircuitBreakerRepository(&mockFailingRepository{}, app.logger)
+		app.statistics = &statisticsHandler{service: data.NewStatisticsService(cbRepo)}
+
+		// Trip the read breaker with repeated failures
+		for i := 0; i < 6; i++ {
+			cbRepo.GetTopN(context.Background(), 10)
+		}
+
+		for _, target := range []string{"/v1/statistics/top", "/v1/statistics/summary"} {
+			rr := get(t, app, target)
+			if rr.Code != http.StatusOK {
+				t.Errorf("%s: expected status %d with open circuit, got %d: %s", target, http.StatusOK, rr.Code, rr.Body.String())
+			}
+		}
+	})
+}
+
+func TestStatisticsSummaryHandler(t *testing.T) {
+	app := newTestApplication(t)
+	input := data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	for i := 0; i < 3; i++ {
+		if err := app.statistics.Record(context.Background(), &input, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/statistics/summary", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Data data.StatsSummary `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Data.TotalUniqueRequests != 1 || response.Data.TotalRequests != 3 || response.Data.MaxHits != 3 {
+		t.Errorf("unexpected summary: %+v", response.Data)
+	}
+}
+
+func TestStatisticsByClient(t *testing.T) {
+	keys := data.NewMemoryAPIKeyRepository()
+	plaintext, _ := issueTestKey(t, keys, "acme")
+
+	app := newTestApplication(t)
+	app.statistics = &statisticsHandler{service: data.NewStatisticsService(data.NewMemoryStatisticsRepository())}
+	app.apiKeys = newAPIKeyAuthenticator(keys, "X-API-Key", 0)
+	handler := app.routes()
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", plaintext)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	fizzbuzz := `{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`
+	foobar := `{"int1":2,"int2":7,"limit":10,"str1":"foo","str2":"bar"}`
+	for _, body := range []string{fizzbuzz, foobar, foobar} {
+		if rr := serve(http.MethodPost, "/v1/fizzbuzz", body); rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+	}
+
+	t.Run("most frequent request of a client", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/v1/statistics?client=acme", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var response struct {
+			Data struct {
+				MostFrequentRequest data.FizzBuzzInput `json:"most_frequent_request"`
+				Hits                int                `json:"hits"`
+				Client              string             `json:"client"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if response.Data.Client != "acme" || response.Data.Hits != 2 || response.Data.MostFrequentRequest.Str1 != "foo" {
+			t.Errorf("unexpected response: %+v", response.Data)
+		}
+	})
+
+	t.Run("top requests of a client", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/v1/statistics/top?client=unknown", "")
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"top_requests": []`) {
+			t.Errorf("expected no requests for an unknown client, got %d: %s", rr.Code, rr.Body.String())
+		}
+	})
+
+	t.Run("top clients of a request", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/v1/statistics/clients?int1=3&int2=5&limit=15&str1=fizz&str2=buzz", "")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+		}
+
+		var response struct {
+			Data struct {
+				Request    data.FizzBuzzInput `json:"request"`
+				TopClients []data.ClientHits  `json:"top_clients"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		want := []data.ClientHits{{Client: "acme", Hits: 1}}
+		if !reflect.DeepEqual(response.Data.TopClients, want) || response.Data.Request.Limit != 15 {
+			t.Errorf("unexpected response: %+v", response.Data)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		tests := []struct {
+			target string
+			key    string
+		}{
+			{"/v1/statistics?client=acme&window=1h", "client"},
+			{"/v1/statistics/clients?int1=3&int2=5&limit=15&str1=fizz", "str2"},
+			{"/v1/statistics/clients?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&n=0", "n"},
+		}
+
+		for _, tt := range tests {
+			rr := serve(http.MethodGet, tt.target, "")
+			if rr.Code != http.StatusUnprocessableEntity || !strings.Contains(rr.Body.String(), `"`+tt.key+`"`) {
+				t.Errorf("%s: expected a validation error for %q, got %d: %s", tt.target, tt.key, rr.Code, rr.Body.String())
+			}
+		}
+	})
+}
+
+func TestStatisticsByClientIP(t *testing.T) {
+	app := newTestApplication(t)
+	app.statistics = &statisticsHandler{service: data.NewStatisticsService(data.NewMemoryStatisticsRepository())}
+	handler := app.routes()
+
+	for _, remoteAddr := range []string{"198.51.100.7:1234", "198.51.100.7:5678", "203.0.113.9:1234"} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/fizzbuzz?int1=3&int2=5&limit=15&str1=fizz&str2=buzz", nil)
+		req.RemoteAddr = remoteAddr
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/statistics/clients?int1=3&int2=5&limit=15&str1=fizz&str2=buzz&n=1", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var response struct {
+		Data struct {
+			TopClients []data.ClientHits `json:"top_clients"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	want := []data.ClientHits{{Client: "198.51.100.7", Hits: 2}}
+	if !reflect.DeepEqual(response.Data.TopClients, want) {
+		t.Errorf("expected anonymous hits attributed to the client IP, got %+v", response.Data.TopClients)
+	}
+}
+
+func TestStatisticsByClientUnsupported(t *testing.T) {
+	app := newTestApplication(t)
+
+	for _, target := range []string{"/v1/statistics?client=acme", "/v1/statistics/top?client=acme", "/v1/statistics/clients?int1=3&int2=5&limit=15&str1=fizz&str2=buzz"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusNotImplemented {
+			t.Errorf("%s: expected status %d, got %d: %s", target, http.StatusNotImplemented, rr.Code, rr.Body.String())
+		}
+	}
+}
+
 // Benchmark test for statistics endpoint performance
 func BenchmarkStatisticsHandler(b *testing.B) {
 	app := newTestApplication(&testing.T{})
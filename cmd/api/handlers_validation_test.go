@@ -299,6 +299,79 @@ func TestValidateFizzBuzzInput(t *testing.T) {
 	}
 }
 
+// TestValidateFizzBuzzInputRules tests validation of rule-based FizzBuzz inputs
+func TestValidateFizzBuzzInputRules(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          *data.FizzBuzzInput
+		expectedErrors map[string]string
+	}{
+		{
+			name: "valid rules",
+			input: &data.FizzBuzzInput{
+				Limit: 100,
+				Rules: []data.Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"}},
+			},
+			expectedErrors: nil,
+		},
+		{
+			name: "duplicate divisors",
+			input: &data.FizzBuzzInput{
+				Limit: 100,
+				Rules: []data.Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 3, Word: "fuzz"}},
+			},
+			expectedErrors: map[string]string{"rules": "must not contain duplicate divisors"},
+		},
+		{
+			name: "rules combined with legacy fields",
+			input: &data.FizzBuzzInput{
+				Int1:  3,
+				Limit: 100,
+				Rules: []data.Rule{{Divisor: 3, Word: "fizz"}},
+			},
+			expectedErrors: map[string]string{"rules": "must not be combined with int1, int2, str1 or str2"},
+		},
+		{
+			name: "invalid rule fields",
+			input: &data.FizzBuzzInput{
+				Limit: 0,
+				Rules: []data.Rule{{Divisor: 10001, Word: strings.Repeat("a", 51)}},
+			},
+			expectedErrors: map[string]string{
+				"rules[0].divisor": "must not be more than 10,000",
+				"rules[0].word":    "must not be more than 50 characters",
+				"limit":            "must be a positive integer",
+			},
+		},
+		{
+			name: "too many rules",
+			input: func() *data.FizzBuzzInput {
+				input := &data.FizzBuzzInput{Limit: 100}
+				for i := 1; i <= maxRules+1; i++ {
+					input.Rules = append(input.Rules, data.Rule{Divisor: i, Word: "w"})
+				}
+				return input
+			}(),
+			expectedErrors: map[string]string{"rules": "must not contain more than 10 rules"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errorMap := validateFizzBuzzInput(tt.input).ErrorMap()
+
+			if len(errorMap) != len(tt.expectedErrors) {
+				t.Errorf("expected %d errors, got %d: %v", len(tt.expectedErrors), len(errorMap), errorMap)
+			}
+			for field, expectedMsg := range tt.expectedErrors {
+				if actualMsg := errorMap[field]; actualMsg != expectedMsg {
+					t.Errorf("field %s: expected error %q, got %q", field, expectedMsg, actualMsg)
+				}
+			}
+		})
+	}
+}
+
 // TestFizzBuzzValidationIntegration tests validation integration with HTTP handler
 func TestFizzBuzzValidationIntegration(t *testing.T) {
 	app := newTestApplication(t)
@@ -454,6 +527,34 @@ func TestFizzBuzzValidationIntegration(t *testing.T) {
 			expectedStatus: http.StatusOK,
 			shouldContain:  []string{`"data"`, `"result"`},
 		},
+		{
+			name: "rule-based request with three rules",
+			requestBody: `{
+				"limit": 21,
+				"rules": [
+					{"divisor": 3, "word": "fizz"},
+					{"divisor": 5, "word": "buzz"},
+					{"divisor": 7, "word": "bazz"}
+				]
+			}`,
+			expectedStatus: http.StatusOK,
+			shouldContain:  []string{`"fizzbuzz"`, `"fizzbazz"`, `"bazz"`},
+		},
+		{
+			name: "rule-based request reports per-rule errors",
+			requestBody: `{
+				"limit": 15,
+				"rules": [
+					{"divisor": 3, "word": "fizz"},
+					{"divisor": 0, "word": ""}
+				]
+			}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+			shouldContain: []string{
+				`"rules[1].divisor": "must be a positive integer"`,
+				`"rules[1].word": "must be provided"`,
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/health"
+)
+
+// maxTrackedClients is the rate limiter map size above which the rate_limiter check warns;
+// a map this large usually means cleanup is falling behind or the API is being scanned.
+const maxTrackedClients = 100_000
+
+// newHealthRegistry registers the API's dependency checks. FizzBuzz keeps serving without
+// statistics, so database checks only make the service unready when databaseCritical is set.
+func newHealthRegistry(app *application, databaseCritical bool) *health.Registry {
+	registry := health.NewRegistry(1 * time.Second)
+
+	registry.Register("database", databaseCritical, func(ctx context.Context) health.Result {
+		if _, err := app.statistics.GetDatabaseHealth(ctx); err != nil {
+			return health.Result{Status: health.StatusFail, Message: err.Error()}
+		}
+		return health.Result{Status: health.StatusPass}
+	})
+
+	registry.Register("database_pool", databaseCritical, func(ctx context.Context) health.Result {
+		stats, err := app.statistics.GetPoolStats(ctx)
+		if err != nil {
+			return health.Result{Status: health.StatusFail, Message: err.Error()}
+		}
+
+		switch stats.Status {
+		case "healthy":
+			return health.Result{Status: health.StatusPass, Details: stats}
+		case "degraded":
+			return health.Result{Status: health.StatusWarn, Message: "connection pool is degraded", Details: stats}
+		default:
+			return health.Result{Status: health.StatusFail, Message: "connection pool is " + stats.Status, Details: stats}
+		}
+	})
+
+	// Only backends protected by circuit breakers report their state
+	if reporter, ok := app.statistics.(circuitBreakerReporter); ok {
+		if _, protected := reporter.GetCircuitBreakerStats(); protected {
+			registry.Register("circuit_breaker", databaseCritical, func(ctx context.Context) health.Result {
+				stats, _ := reporter.GetCircuitBreakerStats()
+				breakers := []struct {
+					name  string
+					stats data.CircuitBreakerStats
+				}{{"read", stats.Read}, {"write", stats.Write}}
+
+				details := make(map[string]any, len(breakers))
+				result := health.Result{Status: health.StatusPass, Details: details}
+				for _, breaker := range breakers {
+					details[breaker.name] = map[string]any{
+						"state":     breaker.stats.State.String(),
+						"failures":  breaker.stats.Failures,
+						"successes": breaker.stats.Successes,
+					}
+
+					switch breaker.stats.State {
+					case data.CircuitOpen:
+						result.Status = health.StatusFail
+						result.Message = breaker.name + " circuit breaker is open"
+					case data.CircuitHalfOpen:
+						if result.Status == health.StatusPass {
+							result.Status = health.StatusWarn
+							result.Message = breaker.name + " circuit breaker is probing recovery"
+						}
+					}
+				}
+				return result
+			})
+		}
+	}
+
+	// Only backends configured with an outage spool report its depth
+	if reporter, ok := app.statistics.(spoolReporter); ok {
+		if _, spooling := reporter.GetSpoolStats(); spooling {
+			registry.Register("statistics_spool", false, func(ctx context.Context) health.Result {
+				stats, _ := reporter.GetSpoolStats()
+
+				switch {
+				case stats.SizeBytes >= stats.MaxBytes:
+					return health.Result{Status: health.StatusFail, Message: "statistics spool is full, new hits are dropped", Details: stats}
+				case stats.Depth > 0:
+					return health.Result{Status: health.StatusWarn, Message: "statistics hits are waiting to be replayed", Details: stats}
+				default:
+					return health.Result{Status: health.StatusPass, Details: stats}
+				}
+			})
+		}
+	}
+
+	if app.rateLimiter != nil {
+		registry.Register("rate_limiter", false, func(ctx context.Context) health.Result {
+			clients, rps, burst := app.rateLimiter.getStats()
+			details := map[string]any{
+				"enabled":  app.config.limiter.enabled,
+				"clients":  clients,
+				"rps":      rps,
+				"burst":    burst,
+				"rejected": app.rateLimiter.rejected.Load(),
+			}
+
+			// Requests are let through while a shared store is unreachable
+			if err := app.rateLimiter.store.ping(ctx); err != nil {
+				return health.Result{Status: health.StatusWarn, Message: "rate limit store unreachable, requests are not limited: " + err.Error(), Details: details}
+			}
+
+			if clients >= maxTrackedClients {
+				return health.Result{Status: health.StatusWarn, Message: "rate limiter is tracking an unusually large number of clients", Details: details}
+			}
+			return health.Result{Status: health.StatusPass, Details: details}
+		})
+	}
+
+	return registry
+}
+
+// liveHandler handles GET requests to the /v1/health/live endpoint.
+// Reports that the process is up and serving HTTP; it never inspects dependencies,
+// so an orchestrator only restarts the pod when the API itself is wedged.
+func (app *application) liveHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"data": envelope{"status": health.StatusPass}}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readyHandler handles GET requests to the /v1/health/ready endpoint.
+// Runs every registered check and returns 503 only when a critical check fails;
+// degraded non-critical dependencies are reported with status "warn" and a 200.
+func (app *application) readyHandler(w http.ResponseWriter, r *http.Request) {
+	report := health.Report{Status: health.StatusPass, Checks: []health.CheckResult{}}
+	if app.health != nil {
+		report = app.health.Run(r.Context())
+	}
+
+	statusCode := http.StatusOK
+	if report.Status == health.StatusFail {
+		statusCode = http.StatusServiceUnavailable
+		app.logger.WarnWithContext(r.Context(), "readiness check failed", "checks", report.Checks)
+	}
+
+	err := app.writeJSON(w, statusCode, envelope{"data": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/health"
+	"fizzbuzz/internal/jsonlog"
+)
+
+// readyResponse decodes the /v1/health/ready envelope
+type readyResponse struct {
+	Data health.Report `json:"data"`
+}
+
+// serveReady runs GET /v1/health/ready through the full middleware chain
+func serveReady(t *testing.T, app *application) (int, health.Report) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/health/ready", nil))
+
+	var response readyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v\n%s", err, rr.Body.String())
+	}
+	return rr.Code, response.Data
+}
+
+// checkStatuses maps each check name in report to its status
+func checkStatuses(report health.Report) map[string]health.Status {
+	statuses := make(map[string]health.Status, len(report.Checks))
+	for _, check := range report.Checks {
+		statuses[check.Name] = check.Status
+	}
+	return statuses
+}
+
+func TestLiveHandler(t *testing.T) {
+	app := newTestApplication(t)
+	app.statistics = &statisticsHandler{service: data.NewStatisticsService(&mockFailingRepository{})}
+	app.health = newHealthRegistry(app, true)
+
+	rr := httptest.NewRecorder()
+	app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/health/live", nil))
+
+	// Liveness never depends on the database
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var response readyResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if response.Data.Status != health.StatusPass {
+		t.Errorf("expected status pass, got %q", response.Data.Status)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	t.Run("healthy dependencies", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.config.limiter.enabled = true
+		app.rateLimiter = newRateLimiterMap(10, 20)
+		app.health = newHealthRegistry(app, true)
+
+		code, report := serveReady(t, app)
+		if code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, code)
+		}
+		if report.Status != health.StatusPass {
+			t.Errorf("expected status pass, got %s: %+v", report.Status, report.Checks)
+		}
+
+		statuses := checkStatuses(report)
+		for _, name := range []string{"database", "database_pool", "rate_limiter"} {
+			if statuses[name] != health.StatusPass {
+				t.Errorf("expected check %s to pass, got %q", name, statuses[name])
+			}
+		}
+		if _, exists := statuses["circuit_breaker"]; exists {
+			t.Error("expected no circuit_breaker check without a breaker")
+		}
+	})
+
+	tests := []struct {
+		name           string
+		critical       bool
+		expectedCode   int
+		expectedStatus health.Status
+	}{
+		{"database down, non-critical", false, http.StatusOK, health.StatusWarn},
+		{"database down, critical", true, http.StatusServiceUnavailable, health.StatusFail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.statistics = &statisticsHandler{service: data.NewStatisticsService(&mockFailingRepository{})}
+			app.health = newHealthRegistry(app, tt.critical)
+
+			code, report := serveReady(t, app)
+			if code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, code)
+			}
+			if report.Status != tt.expectedStatus {
+				t.Errorf("expected report status %s, got %s", tt.expectedStatus, report.Status)
+			}
+
+			for _, check := range report.Checks {
+				if check.Name == "database" {
+					if check.Status != health.StatusFail || check.Critical != tt.critical || check.Message == "" {
+						t.Errorf("unexpected database check: %+v", check)
+					}
+					return
+				}
+			}
+			t.Error("expected a database check")
+		})
+	}
+
+	t.Run("circuit breaker open", func(t *testing.T) {
+		app := newTestApplication(t)
+		cbRepo := data.NewCircuitBreakerRepository(&mockFailingRepository{}, jsonlog.New(&bytes.Buffer{}, jsonlog.LevelError, "test"))
+		app.statistics = &statisticsHandler{service: data.NewStatisticsService(cbRepo)}
+		app.health = newHealthRegistry(app, false)
+
+		for i := 0; i < 6; i++ {
+			cbRepo.Record(context.Background(), data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"})
+		}
+
+		_, report := serveReady(t, app)
+		if status := checkStatuses(report)["circuit_breaker"]; status != health.StatusFail {
+			t.Errorf("expected circuit_breaker check to fail, got %q", status)
+		}
+	})
+
+	t.Run("statistics spool depth", func(t *testing.T) {
+		app := newTestApplication(t)
+		cbRepo := data.NewCircuitBreakerRepository(&mockFailingRepository{}, jsonlog.New(&bytes.Buffer{}, jsonlog.LevelError, "test"))
+		spool, err := data.OpenSpool(filepath.Join(t.TempDir(), "statistics.spool"), 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cbRepo.SetSpool(spool)
+		defer cbRepo.Close()
+		app.statistics = &statisticsHandler{service: data.NewStatisticsService(cbRepo)}
+		app.health = newHealthRegistry(app, false)
+
+		// Trip the write breaker, then one more write lands in the spool
+		for i := 0; i < 6; i++ {
+			cbRepo.Record(context.Background(), data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"})
+		}
+
+		_, report := serveReady(t, app)
+		for _, check := range report.Checks {
+			if check.Name == "statistics_spool" {
+				details, _ := check.Details.(map[string]any)
+				if check.Status != health.StatusWarn || details["depth"] != float64(1) {
+					t.Errorf("expected a warning with depth 1, got %+v", check)
+				}
+				return
+			}
+		}
+		t.Error("expected a statistics_spool check")
+	})
+
+	t.Run("no registry", func(t *testing.T) {
+		code, report := serveReady(t, newTestApplication(t))
+		if code != http.StatusOK || report.Status != health.StatusPass || len(report.Checks) != 0 {
+			t.Errorf("expected pass with no checks, got %d %+v", code, report)
+		}
+	})
+}
@@ -41,7 +41,7 @@ func (m *mockStatisticsHandler) GetDatabaseHealth(ctx context.Context) (map[stri
 	}, nil
 }
 
-func (m *mockStatisticsHandler) Record(ctx context.Context, input *data.FizzBuzzInput) error {
+func (m *mockStatisticsHandler) Record(ctx context.Context, input *data.FizzBuzzInput, client string) error {
 	return nil
 }
 
@@ -49,6 +49,26 @@ func (m *mockStatisticsHandler) GetMostFrequent(ctx context.Context) (*data.Stat
 	return nil, nil
 }
 
+func (m *mockStatisticsHandler) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
+func (m *mockStatisticsHandler) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
+	return []*data.StatisticsEntry{}, nil
+}
+
+func (m *mockStatisticsHandler) GetTopNForClient(ctx context.Context, client string, n int) ([]*data.StatisticsEntry, error) {
+	return []*data.StatisticsEntry{}, nil
+}
+
+func (m *mockStatisticsHandler) GetTopClients(ctx context.Context, input *data.FizzBuzzInput, n int) ([]data.ClientHits, error) {
+	return []data.ClientHits{}, nil
+}
+
+func (m *mockStatisticsHandler) GetStats(ctx context.Context) (data.StatsSummary, error) {
+	return data.StatsSummary{}, nil
+}
+
 func (m *mockStatisticsHandler) GetPoolStats(ctx context.Context) (*data.PoolStats, error) {
 	return &data.PoolStats{
 		TotalConnections:  10,
@@ -67,6 +87,10 @@ func (m *mockStatisticsHandler) GetMostFrequentLegacy(logger *jsonlog.Logger) *d
 	return nil
 }
 
+func (m *mockStatisticsHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
 func (m *mockStatisticsHandler) Close() error {
 	return nil
 }
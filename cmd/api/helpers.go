@@ -4,9 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/validator"
 )
 
 type envelope map[string]any
@@ -93,6 +99,112 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 	return nil
 }
 
+// readString returns a string value from the query string, or the default if the key is absent.
+func (app *application) readString(qs url.Values, key string, defaultValue string) string {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// readInt returns an integer value from the query string, or the default if the key is absent.
+// Records a validation error when the value cannot be converted to an integer.
+func (app *application) readInt(qs url.Values, key string, defaultValue int, v *validator.Validator) int {
+	s := qs.Get(key)
+	if s == "" {
+		return defaultValue
+	}
+
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		v.AddError(key, "must be an integer value")
+		return defaultValue
+	}
+	return i
+}
+
+// readTime parses an RFC 3339 timestamp from the query string.
+// Returns the zero time, recording a validation error when the value is malformed.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp")
+		return time.Time{}
+	}
+	return t
+}
+
+// readWindow parses a positive duration such as "90m", "24h" or "7d" from the query string.
+// Accepts Go duration syntax plus a whole-day "d" suffix, which time.ParseDuration lacks.
+func (app *application) readWindow(qs url.Values, key string, v *validator.Validator) time.Duration {
+	s := qs.Get(key)
+	if s == "" {
+		return 0
+	}
+
+	var d time.Duration
+	var err error
+	if days, found := strings.CutSuffix(s, "d"); found {
+		var n int
+		n, err = strconv.Atoi(days)
+		if n > int(math.MaxInt64/int64(24*time.Hour)) {
+			n = 0 // Would overflow time.Duration
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+
+	if err != nil || d <= 0 {
+		v.AddError(key, "must be a positive duration such as 24h or 7d")
+		return 0
+	}
+	return d
+}
+
+// readRules parses a comma-separated list of divisor:word pairs (e.g. "3:fizz,5:buzz") from the query string.
+// Records a validation error when a pair is malformed.
+func (app *application) readRules(qs url.Values, key string, v *validator.Validator) []data.Rule {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+
+	var rules []data.Rule
+	for _, pair := range strings.Split(s, ",") {
+		divisor, word, found := strings.Cut(pair, ":")
+		d, err := strconv.Atoi(divisor)
+		if !found || err != nil {
+			v.AddError(key, "must be a comma-separated list of divisor:word pairs")
+			return nil
+		}
+		rules = append(rules, data.Rule{Divisor: d, Word: word})
+	}
+	return rules
+}
+
+// etagMatches reports whether an If-None-Match header value matches the given entity tag.
+// Uses the weak comparison required for If-None-Match, so W/ prefixes are ignored.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
 func (app *application) errorJSON(w http.ResponseWriter, r *http.Request, status int, message any) {
 	env := envelope{"error": message}
 
@@ -107,11 +219,11 @@ func (app *application) errorJSON(w http.ResponseWriter, r *http.Request, status
 }
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.logger.ErrorWithContext(r.Context(), "server error",
+	app.logger.ErrorWithContext(r.Context(), "server error", withClientID(r,
 		"error", err,
 		"method", r.Method,
 		"uri", r.URL.RequestURI(),
-		"addr", r.RemoteAddr)
+		"addr", r.RemoteAddr)...)
 	message := "the server encountered a problem and could not process your request"
 	app.errorJSON(w, r, http.StatusInternalServerError, message)
 }
@@ -125,8 +237,10 @@ func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.
 	// Set Allow header based on the requested path
 	switch r.URL.Path {
 	case "/v1/fizzbuzz":
+		w.Header().Set("Allow", "GET, POST")
+	case "/v1/fizzbuzz/batch":
 		w.Header().Set("Allow", "POST")
-	case "/v1/healthcheck", "/v1/statistics":
+	case "/v1/healthcheck", "/v1/health/live", "/v1/health/ready", "/v1/statistics", "/v1/statistics/top", "/v1/statistics/summary", "/v1/statistics/clients", "/v1/ratelimit", "/metrics":
 		w.Header().Set("Allow", "GET")
 	default:
 		w.Header().Set("Allow", "GET, POST")
@@ -151,14 +265,68 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 	app.errorJSON(w, r, status, message)
 }
 
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "ApiKey")
+	message := "you must be authenticated to access this resource"
+	app.errorJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "ApiKey")
+	message := "invalid API key"
+	app.errorJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) revokedAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this API key has been revoked"
+	app.errorJSON(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) clientStatisticsUnsupportedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "per-client statistics are not supported by the configured statistics store"
+	app.errorJSON(w, r, http.StatusNotImplemented, message)
+}
+
+func (app *application) bearerTokenRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="fizzbuzz"`)
+	message := "you must be authenticated to access this resource"
+	app.errorJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidBearerTokenResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// Describe the failure by its sentinel error alone, which never quotes token contents
+	description := err.Error()
+	if sentinel := errors.Unwrap(err); sentinel != nil {
+		description = sentinel.Error()
+	}
+	description = strings.TrimPrefix(description, "jwt: ")
+
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="fizzbuzz", error="invalid_token", error_description=%q`, description))
+	message := "invalid or expired bearer token"
+	app.errorJSON(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) insufficientScopeResponse(w http.ResponseWriter, r *http.Request, scope string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="fizzbuzz", error="insufficient_scope", scope=%q`, scope))
+	message := "your token does not grant the " + scope + " scope required by this resource"
+	app.errorJSON(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) costExceedsBurstResponse(w http.ResponseWriter, r *http.Request, cost, burst, maxElements int) {
+	message := fmt.Sprintf("this request costs %d rate limit tokens but your bucket holds at most %d: "+
+		"request at most %d elements at a time, paging long sequences with page_size", cost, burst, maxElements)
+	app.errorJSON(w, r, http.StatusUnprocessableEntity, message)
+}
+
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
-	// Set Retry-After header with suggested wait time in seconds
-	retryAfterSeconds := int(retryAfter.Seconds())
+	// Set Retry-After header with suggested wait time in whole seconds, rounded up so that
+	// a client waiting that long finds enough tokens
+	retryAfterSeconds := int(math.Ceil(retryAfter.Seconds()))
 	if retryAfterSeconds < 1 {
 		retryAfterSeconds = 1
 	}
 	w.Header().Set("Retry-After", fmt.Sprintf("%d", retryAfterSeconds))
 
-	message := "rate limit exceeded - too many requests from this IP address"
+	message := "rate limit exceeded - too many requests from this client"
 	app.errorJSON(w, r, http.StatusTooManyRequests, message)
 }
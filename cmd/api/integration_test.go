@@ -545,6 +545,13 @@ func TestConcurrentRequests(t *testing.T) {
 type mockRepositoryForTesting struct {
 	mu      sync.RWMutex
 	entries map[string]*data.StatisticsEntry
+	history []mockHit
+}
+
+// mockHit records when a parameter combination was requested, for window queries
+type mockHit struct {
+	hash string
+	at   time.Time
 }
 
 func newMockRepository() *mockRepositoryForTesting {
@@ -559,6 +566,8 @@ func (m *mockRepositoryForTesting) Record(ctx context.Context, input data.FizzBu
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.history = append(m.history, mockHit{hash: hash, at: time.Now()})
+
 	if entry, exists := m.entries[hash]; exists {
 		entry.Hits++
 		entry.UpdatedAt = time.Now()
@@ -593,6 +602,28 @@ func (m *mockRepositoryForTesting) GetMostFrequent(ctx context.Context) (*data.S
 	return mostFrequent, nil
 }
 
+func (m *mockRepositoryForTesting) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hits := make(map[string]int)
+	for _, hit := range m.history {
+		if !hit.at.Before(from) && hit.at.Before(to) {
+			hits[hit.hash]++
+		}
+	}
+
+	var mostFrequent *data.StatisticsEntry
+	for hash, count := range hits {
+		if mostFrequent == nil || count > mostFrequent.Hits {
+			entry := *m.entries[hash]
+			entry.Hits = count
+			mostFrequent = &entry
+		}
+	}
+	return mostFrequent, nil
+}
+
 func (m *mockRepositoryForTesting) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -13,7 +13,10 @@ import (
 	"time"
 
 	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/health"
 	"fizzbuzz/internal/jsonlog"
+	"fizzbuzz/internal/jwt"
+	"fizzbuzz/internal/resp"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -24,12 +27,18 @@ var (
 
 // StatisticsHandlerInterface defines the interface for statistics operations
 type StatisticsHandlerInterface interface {
-	Record(ctx context.Context, input *data.FizzBuzzInput) error
+	Record(ctx context.Context, input *data.FizzBuzzInput, client string) error
 	GetMostFrequent(ctx context.Context) (*data.StatisticsEntry, error)
+	GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error)
+	GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error)
+	GetTopNForClient(ctx context.Context, client string, n int) ([]*data.StatisticsEntry, error)
+	GetTopClients(ctx context.Context, input *data.FizzBuzzInput, n int) ([]data.ClientHits, error)
+	GetStats(ctx context.Context) (data.StatsSummary, error)
 	GetDatabaseHealth(ctx context.Context) (map[string]interface{}, error)
 	GetPoolStats(ctx context.Context) (*data.PoolStats, error)
 	RecordLegacy(input *data.FizzBuzzInput, logger *jsonlog.Logger)
 	GetMostFrequentLegacy(logger *jsonlog.Logger) *data.StatisticsEntry
+	Flush(ctx context.Context) error
 	Close() error
 }
 
@@ -38,23 +47,30 @@ type application struct {
 	logger      *jsonlog.Logger
 	statistics  StatisticsHandlerInterface
 	rateLimiter *rateLimiterMap
+	authLimiter *rateLimiterMap // Per-IP limit on credential checks, nil when disabled
+	clientIPs   *clientIPResolver
+	corsPolicy  *corsPolicy
+	apiKeys     *apiKeyAuthenticator
+	tokens      *jwtAuthenticator
+	metrics     *apiMetrics
+	health      *health.Registry
 }
 
 // statisticsHandler provides concrete implementation for statistics operations
-// Story 4.6: Direct PostgreSQL access with context-aware operations
+// It wraps whichever StatisticsRepository the configured store selects
 type statisticsHandler struct {
-	service *data.StatisticsService // PostgreSQL-backed implementation only
+	service *data.StatisticsService // Backed by the PostgreSQL or in-memory repository
 }
 
-// Record records statistics using PostgreSQL service with context and timeout
-func (sh *statisticsHandler) Record(ctx context.Context, input *data.FizzBuzzInput) error {
+// Record records statistics attributed to client through the service with context and timeout
+func (sh *statisticsHandler) Record(ctx context.Context, input *data.FizzBuzzInput, client string) error {
 	if sh.service == nil {
 		return errors.New("statistics service not initialized")
 	}
-	return sh.service.Record(ctx, input)
+	return sh.service.Record(ctx, input, client)
 }
 
-// GetMostFrequent gets most frequent statistics from PostgreSQL with context
+// GetMostFrequent gets most frequent statistics from the configured store with context
 func (sh *statisticsHandler) GetMostFrequent(ctx context.Context) (*data.StatisticsEntry, error) {
 	if sh.service == nil {
 		return nil, errors.New("statistics service not initialized")
@@ -62,6 +78,46 @@ func (sh *statisticsHandler) GetMostFrequent(ctx context.Context) (*data.Statist
 	return sh.service.GetMostFrequent(ctx)
 }
 
+// GetMostFrequentInWindow gets the most frequent statistics between from and to from the configured store with context
+func (sh *statisticsHandler) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	if sh.service == nil {
+		return nil, errors.New("statistics service not initialized")
+	}
+	return sh.service.GetMostFrequentInWindow(ctx, from, to)
+}
+
+// GetTopN gets the n most frequent parameter combinations from the configured store with context
+func (sh *statisticsHandler) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
+	if sh.service == nil {
+		return nil, errors.New("statistics service not initialized")
+	}
+	return sh.service.GetTopN(ctx, n)
+}
+
+// GetTopNForClient gets the n parameter combinations client requested most often with context
+func (sh *statisticsHandler) GetTopNForClient(ctx context.Context, client string, n int) ([]*data.StatisticsEntry, error) {
+	if sh.service == nil {
+		return nil, errors.New("statistics service not initialized")
+	}
+	return sh.service.GetTopNForClient(ctx, client, n)
+}
+
+// GetTopClients gets the n clients that requested input most often with context
+func (sh *statisticsHandler) GetTopClients(ctx context.Context, input *data.FizzBuzzInput, n int) ([]data.ClientHits, error) {
+	if sh.service == nil {
+		return nil, errors.New("statistics service not initialized")
+	}
+	return sh.service.GetTopClients(ctx, input, n)
+}
+
+// GetStats gets aggregate statistics from the configured store with context
+func (sh *statisticsHandler) GetStats(ctx context.Context) (data.StatsSummary, error) {
+	if sh.service == nil {
+		return data.StatsSummary{}, errors.New("statistics service not initialized")
+	}
+	return sh.service.GetStats(ctx)
+}
+
 // Legacy compatibility methods for transition period
 // RecordLegacy provides legacy-compatible Record method (no context, no error return)
 func (sh *statisticsHandler) RecordLegacy(input *data.FizzBuzzInput, logger *jsonlog.Logger) {
@@ -69,7 +125,7 @@ func (sh *statisticsHandler) RecordLegacy(input *data.FizzBuzzInput, logger *jso
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := sh.Record(ctx, input)
+	err := sh.Record(ctx, input, "")
 	if err != nil {
 		if logger != nil {
 			logger.Warn("legacy statistics recording failed",
@@ -120,6 +176,30 @@ func (sh *statisticsHandler) GetPoolStats(ctx context.Context) (*data.PoolStats,
 	return sh.service.GetPoolStats(ctx)
 }
 
+// GetCircuitBreakerStats returns the state of the circuit breakers protecting the database, if any
+func (sh *statisticsHandler) GetCircuitBreakerStats() (data.CircuitBreakerRepositoryStats, bool) {
+	if sh.service == nil {
+		return data.CircuitBreakerRepositoryStats{}, false
+	}
+	return sh.service.GetCircuitBreakerStats()
+}
+
+// GetSpoolStats returns the depth of the statistics outage spool, if one is configured
+func (sh *statisticsHandler) GetSpoolStats() (data.SpoolStats, bool) {
+	if sh.service == nil {
+		return data.SpoolStats{}, false
+	}
+	return sh.service.GetSpoolStats()
+}
+
+// Flush writes any buffered statistics to the database
+func (sh *statisticsHandler) Flush(ctx context.Context) error {
+	if sh.service == nil {
+		return nil
+	}
+	return sh.service.Flush(ctx)
+}
+
 // Close closes the database connections
 func (sh *statisticsHandler) Close() error {
 	if sh.service == nil {
@@ -149,15 +229,81 @@ type config struct {
 		monitoringEnabled bool
 	}
 
+	stats struct {
+		backend       string
+		batchSize     int
+		bufferSize    int
+		flushInterval time.Duration
+		spoolPath     string
+		spoolMaxBytes int
+		spoolSync     time.Duration
+	}
+
+	circuitBreaker struct {
+		policy            string
+		failureThreshold  int
+		failureRate       float64
+		window            time.Duration
+		minRequests       int
+		slowCallThreshold time.Duration
+		recoveryTimeout   time.Duration
+		successThreshold  int
+		halfOpenProbes    int
+		timeout           time.Duration
+	}
+
 	limiter struct {
-		enabled bool
-		rps     float64
-		burst   int
+		enabled    bool
+		rps        float64
+		burst      int
+		costUnit   int
+		policyPath string
+		policy     *rateLimitPolicy // Loaded from policyPath; nil limits every client IP to rps and burst
+		store      string           // "memory" or "redis"
+		redis      struct {
+			addr     string
+			password string
+			db       int
+			prefix   string
+		}
+	}
+
+	proxies struct {
+		trusted string // Comma-separated CIDRs whose forwarding headers are believed
+	}
+
+	cors struct {
+		origins string // Comma-separated exact or wildcard origins; empty disables CORS
+		methods string
+		headers string
+		maxAge  time.Duration
+	}
+
+	auth struct {
+		mode         string // "none", "api-key" or "jwt"
+		apiKeyHeader string
+		cacheTTL     time.Duration
+		limiter      struct {
+			rps   float64 // Per-IP credential checks per second, 0 disables
+			burst int
+		}
+		jwt struct {
+			secret   string // HS256 shared secret
+			jwksFile string // Local JSON Web Key Set, used instead of secret
+			audience string
+			issuer   string
+			leeway   time.Duration
+			scopes   string // Comma-separated route=scope overrides
+		}
 	}
 
 	shutdown struct {
 		timeout time.Duration
 	}
+
+	health struct {
+		databaseCritical bool
+	}
 }
 
 // getEnvString returns environment variable value or default if not set
@@ -208,9 +354,9 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
-// initializePostgreSQLStatistics initializes PostgreSQL connection pool and statistics service
-// Story 4.6: Direct PostgreSQL access with connection pooling and context-aware operations
-func initializePostgreSQLStatistics(cfg config, logger *jsonlog.Logger) (StatisticsHandlerInterface, error) {
+// openPostgreSQLPool connects a connection pool of at most maxConns connections to the configured
+// database and checks that it answers
+func openPostgreSQLPool(cfg config, maxConns int) (*pgxpool.Pool, error) {
 	// Build PostgreSQL connection string
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		cfg.db.host, cfg.db.port, cfg.db.user, cfg.db.password, cfg.db.name, cfg.db.sslMode)
@@ -222,7 +368,7 @@ func initializePostgreSQLStatistics(cfg config, logger *jsonlog.Logger) (Statist
 	}
 
 	// Connection pooling configuration for optimal performance
-	poolConfig.MaxConns = int32(cfg.db.maxConns)
+	poolConfig.MaxConns = int32(maxConns)
 	poolConfig.MinConns = 2                                 // Maintain minimum connections for immediate availability
 	poolConfig.MaxConnLifetime = cfg.db.maxLifetime         // Connection refresh for long-running applications
 	poolConfig.MaxConnIdleTime = 10 * time.Minute           // Close idle connections to free resources
@@ -245,14 +391,65 @@ func initializePostgreSQLStatistics(cfg config, logger *jsonlog.Logger) (Statist
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	return pool, nil
+}
+
+// initializePostgreSQLStatistics initializes PostgreSQL connection pool and statistics service
+// Story 4.6: Direct PostgreSQL access with connection pooling and context-aware operations
+func initializePostgreSQLStatistics(cfg config, logger *jsonlog.Logger, onStateChange func(breaker string, from, to data.CircuitBreakerState), onWrite data.WriteObserver) (StatisticsHandlerInterface, error) {
+	// Validate the circuit breaker policy before connecting
+	policy, err := data.ParseTripPolicy(cfg.circuitBreaker.policy)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := openPostgreSQLPool(cfg, cfg.db.maxConns)
+	if err != nil {
+		return nil, err
+	}
+
 	// Initialize repository with configurable timeout for FizzBuzz operations
 	repository := data.NewPostgreSQLStatisticsRepository(pool, cfg.db.operationTimeout, logger)
 
-	// Create circuit breaker repository for database resilience
-	cbRepository := data.NewCircuitBreakerRepository(repository, logger)
+	// Create circuit breaker repository for database resilience, with separate read and write breakers
+	cbRepository := data.NewCircuitBreakerRepositoryWithConfig(repository, data.CircuitBreakerConfig{
+		Policy:               policy,
+		FailureThreshold:     cfg.circuitBreaker.failureThreshold,
+		FailureRateThreshold: cfg.circuitBreaker.failureRate,
+		Window:               cfg.circuitBreaker.window,
+		MinimumRequests:      cfg.circuitBreaker.minRequests,
+		SlowCallThreshold:    cfg.circuitBreaker.slowCallThreshold,
+		RecoveryTimeout:      cfg.circuitBreaker.recoveryTimeout,
+		SuccessThreshold:     cfg.circuitBreaker.successThreshold,
+		MaxHalfOpenCalls:     cfg.circuitBreaker.halfOpenProbes,
+		Timeout:              cfg.circuitBreaker.timeout,
+		OnStateChange:        onStateChange,
+	}, logger)
+
+	// Spool writes to a local file while the write breaker is open, replaying them once it closes
+	if cfg.stats.spoolPath != "" {
+		spool, err := data.OpenSpool(cfg.stats.spoolPath, int64(cfg.stats.spoolMaxBytes), cfg.stats.spoolSync)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		cbRepository.SetSpool(spool)
+	}
+
+	// Take writes off the request path with a background batch writer unless disabled
+	var serviceRepository data.StatisticsRepository = cbRepository
+	if cfg.stats.bufferSize > 0 {
+		serviceRepository = data.NewBufferedStatisticsRepository(cbRepository, data.BufferedWriterConfig{
+			BatchSize:     cfg.stats.batchSize,
+			BufferSize:    cfg.stats.bufferSize,
+			FlushInterval: cfg.stats.flushInterval,
+			WriteTimeout:  cfg.db.operationTimeout,
+		}, logger)
+	}
 
 	// Create statistics service with circuit breaker protection
-	service := data.NewStatisticsService(cbRepository)
+	service := data.NewStatisticsService(serviceRepository)
+	service.SetWriteObserver(onWrite)
 
 	logger.Info("PostgreSQL statistics with circuit breaker initialized successfully",
 		"db_host", cfg.db.host,
@@ -262,51 +459,166 @@ func initializePostgreSQLStatistics(cfg config, logger *jsonlog.Logger) (Statist
 		"operation_timeout", cfg.db.operationTimeout,
 		"pool_health_check_period", cfg.db.healthCheckPeriod,
 		"monitoring_enabled", cfg.db.monitoringEnabled,
-		"circuit_breaker_enabled", true)
+		"circuit_breaker_enabled", true,
+		"circuit_breaker_policy", policy.String(),
+		"circuit_breaker_failure_threshold", cfg.circuitBreaker.failureThreshold,
+		"circuit_breaker_slow_call_threshold", cfg.circuitBreaker.slowCallThreshold,
+		"circuit_breaker_recovery_timeout", cfg.circuitBreaker.recoveryTimeout,
+		"background_writes_enabled", cfg.stats.bufferSize > 0,
+		"statistics_batch_size", cfg.stats.batchSize,
+		"background_write_buffer", cfg.stats.bufferSize,
+		"statistics_spool_path", cfg.stats.spoolPath)
 
 	return &statisticsHandler{
 		service: service,
 	}, nil
 }
 
+// initializeStatistics creates the statistics handler for the configured backend.
+// "postgres" requires a reachable database; "memory" keeps statistics in process memory
+// so the API can run without one (development, CI). onStateChange, if set, is called on
+// every transition of the database circuit breakers, and onWrite with the outcome of every
+// statistics write, including background and replayed ones.
+func initializeStatistics(cfg config, logger *jsonlog.Logger, onStateChange func(breaker string, from, to data.CircuitBreakerState), onWrite data.WriteObserver) (StatisticsHandlerInterface, error) {
+	switch cfg.stats.backend {
+	case "postgres":
+		return initializePostgreSQLStatistics(cfg, logger, onStateChange, onWrite)
+	case "memory":
+		logger.Info("in-memory statistics initialized, statistics will not persist across restarts")
+		service := data.NewStatisticsService(data.NewMemoryStatisticsRepository())
+		service.SetWriteObserver(onWrite)
+		return &statisticsHandler{service: service}, nil
+	default:
+		return nil, fmt.Errorf("unknown statistics backend %q (want postgres or memory)", cfg.stats.backend)
+	}
+}
+
+// minJWTSecretLength is the shortest accepted HS256 secret, the size of a SHA-256 output
+const minJWTSecretLength = 32
+
+// initializeAuthentication creates the API key authenticator for the "api-key" auth mode, with
+// keys looked up in PostgreSQL, or the bearer token authenticator for the "jwt" mode; both are
+// nil for "none", where every route is anonymous
+func initializeAuthentication(cfg config, logger *jsonlog.Logger) (*apiKeyAuthenticator, *jwtAuthenticator, error) {
+	switch cfg.auth.mode {
+	case "none":
+		return nil, nil, nil
+	case "api-key":
+		pool, err := openPostgreSQLPool(cfg, 4)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		logger.Info("API key authentication initialized",
+			"header", cfg.auth.apiKeyHeader,
+			"cache_ttl", cfg.auth.cacheTTL)
+		repository := data.NewPostgreSQLAPIKeyRepository(pool, cfg.db.operationTimeout)
+		return newAPIKeyAuthenticator(repository, cfg.auth.apiKeyHeader, cfg.auth.cacheTTL), nil, nil
+	case "jwt":
+		tokens, err := initializeJWTAuthentication(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		logger.Info("JWT authentication initialized",
+			"keys", tokens.validator.Keys.Len(),
+			"audience", cfg.auth.jwt.audience,
+			"issuer", cfg.auth.jwt.issuer,
+			"leeway", cfg.auth.jwt.leeway)
+		return nil, tokens, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown auth mode %q (want none, api-key or jwt)", cfg.auth.mode)
+	}
+}
+
+// initializeJWTAuthentication builds the bearer token authenticator from either the shared
+// secret or the JWKS file
+func initializeJWTAuthentication(cfg config) (*jwtAuthenticator, error) {
+	var keys *jwt.KeySet
+	switch {
+	case cfg.auth.jwt.secret != "" && cfg.auth.jwt.jwksFile != "":
+		return nil, errors.New("jwt auth mode takes either a secret or a JWKS file, not both")
+	case cfg.auth.jwt.secret != "":
+		if len(cfg.auth.jwt.secret) < minJWTSecretLength {
+			return nil, fmt.Errorf("jwt secret must be at least %d bytes", minJWTSecretLength)
+		}
+		keys = jwt.NewSecretKeySet([]byte(cfg.auth.jwt.secret))
+	case cfg.auth.jwt.jwksFile != "":
+		var err error
+		if keys, err = jwt.LoadJWKS(cfg.auth.jwt.jwksFile); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("jwt auth mode requires a secret or a JWKS file")
+	}
+
+	scopes, err := parseRouteScopes(cfg.auth.jwt.scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	validator := &jwt.Validator{
+		Keys:     keys,
+		Audience: cfg.auth.jwt.audience,
+		Issuer:   cfg.auth.jwt.issuer,
+		Leeway:   cfg.auth.jwt.leeway,
+	}
+	return newJWTAuthenticator(validator, scopes), nil
+}
+
+// initializeRateLimitStore connects the shared token bucket store for the "redis" rate limiter
+// store; it returns nil for "memory", where buckets stay in the rate limiter map. An unreachable
+// server is only logged: requests are let through until it comes back.
+func initializeRateLimitStore(cfg config, logger *jsonlog.Logger) (rateLimitStore, error) {
+	switch cfg.limiter.store {
+	case "memory":
+		return nil, nil
+	case "redis":
+		client := resp.NewClient(resp.Options{
+			Addr:     cfg.limiter.redis.addr,
+			Password: cfg.limiter.redis.password,
+			DB:       cfg.limiter.redis.db,
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := client.Ping(ctx); err != nil {
+			logger.Warn("rate limit store unreachable, requests are not limited until it answers",
+				"addr", cfg.limiter.redis.addr,
+				"error", err)
+		}
+
+		logger.Info("shared rate limit store initialized",
+			"addr", cfg.limiter.redis.addr,
+			"db", cfg.limiter.redis.db,
+			"prefix", cfg.limiter.redis.prefix)
+		return newRedisRateLimitStore(client, cfg.limiter.redis.prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limiter store %q (want memory or redis)", cfg.limiter.store)
+	}
+}
+
 // initializeRateLimiter creates a new rate limiter with cleanup goroutine
 func initializeRateLimiter(cfg config, logger *jsonlog.Logger) *rateLimiterMap {
 	// Create rate limiter map
-	rlm := newRateLimiterMap(cfg.limiter.rps, cfg.limiter.burst)
+	policy := cfg.limiter.policy
+	if policy == nil {
+		policy = defaultRateLimitPolicy(cfg.limiter.rps, cfg.limiter.burst)
+		policy.Cost.ElementsPerToken = cfg.limiter.costUnit
+	}
+	rlm := newRateLimiterMapWithPolicy(policy)
+
+	// Requests costing more than their bucket's burst are refused outright
+	if maxElements := policy.maxElements(); cfg.limiter.enabled && maxElements > 0 && maxElements < maxLimit {
+		logger.Warn("rate limit burst is too small for the largest requests, which will be refused",
+			"max_elements_per_request", maxElements,
+			"max_limit", maxLimit,
+			"elements_per_token", policy.Cost.ElementsPerToken)
+	}
 
 	// Start background cleanup goroutine if rate limiting is enabled
 	if cfg.limiter.enabled {
-		go func() {
-			defer close(rlm.done) // Signal completion when goroutine exits
-
-			cleanupInterval := 1 * time.Minute // Run cleanup every minute
-			maxAge := 1 * time.Hour            // Remove entries older than 1 hour
-			ticker := time.NewTicker(cleanupInterval)
-			defer ticker.Stop()
-
-			logger.Info("rate limiter cleanup goroutine started",
-				"cleanup_interval", cleanupInterval,
-				"max_age", maxAge)
-
-			for {
-				select {
-				case <-ticker.C:
-					deletedCount := rlm.cleanupOldEntries(maxAge)
-					totalEntries, rps, burst := rlm.getStats()
-
-					if deletedCount > 0 {
-						logger.Debug("rate limiter cleanup completed",
-							"deleted_entries", deletedCount,
-							"remaining_entries", totalEntries,
-							"rps_limit", rps,
-							"burst_limit", burst)
-					}
-				case <-rlm.shutdownCh:
-					logger.Info("rate limiter cleanup goroutine shutdown initiated")
-					return
-				}
-			}
-		}()
+		go cleanupRateLimiter(rlm, logger)
 	} else {
 		// If rate limiting is disabled, immediately close done channel
 		close(rlm.done)
@@ -314,12 +626,65 @@ func initializeRateLimiter(cfg config, logger *jsonlog.Logger) *rateLimiterMap {
 
 	logger.Info("rate limiter initialized",
 		"enabled", cfg.limiter.enabled,
-		"rps", cfg.limiter.rps,
-		"burst", cfg.limiter.burst)
+		"rps", float64(rlm.rps),
+		"burst", rlm.burst,
+		"key", policy.Key,
+		"tiers", len(policy.Tiers),
+		"cost_elements_per_token", policy.Cost.ElementsPerToken,
+		"policy_path", cfg.limiter.policyPath)
+
+	return rlm
+}
+
+// initializeAuthRateLimiter creates the per-IP limit on credential checks, or returns nil when
+// authentication or the limit is disabled. It always keeps its buckets in process memory.
+func initializeAuthRateLimiter(cfg config, logger *jsonlog.Logger) *rateLimiterMap {
+	if cfg.auth.mode == "none" || cfg.auth.limiter.rps <= 0 {
+		return nil
+	}
+
+	rlm := newRateLimiterMap(cfg.auth.limiter.rps, cfg.auth.limiter.burst)
+	go cleanupRateLimiter(rlm, logger)
 
+	logger.Info("authentication rate limiter initialized",
+		"rps", cfg.auth.limiter.rps,
+		"burst", cfg.auth.limiter.burst)
 	return rlm
 }
 
+// cleanupRateLimiter removes idle clients from rlm every minute until rlm is shut down
+func cleanupRateLimiter(rlm *rateLimiterMap, logger *jsonlog.Logger) {
+	defer close(rlm.done) // Signal completion when goroutine exits
+
+	cleanupInterval := 1 * time.Minute // Run cleanup every minute
+	maxAge := 1 * time.Hour            // Remove entries older than 1 hour
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+
+	logger.Info("rate limiter cleanup goroutine started",
+		"cleanup_interval", cleanupInterval,
+		"max_age", maxAge)
+
+	for {
+		select {
+		case <-ticker.C:
+			deletedCount := rlm.cleanupOldEntries(maxAge)
+			totalEntries, rps, burst := rlm.getStats()
+
+			if deletedCount > 0 {
+				logger.Debug("rate limiter cleanup completed",
+					"deleted_entries", deletedCount,
+					"remaining_entries", totalEntries,
+					"rps_limit", rps,
+					"burst_limit", burst)
+			}
+		case <-rlm.shutdownCh:
+			logger.Info("rate limiter cleanup goroutine shutdown initiated")
+			return
+		}
+	}
+}
+
 func main() {
 	var cfg config
 
@@ -328,10 +693,62 @@ func main() {
 	flag.StringVar(&cfg.env, "env", "development", "Environment (development|staging|production)")
 	flag.StringVar(&cfg.logLevel, "log-level", "info", "Log level (debug|info|warn|error)")
 
+	// Statistics backend flag
+	flag.StringVar(&cfg.stats.backend, "stats-backend", "postgres", "Statistics backend (postgres|memory)")
+	flag.IntVar(&cfg.stats.batchSize, "stats-batch-size", 100, "Maximum rows per background statistics upsert")
+	flag.IntVar(&cfg.stats.bufferSize, "stats-write-buffer", 1000, "Queued statistics hits before new hits are dropped (0 writes synchronously)")
+	flag.DurationVar(&cfg.stats.flushInterval, "stats-flush-interval", 1*time.Second, "Maximum time statistics wait in memory before being written")
+	flag.StringVar(&cfg.stats.spoolPath, "stats-spool-path", "", "File that keeps statistics writes during database outages (empty disables)")
+	flag.IntVar(&cfg.stats.spoolMaxBytes, "stats-spool-max-bytes", 64<<20, "Maximum size of the statistics spool file in bytes")
+	flag.DurationVar(&cfg.stats.spoolSync, "stats-spool-sync-interval", 0, "How often spooled statistics are synced to disk (0 syncs every write)")
+
+	// Circuit breaker flags, applied to both the read and the write breaker
+	flag.StringVar(&cfg.circuitBreaker.policy, "cb-policy", "consecutive", "Circuit breaker trip policy (consecutive|failure-rate)")
+	flag.IntVar(&cfg.circuitBreaker.failureThreshold, "cb-failure-threshold", 5, "Consecutive database failures before a circuit breaker opens")
+	flag.Float64Var(&cfg.circuitBreaker.failureRate, "cb-failure-rate", 0.5, "Failure ratio over the window that opens a circuit breaker (failure-rate policy)")
+	flag.DurationVar(&cfg.circuitBreaker.window, "cb-window", 60*time.Second, "Rolling window over which the failure rate is measured (failure-rate policy)")
+	flag.IntVar(&cfg.circuitBreaker.minRequests, "cb-min-requests", 10, "Calls the window must hold before the failure rate can open a circuit breaker")
+	flag.DurationVar(&cfg.circuitBreaker.slowCallThreshold, "cb-slow-call-threshold", 0, "Database calls at least this slow count as failures (0 disables)")
+	flag.DurationVar(&cfg.circuitBreaker.recoveryTimeout, "cb-recovery-timeout", 30*time.Second, "Time an open circuit breaker waits before probing the database")
+	flag.IntVar(&cfg.circuitBreaker.successThreshold, "cb-success-threshold", 3, "Successful probes needed to close a half-open circuit breaker")
+	flag.IntVar(&cfg.circuitBreaker.halfOpenProbes, "cb-half-open-probes", 1, "Probe calls a half-open circuit breaker lets through at once")
+	flag.DurationVar(&cfg.circuitBreaker.timeout, "cb-timeout", 5*time.Second, "Maximum duration of a database call made through a circuit breaker")
+
 	// Rate limiter flags
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiting")
 	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 2.0, "Rate limiter requests per second")
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst size")
+	flag.IntVar(&cfg.limiter.costUnit, "limiter-cost-unit", 1000, "FizzBuzz elements per rate limiter token (0 charges one token per request)")
+	flag.StringVar(&cfg.limiter.store, "limiter-store", "memory", "Where token buckets are kept (memory|redis); redis shares them between replicas")
+	flag.StringVar(&cfg.limiter.redis.addr, "limiter-redis-addr", "localhost:6379", "Redis-compatible server for the redis rate limiter store")
+	flag.IntVar(&cfg.limiter.redis.db, "limiter-redis-db", 0, "Database number for the redis rate limiter store")
+	flag.StringVar(&cfg.limiter.redis.prefix, "limiter-redis-prefix", "fizzbuzz:ratelimit:", "Key prefix for the redis rate limiter store")
+	flag.StringVar(&cfg.limiter.policyPath, "limiter-policy", "", "JSON rate limit policy with key extractor, tiers, per-route limits and cost (replaces -limiter-rps, -limiter-burst and -limiter-cost-unit)")
+
+	// Client IP resolution flags
+	flag.StringVar(&cfg.proxies.trusted, "trusted-proxies", "", "Comma-separated CIDRs of reverse proxies whose Forwarded/X-Forwarded-For headers are trusted")
+
+	// CORS flags
+	flag.StringVar(&cfg.cors.origins, "cors-allowed-origins", "", "Comma-separated origins browsers may call the API from, exact (https://app.example.com), wildcard (https://*.example.com) or * (empty disables CORS)")
+	flag.StringVar(&cfg.cors.methods, "cors-allowed-methods", "GET, POST", "Comma-separated methods allowed in cross-origin requests")
+	flag.StringVar(&cfg.cors.headers, "cors-allowed-headers", "Accept, Authorization, Content-Type, X-API-Key, X-Correlation-ID", "Comma-separated request headers allowed in cross-origin requests")
+	flag.DurationVar(&cfg.cors.maxAge, "cors-max-age", 10*time.Minute, "How long browsers may cache a preflight response (0: browser default)")
+
+	// Authentication flags
+	flag.StringVar(&cfg.auth.mode, "auth", "none", "Authentication required on all but health and metrics routes (none|api-key|jwt)")
+	flag.StringVar(&cfg.auth.apiKeyHeader, "auth-api-key-header", "X-API-Key", "Header holding the API key in api-key auth mode")
+	flag.DurationVar(&cfg.auth.cacheTTL, "auth-cache-ttl", 30*time.Second, "How long API key lookups are cached; revocations take up to this long to apply")
+	flag.Float64Var(&cfg.auth.limiter.rps, "auth-limiter-rps", 10, "Credential checks per second allowed from one client IP before authentication (0 disables)")
+	flag.IntVar(&cfg.auth.limiter.burst, "auth-limiter-burst", 20, "Burst of credential checks allowed from one client IP")
+	flag.StringVar(&cfg.auth.jwt.secret, "auth-jwt-secret", "", "HS256 secret verifying bearer tokens in jwt auth mode (at least 32 bytes)")
+	flag.StringVar(&cfg.auth.jwt.jwksFile, "auth-jwt-jwks-file", "", "JWKS file with the RS256, ES256 or HS256 keys verifying bearer tokens in jwt auth mode")
+	flag.StringVar(&cfg.auth.jwt.audience, "auth-jwt-audience", "", "Audience bearer tokens must list in their aud claim (empty: not checked)")
+	flag.StringVar(&cfg.auth.jwt.issuer, "auth-jwt-issuer", "", "Issuer bearer tokens must name in their iss claim (empty: not checked)")
+	flag.DurationVar(&cfg.auth.jwt.leeway, "auth-jwt-leeway", 30*time.Second, "Clock skew tolerated when checking the exp and nbf claims")
+	flag.StringVar(&cfg.auth.jwt.scopes, "auth-jwt-scopes", "", "Comma-separated route=scope pairs overriding the scope each route requires (empty scope: any valid token)")
+
+	// Health check flags
+	flag.BoolVar(&cfg.health.databaseCritical, "health-db-critical", false, "Report the API unready when the database is unavailable")
 
 	// Shutdown configuration flags
 	flag.DurationVar(&cfg.shutdown.timeout, "shutdown-timeout", 30*time.Second, "Maximum time to wait for graceful shutdown")
@@ -364,10 +781,63 @@ func main() {
 	cfg.db.healthCheckPeriod = getEnvDuration("DB_HEALTH_CHECK_PERIOD", cfg.db.healthCheckPeriod)
 	cfg.db.monitoringEnabled = getEnvBool("DB_MONITORING_ENABLED", cfg.db.monitoringEnabled)
 
+	// Statistics Configuration
+	cfg.stats.backend = getEnvString("STATS_BACKEND", cfg.stats.backend)
+	cfg.stats.batchSize = getEnvInt("STATISTICS_BATCH_SIZE", cfg.stats.batchSize)
+	cfg.stats.bufferSize = getEnvInt("BACKGROUND_WRITE_BUFFER", cfg.stats.bufferSize)
+	cfg.stats.flushInterval = getEnvDuration("STATISTICS_FLUSH_INTERVAL", cfg.stats.flushInterval)
+	cfg.stats.spoolPath = getEnvString("STATISTICS_SPOOL_PATH", cfg.stats.spoolPath)
+	cfg.stats.spoolMaxBytes = getEnvInt("STATISTICS_SPOOL_MAX_BYTES", cfg.stats.spoolMaxBytes)
+	cfg.stats.spoolSync = getEnvDuration("STATISTICS_SPOOL_SYNC_INTERVAL", cfg.stats.spoolSync)
+
+	// Circuit Breaker Configuration
+	cfg.circuitBreaker.policy = getEnvString("CIRCUIT_BREAKER_POLICY", cfg.circuitBreaker.policy)
+	cfg.circuitBreaker.failureThreshold = getEnvInt("CIRCUIT_BREAKER_FAILURE_THRESHOLD", cfg.circuitBreaker.failureThreshold)
+	cfg.circuitBreaker.failureRate = getEnvFloat("CIRCUIT_BREAKER_FAILURE_RATE", cfg.circuitBreaker.failureRate)
+	cfg.circuitBreaker.window = getEnvDuration("CIRCUIT_BREAKER_WINDOW", cfg.circuitBreaker.window)
+	cfg.circuitBreaker.minRequests = getEnvInt("CIRCUIT_BREAKER_MIN_REQUESTS", cfg.circuitBreaker.minRequests)
+	cfg.circuitBreaker.slowCallThreshold = getEnvDuration("CIRCUIT_BREAKER_SLOW_CALL_THRESHOLD", cfg.circuitBreaker.slowCallThreshold)
+	cfg.circuitBreaker.recoveryTimeout = getEnvDuration("CIRCUIT_BREAKER_RECOVERY_TIMEOUT", cfg.circuitBreaker.recoveryTimeout)
+	cfg.circuitBreaker.successThreshold = getEnvInt("CIRCUIT_BREAKER_SUCCESS_THRESHOLD", cfg.circuitBreaker.successThreshold)
+	cfg.circuitBreaker.halfOpenProbes = getEnvInt("CIRCUIT_BREAKER_HALF_OPEN_PROBES", cfg.circuitBreaker.halfOpenProbes)
+	cfg.circuitBreaker.timeout = getEnvDuration("CIRCUIT_BREAKER_TIMEOUT", cfg.circuitBreaker.timeout)
+
 	// Rate Limiter Configuration (use flag values as defaults)
 	cfg.limiter.enabled = getEnvBool("RATE_LIMITER_ENABLED", cfg.limiter.enabled)
 	cfg.limiter.rps = getEnvFloat("RATE_LIMITER_RPS", cfg.limiter.rps)
 	cfg.limiter.burst = getEnvInt("RATE_LIMITER_BURST", cfg.limiter.burst)
+	cfg.limiter.costUnit = getEnvInt("RATE_LIMITER_COST_UNIT", cfg.limiter.costUnit)
+	cfg.limiter.policyPath = getEnvString("RATE_LIMITER_POLICY", cfg.limiter.policyPath)
+	cfg.limiter.store = getEnvString("RATE_LIMITER_STORE", cfg.limiter.store)
+	cfg.limiter.redis.addr = getEnvString("RATE_LIMITER_REDIS_ADDR", cfg.limiter.redis.addr)
+	cfg.limiter.redis.password = getEnvString("RATE_LIMITER_REDIS_PASSWORD", cfg.limiter.redis.password)
+	cfg.limiter.redis.db = getEnvInt("RATE_LIMITER_REDIS_DB", cfg.limiter.redis.db)
+	cfg.limiter.redis.prefix = getEnvString("RATE_LIMITER_REDIS_PREFIX", cfg.limiter.redis.prefix)
+
+	// Client IP Configuration
+	cfg.proxies.trusted = getEnvString("TRUSTED_PROXIES", cfg.proxies.trusted)
+
+	// CORS Configuration
+	cfg.cors.origins = getEnvString("CORS_ALLOWED_ORIGINS", cfg.cors.origins)
+	cfg.cors.methods = getEnvString("CORS_ALLOWED_METHODS", cfg.cors.methods)
+	cfg.cors.headers = getEnvString("CORS_ALLOWED_HEADERS", cfg.cors.headers)
+	cfg.cors.maxAge = getEnvDuration("CORS_MAX_AGE", cfg.cors.maxAge)
+
+	// Authentication Configuration
+	cfg.auth.mode = getEnvString("AUTH_MODE", cfg.auth.mode)
+	cfg.auth.apiKeyHeader = getEnvString("AUTH_API_KEY_HEADER", cfg.auth.apiKeyHeader)
+	cfg.auth.cacheTTL = getEnvDuration("AUTH_CACHE_TTL", cfg.auth.cacheTTL)
+	cfg.auth.limiter.rps = getEnvFloat("AUTH_LIMITER_RPS", cfg.auth.limiter.rps)
+	cfg.auth.limiter.burst = getEnvInt("AUTH_LIMITER_BURST", cfg.auth.limiter.burst)
+	cfg.auth.jwt.secret = getEnvString("AUTH_JWT_SECRET", cfg.auth.jwt.secret)
+	cfg.auth.jwt.jwksFile = getEnvString("AUTH_JWT_JWKS_FILE", cfg.auth.jwt.jwksFile)
+	cfg.auth.jwt.audience = getEnvString("AUTH_JWT_AUDIENCE", cfg.auth.jwt.audience)
+	cfg.auth.jwt.issuer = getEnvString("AUTH_JWT_ISSUER", cfg.auth.jwt.issuer)
+	cfg.auth.jwt.leeway = getEnvDuration("AUTH_JWT_LEEWAY", cfg.auth.jwt.leeway)
+	cfg.auth.jwt.scopes = getEnvString("AUTH_JWT_SCOPES", cfg.auth.jwt.scopes)
+
+	// Health Check Configuration
+	cfg.health.databaseCritical = getEnvBool("HEALTH_DB_CRITICAL", cfg.health.databaseCritical)
 
 	// Shutdown Configuration
 	cfg.shutdown.timeout = getEnvDuration("SHUTDOWN_TIMEOUT", cfg.shutdown.timeout)
@@ -389,12 +859,58 @@ func main() {
 
 	logger := jsonlog.New(os.Stdout, level, cfg.env)
 
-	// Story 4.6: Initialize PostgreSQL Statistics with Connection Pooling
-	// Direct PostgreSQL access approach with proper connection pooling and context-aware operations
-	statsHandler, err := initializePostgreSQLStatistics(cfg, logger)
+	// Load the rate limit policy before anything starts so a bad file fails fast
+	if cfg.limiter.policyPath != "" {
+		policy, err := loadRateLimitPolicy(cfg.limiter.policyPath)
+		if err != nil {
+			logger.Error("failed to load rate limit policy, terminating application", "error", err)
+			os.Exit(1)
+		}
+		cfg.limiter.policy = policy
+	}
+
+	rateLimitStore, err := initializeRateLimitStore(cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize rate limit store, terminating application", "error", err)
+		os.Exit(1)
+	}
+
+	clientIPs, err := newClientIPResolver(cfg.proxies.trusted)
+	if err != nil {
+		logger.Error("failed to parse trusted proxies, terminating application", "error", err)
+		os.Exit(1)
+	}
+
+	corsPolicy, err := newCORSPolicy(cfg.cors.origins, cfg.cors.methods, cfg.cors.headers, cfg.cors.maxAge)
+	if err != nil {
+		logger.Error("failed to parse CORS configuration, terminating application", "error", err)
+		os.Exit(1)
+	}
+
+	apiKeys, tokens, err := initializeAuthentication(cfg, logger)
+	if err != nil {
+		logger.Error("failed to initialize authentication, terminating application", "error", err, "auth_mode", cfg.auth.mode)
+		os.Exit(1)
+	}
+
+	// Metrics read the statistics handler and rate limiter at scrape time, so they can be
+	// registered first and count circuit breaker transitions from the very first request
+	app := &application{
+		config:     cfg,
+		logger:     logger,
+		clientIPs:  clientIPs,
+		corsPolicy: corsPolicy,
+		apiKeys:    apiKeys,
+		tokens:     tokens,
+	}
+	app.metrics = newAPIMetrics(app)
+
+	// Story 4.6: Initialize Statistics (PostgreSQL with connection pooling, or in-memory)
+	statsHandler, err := initializeStatistics(cfg, logger, app.circuitBreakerStateChanged, app.statisticsWritten)
 	if err != nil {
-		logger.Error("failed to initialize PostgreSQL statistics, terminating application",
+		logger.Error("failed to initialize statistics, terminating application",
 			"error", err,
+			"stats_backend", cfg.stats.backend,
 			"db_host", cfg.db.host,
 			"db_port", cfg.db.port)
 		os.Exit(1)
@@ -402,14 +918,15 @@ func main() {
 
 	// Story 5.2: Initialize Rate Limiter with IP-based controls
 	rateLimiter := initializeRateLimiter(cfg, logger)
-
-	app := &application{
-		config:      cfg,
-		logger:      logger,
-		statistics:  statsHandler,
-		rateLimiter: rateLimiter,
+	if rateLimitStore != nil {
+		rateLimiter.store = rateLimitStore
 	}
 
+	app.statistics = statsHandler
+	app.rateLimiter = rateLimiter
+	app.authLimiter = initializeAuthRateLimiter(cfg, logger)
+	app.health = newHealthRegistry(app, cfg.health.databaseCritical)
+
 	srv := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.port),
 		Handler:      app.routes(),
@@ -470,9 +987,35 @@ func main() {
 			app.rateLimiter.shutdown()
 			app.rateLimiter.waitForShutdown()
 			logger.Info("rate limiter cleanup goroutine terminated")
+
+			if err := app.rateLimiter.store.close(); err != nil {
+				logger.Error("failed to close rate limit store", "error", err)
+			}
+		}
+		if app.authLimiter != nil {
+			app.authLimiter.shutdown()
+			app.authLimiter.waitForShutdown()
+		}
+
+		if app.apiKeys != nil {
+			if err := app.apiKeys.repository.Close(); err != nil {
+				logger.Error("failed to close API key store", "error", err)
+			}
+		}
+
+		// Step 3: Drain statistics buffered by the background writer
+		if app.statistics != nil {
+			logger.Info("flushing buffered statistics")
+			err := app.statistics.Flush(ctx)
+			if err != nil {
+				// Buffered hits are best-effort; keep shutting down so connections still close
+				logger.Error("failed to flush buffered statistics", "error", err)
+			} else {
+				logger.Info("buffered statistics flushed successfully")
+			}
 		}
 
-		// Step 3: Close database connections
+		// Step 4: Close database connections
 		if app.statistics != nil {
 			logger.Info("closing database connections")
 			err := app.statistics.Close()
@@ -500,8 +1043,10 @@ func main() {
 		"db_port", cfg.db.port,
 		"db_name", cfg.db.name,
 		"db_ssl_mode", cfg.db.sslMode,
+		"stats_backend", cfg.stats.backend,
 		"rate_limiter_enabled", cfg.limiter.enabled,
 		"rate_limiter_rps", cfg.limiter.rps,
+		"auth_mode", cfg.auth.mode,
 		"shutdown_timeout", cfg.shutdown.timeout)
 
 	listenErr := srv.ListenAndServe()
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"testing"
+
+	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/jsonlog"
 )
 
 func TestVersionVariables(t *testing.T) {
@@ -41,3 +45,83 @@ func TestVersionVariables(t *testing.T) {
 		buildTime = originalBuildTime
 	})
 }
+
+func TestInitializeStatistics(t *testing.T) {
+	logger := jsonlog.New(nil, jsonlog.LevelError, "test")
+
+	t.Run("memory backend needs no database", func(t *testing.T) {
+		var cfg config
+		cfg.stats.backend = "memory"
+
+		handler, err := initializeStatistics(cfg, logger, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer handler.Close()
+
+		input := &data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+		if err := handler.Record(context.Background(), input, ""); err != nil {
+			t.Fatalf("record failed: %v", err)
+		}
+
+		most, err := handler.GetMostFrequent(context.Background())
+		if err != nil || most == nil || most.Hits != 1 {
+			t.Errorf("expected one recorded hit, got %+v, %v", most, err)
+		}
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		var cfg config
+		cfg.stats.backend = "sqlite"
+
+		if _, err := initializeStatistics(cfg, logger, nil, nil); err == nil {
+			t.Error("expected error for unknown backend")
+		}
+	})
+
+	t.Run("unknown circuit breaker policy", func(t *testing.T) {
+		var cfg config
+		cfg.stats.backend = "postgres"
+		cfg.circuitBreaker.policy = "sometimes"
+
+		// Rejected before any connection attempt
+		if _, err := initializeStatistics(cfg, logger, nil, nil); err == nil {
+			t.Error("expected error for unknown circuit breaker policy")
+		}
+	})
+}
+
+func TestInitializeJWTAuthentication(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		jwksFile string
+		scopes   string
+		wantErr  bool
+	}{
+		{name: "secret", secret: testJWTSecret},
+		{name: "no key", wantErr: true},
+		{name: "short secret", secret: "too short", wantErr: true},
+		{name: "secret and JWKS file", secret: testJWTSecret, jwksFile: "jwks.json", wantErr: true},
+		{name: "missing JWKS file", jwksFile: "does-not-exist.json", wantErr: true},
+		{name: "invalid scopes", secret: testJWTSecret, scopes: "fizzbuzz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config
+			cfg.auth.mode = "jwt"
+			cfg.auth.jwt.secret = tt.secret
+			cfg.auth.jwt.jwksFile = tt.jwksFile
+			cfg.auth.jwt.scopes = tt.scopes
+
+			tokens, err := initializeJWTAuthentication(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && tokens.scopes["/v1/statistics"] != "statistics:read" {
+				t.Errorf("expected the default scopes, got %v", tokens.scopes)
+			}
+		})
+	}
+}
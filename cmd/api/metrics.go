@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fizzbuzz/internal/data"
+	"fizzbuzz/internal/metrics"
+	"github.com/julienschmidt/httprouter"
+)
+
+// apiMetrics holds the metric families exported on /metrics.
+// Request and write metrics are updated as they happen; pool, circuit breaker and
+// rate limiter metrics are read from their sources on every scrape.
+type apiMetrics struct {
+	registry         *metrics.Registry
+	httpRequests     *metrics.CounterVec
+	httpDuration     *metrics.HistogramVec
+	statisticsWrites *metrics.CounterVec
+	breakerChanges   *metrics.CounterVec
+	authFailures     *metrics.CounterVec
+}
+
+// circuitBreakerReporter is implemented by statistics handlers whose repository is protected
+// by circuit breakers. ok is false when there is no breaker (e.g. the in-memory backend).
+type circuitBreakerReporter interface {
+	GetCircuitBreakerStats() (stats data.CircuitBreakerRepositoryStats, ok bool)
+}
+
+// spoolReporter is implemented by statistics handlers that spool writes during database outages.
+// ok is false when no spool is configured.
+type spoolReporter interface {
+	GetSpoolStats() (stats data.SpoolStats, ok bool)
+}
+
+// newAPIMetrics registers the API's metric families, reading live state from app at scrape time
+func newAPIMetrics(app *application) *apiMetrics {
+	registry := metrics.NewRegistry()
+
+	m := &apiMetrics{
+		registry: registry,
+		httpRequests: registry.NewCounterVec("fizzbuzz_http_requests_total",
+			"Total HTTP requests by method, route and status code.", "method", "route", "status"),
+		httpDuration: registry.NewHistogramVec("fizzbuzz_http_request_duration_seconds",
+			"HTTP request latency in seconds by method and route.", metrics.DefBuckets, "method", "route"),
+		statisticsWrites: registry.NewCounterVec("fizzbuzz_statistics_writes_total",
+			"Statistics hits by write result (success, spooled or failure).", "result"),
+		breakerChanges: registry.NewCounterVec("fizzbuzz_circuit_breaker_transitions_total",
+			"Database circuit breaker state transitions by breaker (read or write).", "breaker", "from", "to"),
+		authFailures: registry.NewCounterVec("fizzbuzz_auth_failures_total",
+			"Requests rejected by authentication by reason (missing, invalid, revoked, expired or insufficient_scope).", "reason"),
+	}
+
+	// Connection pool metrics from a single GetPoolStats snapshot per scrape
+	registry.NewGaugeCollector([]metrics.GaugeDesc{
+		{Name: "fizzbuzz_db_pool_connections", Help: "Database connection pool connections by state.", Labels: []string{"state"}},
+		{Name: "fizzbuzz_db_pool_average_acquire_duration_seconds", Help: "Average time to acquire a database connection in seconds."},
+		{Name: "fizzbuzz_db_pool_status", Help: "Database connection pool status; 1 for the current status.", Labels: []string{"status"}},
+	}, func() [][]metrics.Sample {
+		if app.statistics == nil {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+
+		stats, err := app.statistics.GetPoolStats(ctx)
+		if err != nil || stats == nil {
+			return nil
+		}
+		return [][]metrics.Sample{
+			{
+				{LabelValues: []string{"total"}, Value: float64(stats.TotalConnections)},
+				{LabelValues: []string{"idle"}, Value: float64(stats.IdleConnections)},
+				{LabelValues: []string{"active"}, Value: float64(stats.ActiveConnections)},
+				{LabelValues: []string{"constructing"}, Value: float64(stats.ConstructingConnections)},
+				{LabelValues: []string{"max"}, Value: float64(stats.MaxConnections)},
+			},
+			{{Value: stats.AverageAcquireDurationMs / 1000}},
+			{{LabelValues: []string{stats.Status}, Value: 1}},
+		}
+	})
+
+	// Circuit breaker metrics from CircuitBreaker.GetStats
+	circuitBreakerSamples := func(value func(data.CircuitBreakerStats) float64) []metrics.Sample {
+		reporter, ok := app.statistics.(circuitBreakerReporter)
+		if !ok {
+			return nil
+		}
+		stats, ok := reporter.GetCircuitBreakerStats()
+		if !ok {
+			return nil
+		}
+		return []metrics.Sample{
+			{LabelValues: []string{"read"}, Value: value(stats.Read)},
+			{LabelValues: []string{"write"}, Value: value(stats.Write)},
+		}
+	}
+
+	registry.NewGaugeFunc("fizzbuzz_circuit_breaker_state",
+		"Database circuit breaker state (0 closed, 1 open, 2 half-open).", []string{"breaker"}, func() []metrics.Sample {
+			return circuitBreakerSamples(func(stats data.CircuitBreakerStats) float64 { return float64(stats.State) })
+		})
+
+	registry.NewGaugeFunc("fizzbuzz_circuit_breaker_consecutive_failures",
+		"Consecutive failures counted by the database circuit breaker.", []string{"breaker"}, func() []metrics.Sample {
+			return circuitBreakerSamples(func(stats data.CircuitBreakerStats) float64 { return float64(stats.Failures) })
+		})
+
+	// Outage spool metrics from Spool.Stats
+	registry.NewGaugeFunc("fizzbuzz_statistics_spool_depth",
+		"Statistics hits spooled locally while the database write breaker is open.", nil, func() []metrics.Sample {
+			reporter, ok := app.statistics.(spoolReporter)
+			if !ok {
+				return nil
+			}
+			stats, ok := reporter.GetSpoolStats()
+			if !ok {
+				return nil
+			}
+			return []metrics.Sample{{Value: float64(stats.Depth)}}
+		})
+
+	// Rate limiter metrics from rateLimiterMap
+	registry.NewCounterFunc("fizzbuzz_rate_limit_rejections_total",
+		"Requests rejected by the rate limiter.", nil, func() []metrics.Sample {
+			if app.rateLimiter == nil {
+				return nil
+			}
+			return []metrics.Sample{{Value: float64(app.rateLimiter.rejected.Load())}}
+		})
+
+	registry.NewCounterFunc("fizzbuzz_auth_rate_limit_rejections_total",
+		"Requests rejected by the per-IP limit on credential checks, before authentication.", nil, func() []metrics.Sample {
+			if app.authLimiter == nil {
+				return nil
+			}
+			return []metrics.Sample{{Value: float64(app.authLimiter.rejected.Load())}}
+		})
+
+	registry.NewCounterFunc("fizzbuzz_rate_limiter_store_errors_total",
+		"Rate limit store calls that failed; the requests were let through.", nil, func() []metrics.Sample {
+			if app.rateLimiter == nil {
+				return nil
+			}
+			return []metrics.Sample{{Value: float64(app.rateLimiter.storeErrors.Load())}}
+		})
+
+	registry.NewGaugeFunc("fizzbuzz_rate_limiter_clients",
+		"Clients currently tracked by the rate limiter.", nil, func() []metrics.Sample {
+			if app.rateLimiter == nil {
+				return nil
+			}
+			entries, _, _ := app.rateLimiter.getStats()
+			return []metrics.Sample{{Value: float64(entries)}}
+		})
+
+	return m
+}
+
+// metricsHandler handles GET requests to the /metrics endpoint in Prometheus text format
+func (app *application) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if app.metrics == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+	app.metrics.registry.Handler().ServeHTTP(w, r)
+}
+
+// recordMetrics middleware counts requests and observes their latency by route and status.
+// Paths the router does not know are reported as route "unmatched" to bound label cardinality.
+func (app *application) recordMetrics(router *httprouter.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.metrics == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+
+			next.ServeHTTP(rr, r)
+
+			route := routeLabel(router, r.URL.Path)
+			app.metrics.httpRequests.Inc(r.Method, route, strconv.Itoa(rr.statusCode))
+			app.metrics.httpDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+		})
+	}
+}
+
+// routeLabel returns path when the router has a handler for it under any method, else "unmatched"
+func routeLabel(router *httprouter.Router, path string) string {
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if handle, _, _ := router.Lookup(method, path); handle != nil {
+			return path
+		}
+	}
+	return "unmatched"
+}
+
+// statisticsWritten is the statistics service's write observer. Counts hits by outcome where it
+// is decided, so hits flushed in the background, spooled, replayed or dropped are all counted.
+func (app *application) statisticsWritten(result string, hits int) {
+	if app.metrics == nil {
+		return
+	}
+	app.metrics.statisticsWrites.Add(float64(hits), result)
+}
+
+// circuitBreakerStateChanged is the statistics circuit breakers' OnStateChange hook.
+// Logs every transition and counts it by breaker, previous and new state.
+func (app *application) circuitBreakerStateChanged(breaker string, from, to data.CircuitBreakerState) {
+	if to == data.CircuitOpen {
+		app.logger.Warn("circuit breaker opened", "breaker", breaker, "from", from.String(), "to", to.String())
+	} else {
+		app.logger.Info("circuit breaker state changed", "breaker", breaker, "from", from.String(), "to", to.String())
+	}
+
+	if app.metrics != nil {
+		app.metrics.breakerChanges.Inc(breaker, from.String(), to.String())
+	}
+}
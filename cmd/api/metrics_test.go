@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fizzbuzz/internal/data"
+)
+
+// scrapeMetrics serves GET /metrics through the full middleware chain and returns the body
+func scrapeMetrics(t *testing.T, handler http.Handler) string {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	return rr.Body.String()
+}
+
+func TestMetricsHandler(t *testing.T) {
+	t.Run("request and statistics metrics", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.metrics = newAPIMetrics(app)
+		app.statistics.(*statisticsHandler).service.SetWriteObserver(app.statisticsWritten)
+		handler := app.routes()
+
+		requests := []*http.Request{
+			httptest.NewRequest(http.MethodPost, "/v1/fizzbuzz", strings.NewReader(`{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`)),
+			httptest.NewRequest(http.MethodPost, "/v1/fizzbuzz", strings.NewReader(`{"int1":0,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`)),
+			httptest.NewRequest(http.MethodGet, "/v1/unknown/path-123", nil),
+		}
+		for _, req := range requests {
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		body := scrapeMetrics(t, handler)
+		for _, want := range []string{
+			`fizzbuzz_http_requests_total{method="POST",route="/v1/fizzbuzz",status="200"} 1`,
+			`fizzbuzz_http_requests_total{method="POST",route="/v1/fizzbuzz",status="422"} 1`,
+			`fizzbuzz_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+			`fizzbuzz_http_request_duration_seconds_count{method="POST",route="/v1/fizzbuzz"} 2`,
+			`fizzbuzz_statistics_writes_total{result="success"} 1`,
+			`fizzbuzz_db_pool_connections{state="max"}`,
+			"# TYPE fizzbuzz_rate_limit_rejections_total counter",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected metrics to contain %q\n%s", want, body)
+			}
+		}
+
+		// No circuit breaker wraps the mock repository
+		if strings.Contains(body, "\nfizzbuzz_circuit_breaker_state{") {
+			t.Errorf("unexpected circuit breaker sample without a breaker\n%s", body)
+		}
+	})
+
+	t.Run("statistics write failures", func(t *testing.T) {
+		app := newTestApplication(t)
+		service := data.NewStatisticsService(&mockFailingRepository{})
+		app.statistics = &statisticsHandler{service: service}
+		app.metrics = newAPIMetrics(app)
+		service.SetWriteObserver(app.statisticsWritten)
+		handler := app.routes()
+
+		req := httptest.NewRequest(http.MethodPost, "/v1/fizzbuzz", strings.NewReader(`{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if body := scrapeMetrics(t, handler); !strings.Contains(body, `fizzbuzz_statistics_writes_total{result="failure"} 1`) {
+			t.Errorf("expected failed write to be counted\n%s", body)
+		}
+	})
+
+	t.Run("background statistics writes", func(t *testing.T) {
+		app := newTestApplication(t)
+		buffered := data.NewBufferedStatisticsRepository(newMockRepository(), data.BufferedWriterConfig{BatchSize: 100, BufferSize: 100, FlushInterval: time.Hour}, nil)
+		service := data.NewStatisticsService(buffered)
+		app.statistics = &statisticsHandler{service: service}
+		app.metrics = newAPIMetrics(app)
+		service.SetWriteObserver(app.statisticsWritten)
+		handler := app.routes()
+
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/v1/fizzbuzz", strings.NewReader(`{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`))
+			req.Header.Set("Content-Type", "application/json")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		// Queued hits are not written yet
+		if body := scrapeMetrics(t, handler); strings.Contains(body, "\nfizzbuzz_statistics_writes_total{") {
+			t.Errorf("expected no writes before the flush\n%s", body)
+		}
+
+		// Hits rejected after close are dropped
+		buffered.Close()
+		req := httptest.NewRequest(http.MethodPost, "/v1/fizzbuzz", strings.NewReader(`{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`))
+		req.Header.Set("Content-Type", "application/json")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		body := scrapeMetrics(t, handler)
+		for _, want := range []string{
+			`fizzbuzz_statistics_writes_total{result="success"} 3`,
+			`fizzbuzz_statistics_writes_total{result="failure"} 1`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected metrics to contain %q\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("circuit breaker state", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.metrics = newAPIMetrics(app)
+		cbRepo := data.NewCircuitBreakerRepositoryWithConfig(&mockFailingRepository{},
+			data.CircuitBreakerConfig{OnStateChange: app.circuitBreakerStateChanged}, app.logger)
+		app.statistics = &statisticsHandler{service: data.NewStatisticsService(cbRepo)}
+
+		for i := 0; i < 6; i++ {
+			cbRepo.Record(context.Background(), data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"})
+		}
+
+		body := scrapeMetrics(t, app.routes())
+		for _, want := range []string{
+			`fizzbuzz_circuit_breaker_state{breaker="read"} 0`,
+			`fizzbuzz_circuit_breaker_state{breaker="write"} 1`,
+			`fizzbuzz_circuit_breaker_transitions_total{breaker="write",from="closed",to="open"} 1`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected metrics to contain %q\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("rate limit rejections", func(t *testing.T) {
+		app := newTestApplication(t)
+		app.config.limiter.enabled = true
+		app.rateLimiter = newRateLimiterMap(1, 1)
+		app.metrics = newAPIMetrics(app)
+		handler := app.routes()
+
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/v1/statistics", nil)
+			req.RemoteAddr = "198.51.100.7:1234"
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		body := scrapeMetrics(t, handler)
+		for _, want := range []string{
+			"fizzbuzz_rate_limit_rejections_total 2\n",
+			"fizzbuzz_rate_limiter_clients 2\n", // the client above plus the scrape itself
+			`fizzbuzz_http_requests_total{method="GET",route="/v1/statistics",status="429"} 2`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected metrics to contain %q\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		app := newTestApplication(t)
+
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("expected status %d without metrics, got %d", http.StatusNotFound, rr.Code)
+		}
+	})
+}
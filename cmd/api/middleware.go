@@ -3,55 +3,76 @@ package main
 import (
 	"context"
 	"fmt"
-	"net"
 	"net/http"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
 	"golang.org/x/time/rate"
 )
 
-// ipLimiter holds rate limiter and last seen time for an IP address
-type ipLimiter struct {
+// clientLimiter holds the token bucket and last seen time for one rate limit bucket
+type clientLimiter struct {
 	limiter  *rate.Limiter
 	lastSeen time.Time
 }
 
-// rateLimiterMap holds per-IP rate limiters with thread-safe access
+// rateLimiterMap holds per-client token buckets with thread-safe access. The policy decides
+// which bucket a request draws from; rps and burst are those of the policy's default tier.
+// Buckets live in the map itself unless store is replaced by a shared store.
 type rateLimiterMap struct {
-	mu         sync.RWMutex
-	limiters   map[string]*ipLimiter
-	rps        rate.Limit
-	burst      int
-	shutdownCh chan struct{} // Channel to signal shutdown to cleanup goroutine
-	done       chan struct{} // Channel to signal cleanup goroutine has terminated
+	mu          sync.RWMutex
+	limiters    map[string]*clientLimiter
+	policy      *rateLimitPolicy
+	store       rateLimitStore // The map itself, or a store shared between replicas
+	rps         rate.Limit
+	burst       int
+	shutdownCh  chan struct{} // Channel to signal shutdown to cleanup goroutine
+	done        chan struct{} // Channel to signal cleanup goroutine has terminated
+	rejected    atomic.Uint64 // Requests rejected since startup, exported on /metrics
+	storeErrors atomic.Uint64 // Failed store calls since startup, exported on /metrics
 }
 
-// newRateLimiterMap creates a new rate limiter map with the specified rate and burst
+// newRateLimiterMap creates a new rate limiter map limiting every client IP to rps and burst
 func newRateLimiterMap(rps float64, burst int) *rateLimiterMap {
-	return &rateLimiterMap{
-		limiters:   make(map[string]*ipLimiter),
-		rps:        rate.Limit(rps),
-		burst:      burst,
+	return newRateLimiterMapWithPolicy(defaultRateLimitPolicy(rps, burst))
+}
+
+// newRateLimiterMapWithPolicy creates a new rate limiter map enforcing a validated policy
+func newRateLimiterMapWithPolicy(policy *rateLimitPolicy) *rateLimiterMap {
+	defaultLimit := policy.Tiers[policy.DefaultTier].rateLimit
+	rlm := &rateLimiterMap{
+		limiters:   make(map[string]*clientLimiter),
+		policy:     policy,
+		rps:        rate.Limit(defaultLimit.RPS),
+		burst:      defaultLimit.Burst,
 		shutdownCh: make(chan struct{}),
 		done:       make(chan struct{}),
 	}
+	rlm.store = rlm
+	return rlm
+}
+
+// getLimiter returns the default tier's rate limiter for the given key, creating one if it doesn't exist
+func (rlm *rateLimiterMap) getLimiter(key string) *rate.Limiter {
+	return rlm.getLimiterFor(key, rateLimit{RPS: float64(rlm.rps), Burst: rlm.burst})
 }
 
-// getLimiter returns the rate limiter for the given IP, creating one if it doesn't exist
-func (rlm *rateLimiterMap) getLimiter(ip string) *rate.Limiter {
+// getLimiterFor returns the rate limiter for the given bucket key, creating one sized by limit
+// if it doesn't exist
+func (rlm *rateLimiterMap) getLimiterFor(key string, limit rateLimit) *rate.Limiter {
 	rlm.mu.Lock()
 	defer rlm.mu.Unlock()
 
-	limiter, exists := rlm.limiters[ip]
+	limiter, exists := rlm.limiters[key]
 	if !exists {
-		limiter = &ipLimiter{
-			limiter:  rate.NewLimiter(rlm.rps, rlm.burst),
+		limiter = &clientLimiter{
+			limiter:  rate.NewLimiter(rate.Limit(limit.RPS), limit.Burst),
 			lastSeen: time.Now(),
 		}
-		rlm.limiters[ip] = limiter
+		rlm.limiters[key] = limiter
 	} else {
 		limiter.lastSeen = time.Now()
 	}
@@ -59,7 +80,47 @@ func (rlm *rateLimiterMap) getLimiter(ip string) *rate.Limiter {
 	return limiter.limiter
 }
 
-// cleanupOldEntries removes IP entries that haven't been seen for the specified duration
+// take implements rateLimitStore with the buckets held in the map, using the limiter's own
+// reservation to tell how long a rejected request has to wait
+func (rlm *rateLimiterMap) take(ctx context.Context, key string, limit rateLimit, n int) (bucketResult, error) {
+	limiter := rlm.getLimiterFor(key, limit)
+	now := time.Now()
+	result := bucketResult{allowed: true}
+
+	reservation := limiter.ReserveN(now, n)
+	if !reservation.OK() {
+		// More than the burst: never satisfiable, callers cap costs to avoid this
+		result.allowed = false
+		result.retryAfter = time.Duration(float64(n) / limit.RPS * float64(time.Second))
+	} else if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		result.allowed = false
+		result.retryAfter = delay
+	}
+
+	result.state = newQuotaState(limiter.TokensAt(now), limit)
+	return result, nil
+}
+
+// peek implements rateLimitStore; a bucket not in the map has not been used and is full
+func (rlm *rateLimiterMap) peek(ctx context.Context, key string, limit rateLimit) (quotaState, error) {
+	if limiter := rlm.peekLimiter(key); limiter != nil {
+		return newQuotaState(limiter.TokensAt(time.Now()), limit), nil
+	}
+	return newQuotaState(float64(limit.Burst), limit), nil
+}
+
+// ping implements rateLimitStore; the map is always reachable
+func (rlm *rateLimiterMap) ping(ctx context.Context) error {
+	return nil
+}
+
+// close implements rateLimitStore; the cleanup goroutine is stopped by shutdown
+func (rlm *rateLimiterMap) close() error {
+	return nil
+}
+
+// cleanupOldEntries removes buckets that haven't been used for the specified duration
 func (rlm *rateLimiterMap) cleanupOldEntries(maxAge time.Duration) int {
 	rlm.mu.Lock()
 	defer rlm.mu.Unlock()
@@ -67,9 +128,9 @@ func (rlm *rateLimiterMap) cleanupOldEntries(maxAge time.Duration) int {
 	cutoff := time.Now().Add(-maxAge)
 	var deletedCount int
 
-	for ip, limiter := range rlm.limiters {
+	for key, limiter := range rlm.limiters {
 		if limiter.lastSeen.Before(cutoff) {
-			delete(rlm.limiters, ip)
+			delete(rlm.limiters, key)
 			deletedCount++
 		}
 	}
@@ -77,6 +138,18 @@ func (rlm *rateLimiterMap) cleanupOldEntries(maxAge time.Duration) int {
 	return deletedCount
 }
 
+// peekLimiter returns the rate limiter for the given bucket key without creating it or
+// refreshing its last seen time; nil means the bucket is unused, and so full
+func (rlm *rateLimiterMap) peekLimiter(key string) *rate.Limiter {
+	rlm.mu.RLock()
+	defer rlm.mu.RUnlock()
+
+	if limiter, exists := rlm.limiters[key]; exists {
+		return limiter.limiter
+	}
+	return nil
+}
+
 // getStats returns statistics about the rate limiter map
 func (rlm *rateLimiterMap) getStats() (totalEntries int, rps float64, burst int) {
 	rlm.mu.RLock()
@@ -95,46 +168,16 @@ func (rlm *rateLimiterMap) waitForShutdown() {
 	<-rlm.done
 }
 
-// getClientIP extracts the real client IP from the request
-// Handles X-Forwarded-For headers properly for proxied requests
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header first (comma-separated list)
-	xForwardedFor := r.Header.Get("X-Forwarded-For")
-	if xForwardedFor != "" {
-		// Take the first IP in the chain (original client)
-		ips := strings.Split(xForwardedFor, ",")
-		ip := strings.TrimSpace(ips[0])
-		if net.ParseIP(ip) != nil {
-			return ip
-		}
-	}
-
-	// Check X-Real-IP header
-	xRealIP := r.Header.Get("X-Real-IP")
-	if xRealIP != "" {
-		if net.ParseIP(xRealIP) != nil {
-			return xRealIP
-		}
-	}
-
-	// Fall back to RemoteAddr (remove port if present)
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return ip
-}
-
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
 				w.Header().Set("Connection", "close")
-				app.logger.ErrorWithContext(r.Context(), "panic recovered",
+				app.logger.ErrorWithContext(r.Context(), "panic recovered", withClientID(r,
 					"panic", err,
 					"method", r.Method,
 					"uri", r.URL.RequestURI(),
-					"addr", r.RemoteAddr)
+					"addr", r.RemoteAddr)...)
 				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
 			}
 		}()
@@ -166,21 +209,33 @@ func (app *application) logRequest(next http.Handler) http.Handler {
 		// Create a response recorder to capture the status code
 		rr := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
 
+		// Authentication runs inside this middleware and reports the client here
+		identity := &requestIdentity{}
+		r = r.WithContext(context.WithValue(r.Context(), identityContextKey, identity))
+
 		next.ServeHTTP(rr, r)
 
 		duration := time.Since(start)
 
 		corrID := r.Context().Value("correlation_id")
 
-		app.logger.Info("HTTP request completed",
+		attrs := []any{
 			"method", r.Method,
 			"uri", r.URL.RequestURI(),
 			"addr", r.RemoteAddr,
+			"client_ip", getClientIP(r),
+			"client_id", identity.clientID,
 			"proto", r.Proto,
 			"status", rr.statusCode,
 			"duration_ms", duration.Milliseconds(),
 			"correlation_id", corrID,
-			"user_agent", r.Header.Get("User-Agent"))
+			"user_agent", r.Header.Get("User-Agent"),
+		}
+		if claims := identity.claims; claims != nil {
+			attrs = append(attrs, "token_issuer", claims.Issuer, "token_scopes", claims.Scopes)
+		}
+
+		app.logger.Info("HTTP request completed", attrs...)
 	})
 }
 
@@ -195,8 +250,23 @@ func (rr *responseRecorder) WriteHeader(code int) {
 	rr.ResponseWriter.WriteHeader(code)
 }
 
-// rateLimit middleware enforces per-IP rate limiting using token bucket algorithm
-func (app *application) rateLimit(rateLimiterMap *rateLimiterMap) func(http.Handler) http.Handler {
+// Flush implements http.Flusher so streaming handlers can push partial responses
+// through the logging middleware
+func (rr *responseRecorder) Flush() {
+	if flusher, ok := rr.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
+
+// rateLimit middleware enforces the rate limit policy using token buckets. Each request draws
+// from the bucket of its client, tier and, for routes with their own limit, route. It takes one
+// token up front; see chargeRateLimit for requests that cost more.
+func (app *application) rateLimit(router *httprouter.Router, rateLimiterMap *rateLimiterMap) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Skip rate limiting if disabled
@@ -205,31 +275,18 @@ func (app *application) rateLimit(rateLimiterMap *rateLimiterMap) func(http.Hand
 				return
 			}
 
-			// Extract client IP
-			ip := getClientIP(r)
-
-			// Get rate limiter for this IP
-			limiter := rateLimiterMap.getLimiter(ip)
-
-			// Check if request is allowed
-			if !limiter.Allow() {
-				// Rate limit exceeded - calculate retry after time
-				retryAfter := time.Second / time.Duration(rateLimiterMap.rps)
-
-				// Log rate limit violation with correlation ID
-				corrID := r.Context().Value("correlation_id")
-				app.logger.WarnWithContext(r.Context(), "rate limit exceeded",
-					"ip", ip,
-					"correlation_id", corrID,
-					"rps_limit", float64(rateLimiterMap.rps),
-					"burst_limit", rateLimiterMap.burst,
-					"method", r.Method,
-					"uri", r.URL.RequestURI())
+			// Identify the client and the bucket it draws from for this route
+			target := rateLimiterMap.policy.target(r, routeLabel(router, r.URL.Path))
+			charge := &rateLimitCharge{limiters: rateLimiterMap, target: target}
 
-				// Send 429 rate limit exceeded response
-				app.rateLimitExceededResponse(w, r, retryAfter)
+			// Every request costs at least one token; handlers charge the rest of their cost
+			if retryAfter, ok := app.takeRateLimit(r, charge, 1); !ok {
+				app.rateLimitRejected(w, r, charge, 1, retryAfter)
 				return
 			}
+			charge.charged = 1
+			charge.writeHeaders(w)
+			r = r.WithContext(context.WithValue(r.Context(), rateLimitContextKey, charge))
 
 			// Request allowed - continue to next handler
 			next.ServeHTTP(w, r)
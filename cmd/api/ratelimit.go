@@ -0,0 +1,480 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultAPIKeyHeader is the header the api-key extractor reads unless the policy names another
+const defaultAPIKeyHeader = "X-API-Key"
+
+// contextKey is the type of request context keys set by this package's middleware
+type contextKey string
+
+// rateLimitContextKey holds the *rateLimitCharge of a rate limited request
+const rateLimitContextKey = contextKey("rate_limit")
+
+// rateLimit is the size of one token bucket
+type rateLimit struct {
+	RPS   float64 `json:"rps"`
+	Burst int     `json:"burst"`
+}
+
+// tierPolicy is the quota of one tier. Routes listed in Routes get a bucket of their own with
+// that limit; every other route shares the tier's default bucket.
+type tierPolicy struct {
+	rateLimit
+	Routes map[string]rateLimit `json:"routes,omitempty"`
+}
+
+// rateLimitPolicy decides which bucket a request draws from and how large that bucket is.
+// It is loaded from the JSON file given by -limiter-policy, or built from -limiter-rps and
+// -limiter-burst when no file is configured.
+type rateLimitPolicy struct {
+	// Key selects the key extractor: "ip" (default), "api-key" or "client"
+	Key string `json:"key"`
+	// APIKeyHeader is the header holding the API key (default X-API-Key)
+	APIKeyHeader string `json:"api_key_header"`
+	// DefaultTier applies to clients without a recognised API key
+	DefaultTier string                `json:"default_tier"`
+	Tiers       map[string]tierPolicy `json:"tiers"`
+	// APIKeys maps the hex SHA-256 of an API key to its tier, so the file holds no secrets
+	APIKeys map[string]string `json:"api_keys"`
+	// Clients maps the ID of an authenticated client to its tier, for the "client" key
+	Clients map[string]string `json:"clients"`
+	// Cost prices requests by the number of FizzBuzz elements they ask for
+	Cost rateLimitCost `json:"cost"`
+
+	extract keyExtractor
+}
+
+// rateLimitCost converts the work a request asks for into tokens. Every request costs at least
+// one token; with ElementsPerToken set, FizzBuzz requests cost one token per started block of
+// that many elements. Zero charges one token per request.
+type rateLimitCost struct {
+	ElementsPerToken int `json:"elements_per_token"`
+}
+
+// tokens returns the cost of computing elements FizzBuzz elements
+func (c rateLimitCost) tokens(elements int) int {
+	if c.ElementsPerToken <= 0 || elements <= c.ElementsPerToken {
+		return 1
+	}
+	return (elements + c.ElementsPerToken - 1) / c.ElementsPerToken
+}
+
+// maxElements returns the most FizzBuzz elements a single request may ask for under the smallest
+// bucket of the policy, or 0 when requests are not priced by size
+func (p *rateLimitPolicy) maxElements() int {
+	if p.Cost.ElementsPerToken <= 0 {
+		return 0
+	}
+
+	burst := 0
+	for _, tier := range p.Tiers {
+		if burst == 0 || tier.Burst < burst {
+			burst = tier.Burst
+		}
+		for _, limit := range tier.Routes {
+			if limit.Burst < burst {
+				burst = limit.Burst
+			}
+		}
+	}
+	return burst * p.Cost.ElementsPerToken
+}
+
+// keyExtractor identifies the client a request is limited as, and the tier it belongs to
+type keyExtractor func(r *http.Request) (client, tier string)
+
+// rateLimitTarget is the bucket a request draws from
+type rateLimitTarget struct {
+	client string // "ip:<address>" or "key:<hash prefix>"
+	tier   string
+	route  string // empty when the request draws from the tier's default bucket
+	limit  rateLimit
+}
+
+// bucket returns the key of the target's token bucket in the rate limiter map
+func (t rateLimitTarget) bucket() string {
+	return t.tier + "|" + t.route + "|" + t.client
+}
+
+// defaultRateLimitPolicy limits every client IP to rps and burst on all routes
+func defaultRateLimitPolicy(rps float64, burst int) *rateLimitPolicy {
+	policy := &rateLimitPolicy{
+		Key:         "ip",
+		DefaultTier: "default",
+		Tiers:       map[string]tierPolicy{"default": {rateLimit: rateLimit{RPS: rps, Burst: burst}}},
+	}
+	policy.extract = ipKeyExtractor(policy.DefaultTier)
+	return policy
+}
+
+// loadRateLimitPolicy reads and validates a rate limit policy file
+func loadRateLimitPolicy(path string) (*rateLimitPolicy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rate limit policy: %w", err)
+	}
+	defer file.Close()
+
+	var policy rateLimitPolicy
+	dec := json.NewDecoder(file)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&policy); err != nil {
+		return nil, fmt.Errorf("failed to decode rate limit policy %s: %w", path, err)
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid rate limit policy %s: %w", path, err)
+	}
+	return &policy, nil
+}
+
+// validate checks the policy, fills in defaults and builds its key extractor
+func (p *rateLimitPolicy) validate() error {
+	if len(p.Tiers) == 0 {
+		return errors.New("at least one tier is required")
+	}
+	for name, tier := range p.Tiers {
+		if err := tier.rateLimit.validate(); err != nil {
+			return fmt.Errorf("tier %q: %w", name, err)
+		}
+		for route, limit := range tier.Routes {
+			if err := limit.validate(); err != nil {
+				return fmt.Errorf("tier %q route %s: %w", name, route, err)
+			}
+		}
+	}
+
+	if p.Cost.ElementsPerToken < 0 {
+		return errors.New("cost elements_per_token must not be negative")
+	}
+
+	if p.DefaultTier == "" {
+		p.DefaultTier = "default"
+	}
+	if _, exists := p.Tiers[p.DefaultTier]; !exists {
+		return fmt.Errorf("default tier %q is not defined", p.DefaultTier)
+	}
+
+	keys := make(map[string]string, len(p.APIKeys))
+	for hash, tier := range p.APIKeys {
+		hash = strings.ToLower(hash)
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return fmt.Errorf("api key %q is not a hex SHA-256 digest", hash)
+		}
+		if _, exists := p.Tiers[tier]; !exists {
+			return fmt.Errorf("api key %s...: tier %q is not defined", hash[:8], tier)
+		}
+		keys[hash] = tier
+	}
+	p.APIKeys = keys
+
+	for clientID, tier := range p.Clients {
+		if _, exists := p.Tiers[tier]; !exists {
+			return fmt.Errorf("client %q: tier %q is not defined", clientID, tier)
+		}
+	}
+
+	switch p.Key {
+	case "", "ip":
+		p.Key = "ip"
+		p.extract = ipKeyExtractor(p.DefaultTier)
+	case "api-key":
+		if p.APIKeyHeader == "" {
+			p.APIKeyHeader = defaultAPIKeyHeader
+		}
+		p.extract = apiKeyExtractor(p.APIKeyHeader, p.APIKeys, ipKeyExtractor(p.DefaultTier))
+	case "client":
+		p.extract = clientKeyExtractor(p.Clients, p.DefaultTier, ipKeyExtractor(p.DefaultTier))
+	default:
+		return fmt.Errorf("unknown key %q (want ip, api-key or client)", p.Key)
+	}
+
+	return nil
+}
+
+// validate rejects buckets that would never admit a request
+func (l rateLimit) validate() error {
+	if l.RPS <= 0 {
+		return errors.New("rps must be positive")
+	}
+	if l.Burst <= 0 {
+		return errors.New("burst must be positive")
+	}
+	return nil
+}
+
+// target returns the bucket r draws from; route is the matched route path
+func (p *rateLimitPolicy) target(r *http.Request, route string) rateLimitTarget {
+	client, tier := p.extract(r)
+	quota := p.Tiers[tier]
+
+	if limit, exists := quota.Routes[route]; exists {
+		return rateLimitTarget{client: client, tier: tier, route: route, limit: limit}
+	}
+	return rateLimitTarget{client: client, tier: tier, limit: quota.rateLimit}
+}
+
+// ipKeyExtractor limits each client IP address under tier
+func ipKeyExtractor(tier string) keyExtractor {
+	return func(r *http.Request) (string, string) {
+		return "ip:" + getClientIP(r), tier
+	}
+}
+
+// apiKeyExtractor limits clients presenting a known API key in header under that key's tier.
+// Requests without a key, or with an unknown one, fall back so that random keys cannot be
+// used to get a fresh bucket.
+func apiKeyExtractor(header string, keys map[string]string, fallback keyExtractor) keyExtractor {
+	return func(r *http.Request) (string, string) {
+		key := r.Header.Get(header)
+		if key == "" {
+			return fallback(r)
+		}
+
+		sum := sha256.Sum256([]byte(key))
+		hash := hex.EncodeToString(sum[:])
+		if tier, exists := keys[hash]; exists {
+			return "key:" + hash[:16], tier
+		}
+		return fallback(r)
+	}
+}
+
+// clientKeyExtractor limits each authenticated client under its tier in clients, or the default
+// tier if it is not listed. Anonymous requests fall back.
+func clientKeyExtractor(clients map[string]string, defaultTier string, fallback keyExtractor) keyExtractor {
+	return func(r *http.Request) (string, string) {
+		clientID := getClientID(r)
+		if clientID == "" {
+			return fallback(r)
+		}
+		if tier, exists := clients[clientID]; exists {
+			return "client:" + clientID, tier
+		}
+		return "client:" + clientID, defaultTier
+	}
+}
+
+// rateLimitStore holds token buckets. rateLimiterMap keeps them in process memory;
+// redisRateLimitStore shares them between replicas through a Redis-compatible server.
+type rateLimitStore interface {
+	// take takes n tokens from the bucket at key, sized by limit. When the bucket cannot cover
+	// them nothing is taken and the result says how long until it could.
+	take(ctx context.Context, key string, limit rateLimit, n int) (bucketResult, error)
+	// peek returns the state of the bucket at key without changing it
+	peek(ctx context.Context, key string, limit rateLimit) (quotaState, error)
+	// ping checks that the store can be reached
+	ping(ctx context.Context) error
+	// close releases the store's connections
+	close() error
+}
+
+// bucketResult is the outcome of taking tokens from a bucket
+type bucketResult struct {
+	allowed    bool
+	retryAfter time.Duration // Until the bucket could cover the request, when not allowed
+	state      quotaState    // The bucket after the attempt
+}
+
+// quotaState is a snapshot of one token bucket
+type quotaState struct {
+	Limit     int     `json:"limit"`         // Bucket size (burst)
+	Remaining int     `json:"remaining"`     // Whole tokens left
+	Reset     int     `json:"reset_seconds"` // Seconds until the bucket is full again
+	RPS       float64 `json:"rps"`
+}
+
+// newQuotaState describes a bucket sized by limit that holds tokens
+func newQuotaState(tokens float64, limit rateLimit) quotaState {
+	return quotaState{
+		Limit:     limit.Burst,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     max(int(math.Ceil((float64(limit.Burst)-tokens)/limit.RPS)), 0),
+		RPS:       limit.RPS,
+	}
+}
+
+// rateLimitCharge is the token bucket a request drew from. The middleware charges one token and
+// keeps the charge in the request context, so handlers can charge the rest of the request's cost
+// once they have decoded what it asks for.
+type rateLimitCharge struct {
+	limiters *rateLimiterMap
+	target   rateLimitTarget
+	charged  int
+	state    quotaState // The bucket after the last take
+}
+
+// writeHeaders describes the bucket in RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset
+// headers, plus the X-RateLimit-* equivalents and what the request cost
+func (c *rateLimitCharge) writeHeaders(w http.ResponseWriter) {
+	if c.state.Limit == 0 {
+		return // The store could not be reached, so the bucket is unknown
+	}
+	limit, remaining, reset := strconv.Itoa(c.state.Limit), strconv.Itoa(c.state.Remaining), strconv.Itoa(c.state.Reset)
+
+	w.Header().Set("RateLimit-Limit", limit)
+	w.Header().Set("RateLimit-Remaining", remaining)
+	w.Header().Set("RateLimit-Reset", reset)
+	w.Header().Set("X-RateLimit-Limit", limit)
+	w.Header().Set("X-RateLimit-Remaining", remaining)
+	w.Header().Set("X-RateLimit-Reset", reset)
+	w.Header().Set("X-RateLimit-Cost", strconv.Itoa(c.charged))
+}
+
+// takeRateLimit takes n tokens for the request from its bucket. Should the store fail, the
+// request is let through: an unreachable shared store must not take the API down with it.
+func (app *application) takeRateLimit(r *http.Request, charge *rateLimitCharge, n int) (time.Duration, bool) {
+	result, err := charge.limiters.store.take(r.Context(), charge.target.bucket(), charge.target.limit, n)
+	if err != nil {
+		charge.limiters.storeErrors.Add(1)
+		app.logger.WarnWithContext(r.Context(), "rate limit store unavailable, request not limited",
+			"error", err,
+			"client", charge.target.client)
+		return 0, true
+	}
+
+	charge.state = result.state
+	return result.retryAfter, result.allowed
+}
+
+// chargeRateLimit charges the request for computing elements FizzBuzz elements, on top of the
+// token the middleware already took. If the bucket cannot cover it, a 429 response is sent and
+// false returned. A request costing more than the bucket's burst could never be served, so it is
+// refused with a 422 response telling the client how many elements it may ask for at once.
+func (app *application) chargeRateLimit(w http.ResponseWriter, r *http.Request, elements int) bool {
+	charge, ok := r.Context().Value(rateLimitContextKey).(*rateLimitCharge)
+	if !ok {
+		return true // Rate limiting disabled
+	}
+
+	cost := charge.limiters.policy.Cost
+	tokens := cost.tokens(elements)
+	if burst := charge.target.limit.Burst; tokens > burst {
+		app.logRateLimitRejection(w, r, charge, tokens, "request cost exceeds rate limit burst")
+		app.costExceedsBurstResponse(w, r, tokens, burst, burst*cost.ElementsPerToken)
+		return false
+	}
+
+	extra := tokens - charge.charged
+	if extra <= 0 {
+		return true
+	}
+
+	if retryAfter, ok := app.takeRateLimit(r, charge, extra); !ok {
+		app.rateLimitRejected(w, r, charge, tokens, retryAfter)
+		return false
+	}
+
+	charge.charged = tokens
+	charge.writeHeaders(w)
+	return true
+}
+
+// rateLimitRejected counts and logs a request that did not get the tokens it costs, then sends 429
+func (app *application) rateLimitRejected(w http.ResponseWriter, r *http.Request, charge *rateLimitCharge, cost int, retryAfter time.Duration) {
+	app.logRateLimitRejection(w, r, charge, cost, "rate limit exceeded")
+
+	// Send 429 rate limit exceeded response
+	app.rateLimitExceededResponse(w, r, retryAfter)
+}
+
+// logRateLimitRejection counts and logs a request refused by the rate limiter and describes its
+// bucket and cost in the response headers
+func (app *application) logRateLimitRejection(w http.ResponseWriter, r *http.Request, charge *rateLimitCharge, cost int, message string) {
+	charge.limiters.rejected.Add(1)
+	charge.writeHeaders(w)
+	w.Header().Set("X-RateLimit-Cost", strconv.Itoa(cost))
+
+	// Log rate limit violation with correlation ID
+	corrID := r.Context().Value("correlation_id")
+	app.logger.WarnWithContext(r.Context(), message,
+		"ip", getClientIP(r),
+		"client", charge.target.client,
+		"tier", charge.target.tier,
+		"route", charge.target.route,
+		"cost", cost,
+		"correlation_id", corrID,
+		"rps_limit", charge.target.limit.RPS,
+		"burst_limit", charge.target.limit.Burst,
+		"method", r.Method,
+		"uri", r.URL.RequestURI())
+}
+
+// rateLimitStatusHandler reports the caller's quota: the tier it is limited under and the state
+// of each bucket it can draw from. The request itself is charged like any other.
+func (app *application) rateLimitStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.config.limiter.enabled || app.rateLimiter == nil {
+		err := app.writeJSON(w, http.StatusOK, envelope{"data": envelope{"enabled": false}}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	rlm := app.rateLimiter
+	target := rlm.policy.target(r, "")
+	quota := rlm.policy.Tiers[target.tier]
+
+	type bucketStatus struct {
+		Route string `json:"route"` // "*" for the bucket shared by routes without their own limit
+		quotaState
+	}
+
+	// peek returns the state of the caller's bucket for route ("" for the shared bucket)
+	peek := func(route string, limit rateLimit) (bucketStatus, error) {
+		key := rateLimitTarget{client: target.client, tier: target.tier, route: route}.bucket()
+		state, err := rlm.store.peek(r.Context(), key, limit)
+		if route == "" {
+			route = "*"
+		}
+		return bucketStatus{Route: route, quotaState: state}, err
+	}
+
+	routes := make([]string, 0, len(quota.Routes))
+	for route := range quota.Routes {
+		routes = append(routes, route)
+	}
+	slices.Sort(routes)
+
+	shared, err := peek("", quota.rateLimit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	buckets := []bucketStatus{shared}
+	for _, route := range routes {
+		bucket, err := peek(route, quota.Routes[route])
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	status := envelope{
+		"enabled": true,
+		"client":  target.client,
+		"tier":    target.tier,
+		"buckets": buckets,
+		"cost":    rlm.policy.Cost,
+	}
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": status}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
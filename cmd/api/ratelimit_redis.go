@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"fizzbuzz/internal/resp"
+)
+
+// tokenBucketSource is the Lua token bucket run atomically by the shared store.
+// KEYS[1] is the bucket hash {tokens, ts}; ARGV is rate (tokens per second), burst and the
+// tokens to take, where 0 only reads the bucket. The server's clock is used so replicas with
+// skewed clocks agree. Returns {allowed, tokens left, milliseconds until allowed}; tokens are
+// returned as a string because Lua numbers are truncated to integers in replies.
+const tokenBucketSource = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate / 1000)
+end
+
+local allowed = 0
+local wait = 0
+if tokens >= n then
+  allowed = 1
+  if n > 0 then
+    tokens = tokens - n
+    redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+    redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+  end
+else
+  wait = math.ceil((n - tokens) * 1000 / rate)
+end
+
+return {allowed, tostring(tokens), wait}
+`
+
+var tokenBucketScript = resp.NewScript(tokenBucketSource)
+
+// redisRateLimitStore keeps token buckets in a Redis-compatible server so that every replica
+// draws from the same buckets. Buckets expire once they would have refilled.
+type redisRateLimitStore struct {
+	client *resp.Client
+	prefix string
+}
+
+// newRedisRateLimitStore creates a store whose keys start with prefix
+func newRedisRateLimitStore(client *resp.Client, prefix string) *redisRateLimitStore {
+	return &redisRateLimitStore{client: client, prefix: prefix}
+}
+
+// take implements rateLimitStore with one atomic script call
+func (s *redisRateLimitStore) take(ctx context.Context, key string, limit rateLimit, n int) (bucketResult, error) {
+	reply, err := tokenBucketScript.Run(ctx, s.client, []string{s.prefix + key},
+		strconv.FormatFloat(limit.RPS, 'f', -1, 64),
+		strconv.Itoa(limit.Burst),
+		strconv.Itoa(n))
+	if err != nil {
+		return bucketResult{}, fmt.Errorf("token bucket script failed: %w", err)
+	}
+
+	items, ok := reply.([]any)
+	if !ok || len(items) != 3 {
+		return bucketResult{}, fmt.Errorf("unexpected token bucket reply %v", reply)
+	}
+	allowed, okAllowed := items[0].(int64)
+	tokensText, okTokens := items[1].(string)
+	wait, okWait := items[2].(int64)
+	tokens, err := strconv.ParseFloat(tokensText, 64)
+	if !okAllowed || !okTokens || !okWait || err != nil {
+		return bucketResult{}, fmt.Errorf("unexpected token bucket reply %v", reply)
+	}
+
+	return bucketResult{
+		allowed:    allowed == 1,
+		retryAfter: time.Duration(wait) * time.Millisecond,
+		state:      newQuotaState(tokens, limit),
+	}, nil
+}
+
+// peek implements rateLimitStore by running the script without taking tokens
+func (s *redisRateLimitStore) peek(ctx context.Context, key string, limit rateLimit) (quotaState, error) {
+	result, err := s.take(ctx, key, limit, 0)
+	return result.state, err
+}
+
+// ping implements rateLimitStore
+func (s *redisRateLimitStore) ping(ctx context.Context) error {
+	return s.client.Ping(ctx)
+}
+
+// close implements rateLimitStore
+func (s *redisRateLimitStore) close() error {
+	return s.client.Close()
+}
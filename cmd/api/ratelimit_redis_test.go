@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"fizzbuzz/internal/health"
+	"fizzbuzz/internal/resp"
+	"fizzbuzz/internal/resp/resptest"
+)
+
+// tokenBucketStandIn implements tokenBucketSource for the resptest server
+func tokenBucketStandIn(data map[string]map[string]string, now time.Time, keys, args []string) any {
+	rate, _ := strconv.ParseFloat(args[0], 64)
+	burst, _ := strconv.ParseFloat(args[1], 64)
+	n, _ := strconv.ParseFloat(args[2], 64)
+	nowMs := float64(now.UnixMilli())
+
+	tokens, ts := burst, nowMs
+	if bucket := data[keys[0]]; bucket != nil {
+		tokens, _ = strconv.ParseFloat(bucket["tokens"], 64)
+		ts, _ = strconv.ParseFloat(bucket["ts"], 64)
+	}
+	if nowMs > ts {
+		tokens = math.Min(burst, tokens+(nowMs-ts)*rate/1000)
+	}
+
+	allowed, wait := int64(0), int64(0)
+	if tokens >= n {
+		allowed = 1
+		if n > 0 {
+			tokens -= n
+			data[keys[0]] = map[string]string{
+				"tokens": strconv.FormatFloat(tokens, 'f', -1, 64),
+				"ts":     strconv.FormatFloat(nowMs, 'f', -1, 64),
+			}
+		}
+	} else {
+		wait = int64(math.Ceil((n - tokens) * 1000 / rate))
+	}
+	return []any{allowed, strconv.FormatFloat(tokens, 'f', -1, 64), wait}
+}
+
+// newRedisTestServer starts a stand-in server with the token bucket script and a clock that
+// only moves when advanced
+func newRedisTestServer(t *testing.T) (*resptest.Server, func(time.Duration)) {
+	t.Helper()
+
+	server := resptest.NewServer()
+	t.Cleanup(server.Close)
+	server.RegisterScript(tokenBucketSource, tokenBucketStandIn)
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	setClock := func(at time.Time) { server.SetClock(func() time.Time { return at }) }
+	setClock(now)
+	return server, func(d time.Duration) {
+		now = now.Add(d)
+		setClock(now)
+	}
+}
+
+// newRedisReplica creates an application sharing server's buckets, as one of several replicas
+func newRedisReplica(t *testing.T, server *resptest.Server, rps float64, burst int) *application {
+	t.Helper()
+
+	client := resp.NewClient(resp.Options{Addr: server.Addr})
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.rateLimiter = newRateLimiterMap(rps, burst)
+	app.rateLimiter.store = newRedisRateLimitStore(client, "test:")
+	t.Cleanup(func() { app.rateLimiter.store.close() })
+	return app
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	server, advance := newRedisTestServer(t)
+	store := newRedisRateLimitStore(resp.NewClient(resp.Options{Addr: server.Addr}), "test:")
+	defer store.close()
+
+	ctx := context.Background()
+	limit := rateLimit{RPS: 2, Burst: 3}
+
+	// An unused bucket is full, and peeking does not create it
+	state, err := store.peek(ctx, "bucket", limit)
+	if err != nil || state.Remaining != 3 || state.Reset != 0 {
+		t.Fatalf("unexpected state of an unused bucket: %+v, %v", state, err)
+	}
+	if server.Hash("test:bucket") != nil {
+		t.Error("expected peek not to create the bucket")
+	}
+
+	result, err := store.take(ctx, "bucket", limit, 3)
+	if err != nil || !result.allowed || result.state.Remaining != 0 || result.state.Reset != 2 {
+		t.Fatalf("unexpected result: %+v, %v", result, err)
+	}
+
+	result, err = store.take(ctx, "bucket", limit, 2)
+	if err != nil || result.allowed || result.retryAfter != time.Second {
+		t.Fatalf("expected a rejection with a one second wait, got %+v, %v", result, err)
+	}
+
+	advance(time.Second)
+	result, err = store.take(ctx, "bucket", limit, 2)
+	if err != nil || !result.allowed || result.state.Remaining != 0 {
+		t.Errorf("expected the bucket to have refilled, got %+v, %v", result, err)
+	}
+}
+
+func TestRedisRateLimitSharedAcrossReplicas(t *testing.T) {
+	server, _ := newRedisTestServer(t)
+	replicas := []http.Handler{
+		newRedisReplica(t, server, 1, 4).routes(),
+		newRedisReplica(t, server, 1, 4).routes(),
+		newRedisReplica(t, server, 1, 4).routes(),
+	}
+
+	// Six requests spread over three replicas draw from one bucket of four
+	limited := 0
+	for i := 0; i < 6; i++ {
+		rr := httptest.NewRecorder()
+		replicas[i%len(replicas)].ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
+		if rr.Code == http.StatusTooManyRequests {
+			limited++
+			if rr.Header().Get("Retry-After") != "1" {
+				t.Errorf("expected Retry-After 1, got %q", rr.Header().Get("Retry-After"))
+			}
+		}
+	}
+	if limited != 2 {
+		t.Errorf("expected 2 limited requests across replicas, got %d", limited)
+	}
+}
+
+func TestRedisRateLimitStoreDown(t *testing.T) {
+	server, _ := newRedisTestServer(t)
+	app := newRedisReplica(t, server, 1, 1)
+	app.health = newHealthRegistry(app, false)
+	server.SetDown(true)
+
+	// Requests are let through without rate limit headers while the store fails
+	for i := 0; i < 3; i++ {
+		rr := httptest.NewRecorder()
+		app.routes().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
+		if rr.Code == http.StatusTooManyRequests || rr.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("expected the request to pass unlimited, got %d %v", rr.Code, rr.Header())
+		}
+	}
+	if errors := app.rateLimiter.storeErrors.Load(); errors < 3 {
+		t.Errorf("expected store errors to be counted, got %d", errors)
+	}
+
+	if status := checkStatuses(app.health.Run(context.Background()))["rate_limiter"]; status != health.StatusWarn {
+		t.Errorf("expected the rate_limiter check to warn, got %q", status)
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hashAPIKey returns the digest a policy file lists for key
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// writePolicy writes a policy file to a temporary directory and returns its path
+func writePolicy(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// tieredPolicy is a free tier with a cheap statistics route and a paid tier for one API key
+var tieredPolicy = `{
+	"key": "api-key",
+	"default_tier": "free",
+	"tiers": {
+		"free": {"rps": 1, "burst": 2, "routes": {"/v1/statistics": {"rps": 1, "burst": 5}}},
+		"pro": {"rps": 10, "burst": 4}
+	},
+	"api_keys": {"` + hashAPIKey("pro-secret") + `": "pro"}
+}`
+
+func TestLoadRateLimitPolicy(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		policy, err := loadRateLimitPolicy(writePolicy(t, tieredPolicy))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if policy.APIKeyHeader != defaultAPIKeyHeader || policy.extract == nil {
+			t.Errorf("expected defaults to be filled in, got %+v", policy)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr string
+	}{
+		{"no tiers", `{"tiers": {}}`, "at least one tier"},
+		{"missing default tier", `{"default_tier": "free", "tiers": {"pro": {"rps": 1, "burst": 1}}}`, `default tier "free"`},
+		{"zero rps", `{"tiers": {"default": {"rps": 0, "burst": 1}}}`, "rps must be positive"},
+		{"bad route limit", `{"tiers": {"default": {"rps": 1, "burst": 1, "routes": {"/v1/statistics": {"rps": 1}}}}}`, "burst must be positive"},
+		{"unknown key", `{"key": "cookie", "tiers": {"default": {"rps": 1, "burst": 1}}}`, `unknown key "cookie"`},
+		{"client unknown tier", `{"key": "client", "tiers": {"default": {"rps": 1, "burst": 1}}, "clients": {"acme": "gold"}}`, `tier "gold"`},
+		{"api key not hashed", `{"tiers": {"default": {"rps": 1, "burst": 1}}, "api_keys": {"secret": "default"}}`, "not a hex SHA-256"},
+		{"api key unknown tier", `{"tiers": {"default": {"rps": 1, "burst": 1}}, "api_keys": {"` + hashAPIKey("k") + `": "gold"}}`, `tier "gold"`},
+		{"negative cost", `{"tiers": {"default": {"rps": 1, "burst": 1}}, "cost": {"elements_per_token": -1}}`, "must not be negative"},
+		{"unknown field", `{"tier": {}}`, "unknown field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadRateLimitPolicy(writePolicy(t, tt.policy))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRateLimitPolicyTarget(t *testing.T) {
+	policy, err := loadRateLimitPolicy(writePolicy(t, tieredPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		route      string
+		wantClient string
+		wantTier   string
+		wantRoute  string
+		wantBurst  int
+	}{
+		{"anonymous", "", "/v1/fizzbuzz", "ip:192.0.2.1", "free", "", 2},
+		{"anonymous cheap route", "", "/v1/statistics", "ip:192.0.2.1", "free", "/v1/statistics", 5},
+		{"unknown key falls back to ip", "guess", "/v1/fizzbuzz", "ip:192.0.2.1", "free", "", 2},
+		{"paid key", "pro-secret", "/v1/statistics", "key:" + hashAPIKey("pro-secret")[:16], "pro", "", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.route, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+
+			target := policy.target(req, tt.route)
+			if target.client != tt.wantClient || target.tier != tt.wantTier || target.route != tt.wantRoute || target.limit.Burst != tt.wantBurst {
+				t.Errorf("unexpected target: %+v", target)
+			}
+		})
+	}
+}
+
+func TestRateLimitMiddlewarePolicy(t *testing.T) {
+	policy, err := loadRateLimitPolicy(writePolicy(t, tieredPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.rateLimiter = newRateLimiterMapWithPolicy(policy)
+	handler := app.routes()
+
+	// serve sends n requests and returns how many were rate limited
+	serve := func(n int, path, apiKey string) int {
+		limited := 0
+		for i := 0; i < n; i++ {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			if apiKey != "" {
+				req.Header.Set("X-API-Key", apiKey)
+			}
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code == http.StatusTooManyRequests {
+				limited++
+			}
+		}
+		return limited
+	}
+
+	// The free tier's default bucket holds two requests
+	if limited := serve(3, "/v1/healthcheck", ""); limited != 1 {
+		t.Errorf("expected 1 limited request on the default bucket, got %d", limited)
+	}
+	// The statistics route has its own, larger bucket
+	if limited := serve(5, "/v1/statistics", ""); limited != 0 {
+		t.Errorf("expected statistics to use its own bucket, got %d limited", limited)
+	}
+	// A paid key from the same IP gets the pro quota
+	if limited := serve(5, "/v1/healthcheck", "pro-secret"); limited != 1 {
+		t.Errorf("expected 1 limited request on the pro bucket, got %d", limited)
+	}
+}
+
+func TestRateLimitCostTokens(t *testing.T) {
+	tests := []struct {
+		elementsPerToken int
+		elements         int
+		want             int
+	}{
+		{0, 100000, 1},
+		{1000, 0, 1},
+		{1000, 15, 1},
+		{1000, 1000, 1},
+		{1000, 1001, 2},
+		{1000, 100000, 100},
+	}
+
+	for _, tt := range tests {
+		cost := rateLimitCost{ElementsPerToken: tt.elementsPerToken}
+		if got := cost.tokens(tt.elements); got != tt.want {
+			t.Errorf("tokens(%d) with %d per token = %d, want %d", tt.elements, tt.elementsPerToken, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitCostWeighted(t *testing.T) {
+	newHandler := func() http.Handler {
+		app := newTestApplication(t)
+		app.config.limiter.enabled = true
+		policy := defaultRateLimitPolicy(1, 10)
+		policy.Cost.ElementsPerToken = 1000
+		app.rateLimiter = newRateLimiterMapWithPolicy(policy)
+		return app.routes()
+	}
+
+	post := func(handler http.Handler, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		body          string
+		wantCode      int
+		wantCost      string
+		wantRemaining string
+	}{
+		{"small request", "/v1/fizzbuzz", `{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`, http.StatusOK, "1", "9"},
+		{"large request", "/v1/fizzbuzz", `{"int1":3,"int2":5,"limit":4500,"str1":"fizz","str2":"buzz"}`, http.StatusOK, "5", "5"},
+		{"paged request costs the page", "/v1/fizzbuzz", `{"int1":3,"int2":5,"limit":100000,"str1":"fizz","str2":"buzz","page_size":2000}`, http.StatusOK, "2", "8"},
+		{"batch costs its total", "/v1/fizzbuzz/batch", `[{"int1":3,"int2":5,"limit":3000,"str1":"fizz","str2":"buzz"},{"int1":2,"int2":7,"limit":3000,"str1":"foo","str2":"bar"}]`, http.StatusOK, "6", "4"},
+		{"cost up to the burst", "/v1/fizzbuzz", `{"int1":3,"int2":5,"limit":10000,"str1":"fizz","str2":"buzz"}`, http.StatusOK, "10", "0"},
+		{"cost above the burst is refused", "/v1/fizzbuzz", `{"int1":3,"int2":5,"limit":100000,"str1":"fizz","str2":"buzz"}`, http.StatusUnprocessableEntity, "100", "9"},
+		{"batch above the burst is refused", "/v1/fizzbuzz/batch", `[{"int1":3,"int2":5,"limit":6000,"str1":"fizz","str2":"buzz"},{"int1":2,"int2":7,"limit":6000,"str1":"foo","str2":"bar"}]`, http.StatusUnprocessableEntity, "12", "9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := post(newHandler(), tt.path, tt.body)
+			if rr.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantCode, rr.Code, rr.Body.String())
+			}
+			if got := rr.Header().Get("X-RateLimit-Cost"); got != tt.wantCost {
+				t.Errorf("expected cost %s, got %s", tt.wantCost, got)
+			}
+			if got := rr.Header().Get("X-RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("expected %s remaining, got %s", tt.wantRemaining, got)
+			}
+			if got := rr.Header().Get("X-RateLimit-Limit"); got != "10" {
+				t.Errorf("expected limit 10, got %s", got)
+			}
+		})
+	}
+
+	t.Run("rejected when the bucket cannot cover the cost", func(t *testing.T) {
+		handler := newHandler()
+		large := `{"int1":3,"int2":5,"limit":8000,"str1":"fizz","str2":"buzz"}`
+
+		if rr := post(handler, "/v1/fizzbuzz", large); rr.Code != http.StatusOK {
+			t.Fatalf("expected the first request to pass, got %d", rr.Code)
+		}
+		rr := post(handler, "/v1/fizzbuzz", large)
+		if rr.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, rr.Code)
+		}
+		if rr.Header().Get("X-RateLimit-Cost") != "8" || rr.Header().Get("Retry-After") == "" {
+			t.Errorf("unexpected headers: %v", rr.Header())
+		}
+
+		// A cheap request still fits in what is left
+		if rr := post(handler, "/v1/fizzbuzz", `{"int1":3,"int2":5,"limit":15,"str1":"fizz","str2":"buzz"}`); rr.Code != http.StatusOK {
+			t.Errorf("expected a small request to pass, got %d", rr.Code)
+		}
+	})
+
+	t.Run("refused above the burst without a retry hint", func(t *testing.T) {
+		rr := post(newHandler(), "/v1/fizzbuzz", `{"int1":3,"int2":5,"limit":10001,"str1":"fizz","str2":"buzz"}`)
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+		}
+		if rr.Header().Get("Retry-After") != "" {
+			t.Errorf("expected no Retry-After for a request that can never fit, got %q", rr.Header().Get("Retry-After"))
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(body.Error, "at most 10000 elements") {
+			t.Errorf("expected the message to give the largest request, got %q", body.Error)
+		}
+	})
+}
+
+func TestRateLimitPolicyMaxElements(t *testing.T) {
+	policy, err := loadRateLimitPolicy(writePolicy(t, `{
+		"tiers": {
+			"free": {"rps": 1, "burst": 8, "routes": {"/v1/fizzbuzz": {"rps": 1, "burst": 3}}},
+			"pro": {"rps": 10, "burst": 100}
+		},
+		"default_tier": "free",
+		"cost": {"elements_per_token": 1000}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := policy.maxElements(); got != 3000 {
+		t.Errorf("expected 3000 elements under the smallest burst, got %d", got)
+	}
+
+	if got := defaultRateLimitPolicy(1, 4).maxElements(); got != 0 {
+		t.Errorf("expected no cap without a cost, got %d", got)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	app := newTestApplication(t)
+	app.config.limiter.enabled = true
+	app.rateLimiter = newRateLimiterMap(0.1, 2)
+	handler := app.routes()
+
+	get := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil))
+		return rr
+	}
+
+	tests := []struct {
+		wantCode       int
+		wantRemaining  string
+		wantReset      string
+		wantRetryAfter string
+	}{
+		{http.StatusOK, "1", "10", ""},
+		{http.StatusOK, "0", "20", ""},
+		// The next token is ten seconds away at 0.1 rps, not the 1/rps truncated to zero
+		{http.StatusTooManyRequests, "0", "20", "10"},
+	}
+
+	for i, tt := range tests {
+		rr := get()
+		if rr.Code != tt.wantCode {
+			t.Fatalf("request %d: expected status %d, got %d", i, tt.wantCode, rr.Code)
+		}
+		headers := rr.Header()
+		if headers.Get("RateLimit-Limit") != "2" || headers.Get("RateLimit-Remaining") != tt.wantRemaining ||
+			headers.Get("RateLimit-Reset") != tt.wantReset || headers.Get("Retry-After") != tt.wantRetryAfter {
+			t.Errorf("request %d: unexpected headers %v", i, headers)
+		}
+	}
+}
+
+func TestRateLimitStatusHandler(t *testing.T) {
+	type statusResponse struct {
+		Data struct {
+			Enabled bool   `json:"enabled"`
+			Client  string `json:"client"`
+			Tier    string `json:"tier"`
+			Buckets []struct {
+				Route string `json:"route"`
+				quotaState
+			} `json:"buckets"`
+			Cost rateLimitCost `json:"cost"`
+		} `json:"data"`
+	}
+
+	get := func(t *testing.T, handler http.Handler, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		policy, err := loadRateLimitPolicy(writePolicy(t, tieredPolicy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		app := newTestApplication(t)
+		app.config.limiter.enabled = true
+		app.rateLimiter = newRateLimiterMapWithPolicy(policy)
+		handler := app.routes()
+
+		get(t, handler, "/v1/statistics")
+		rr := get(t, handler, "/v1/ratelimit")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+
+		var response statusResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		status := response.Data
+		if !status.Enabled || status.Tier != "free" || status.Client != "ip:192.0.2.1" || len(status.Buckets) != 2 {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+
+		// The status request drew from the shared bucket, the statistics request from its own
+		shared, statistics := status.Buckets[0], status.Buckets[1]
+		if shared.Route != "*" || shared.Limit != 2 || shared.Remaining != 1 {
+			t.Errorf("unexpected shared bucket: %+v", shared)
+		}
+		if statistics.Route != "/v1/statistics" || statistics.Limit != 5 || statistics.Remaining != 4 {
+			t.Errorf("unexpected statistics bucket: %+v", statistics)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		rr := get(t, newTestApplication(t).routes(), "/v1/ratelimit")
+
+		var response statusResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if rr.Code != http.StatusOK || response.Data.Enabled {
+			t.Errorf("expected 200 with rate limiting disabled, got %d %s", rr.Code, rr.Body.String())
+		}
+	})
+}
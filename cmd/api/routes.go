@@ -16,10 +16,22 @@ func (app *application) routes() http.Handler {
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/health/live", app.liveHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/health/ready", app.readyHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/fizzbuzz", app.fizzbuzzQueryHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/fizzbuzz", app.fizzbuzzHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/fizzbuzz/batch", app.fizzbuzzBatchHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/statistics", app.statisticsHandler)
-
-	return app.correlationID(app.logRequest(app.rateLimit(app.rateLimiter)(app.recoverPanic(router))))
+	router.HandlerFunc(http.MethodGet, "/v1/statistics/top", app.topStatisticsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/statistics/summary", app.statisticsSummaryHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/statistics/clients", app.topClientsHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/ratelimit", app.rateLimitStatusHandler)
+	router.HandlerFunc(http.MethodGet, "/metrics", app.metricsHandler)
+
+	// Authentication runs inside logging, metrics and panic recovery so rejected requests are
+	// logged, counted and recovered like any other; a per-IP limit guards the credential lookup,
+	// and the client's own bucket is charged once it is known
+	return app.correlationID(app.clientIP(app.cors(app.logRequest(app.recordMetrics(router)(app.recoverPanic(app.authRateLimit(app.authenticate(app.rateLimit(router, app.rateLimiter)(router)))))))))
 }
 
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -99,6 +111,11 @@ func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
+	// Include the per-dependency checks served by /v1/health/ready
+	if app.health != nil {
+		healthResponse.Checks = app.health.Run(ctx).Checks
+	}
+
 	// Determine HTTP status code based on health status
 	statusCode := http.StatusOK
 	if healthResponse.Status == "degraded" {
@@ -18,17 +18,10 @@ import (
 
 // TestRateLimiterShutdown tests that rate limiter cleanup goroutine terminates cleanly
 func TestRateLimiterShutdown(t *testing.T) {
-	cfg := config{
-		limiter: struct {
-			enabled bool
-			rps     float64
-			burst   int
-		}{
-			enabled: true,
-			rps:     10.0,
-			burst:   20,
-		},
-	}
+	var cfg config
+	cfg.limiter.enabled = true
+	cfg.limiter.rps = 10.0
+	cfg.limiter.burst = 20
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelError, "test") // Use ERROR level to reduce test output
 
@@ -52,17 +45,10 @@ func TestRateLimiterShutdown(t *testing.T) {
 
 // TestRateLimiterShutdownDisabled tests shutdown when rate limiting is disabled
 func TestRateLimiterShutdownDisabled(t *testing.T) {
-	cfg := config{
-		limiter: struct {
-			enabled bool
-			rps     float64
-			burst   int
-		}{
-			enabled: false,
-			rps:     10.0,
-			burst:   20,
-		},
-	}
+	var cfg config
+	cfg.limiter.enabled = false
+	cfg.limiter.rps = 10.0
+	cfg.limiter.burst = 20
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelError, "test")
 
@@ -96,14 +82,8 @@ func TestGracefulShutdownWithInFlightRequests(t *testing.T) {
 		}{
 			timeout: 2 * time.Second, // Short timeout for faster tests
 		},
-		limiter: struct {
-			enabled bool
-			rps     float64
-			burst   int
-		}{
-			enabled: false, // Disable rate limiting for simpler test
-		},
 	}
+	cfg.limiter.enabled = false // Disable rate limiting for simpler test
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelError, "test")
 
@@ -215,17 +195,10 @@ func TestShutdownConfiguration(t *testing.T) {
 
 // TestRateLimiterCleanupIntegration tests rate limiter cleanup during shutdown
 func TestRateLimiterCleanupIntegration(t *testing.T) {
-	cfg := config{
-		limiter: struct {
-			enabled bool
-			rps     float64
-			burst   int
-		}{
-			enabled: true,
-			rps:     1.0,
-			burst:   2,
-		},
-	}
+	var cfg config
+	cfg.limiter.enabled = true
+	cfg.limiter.rps = 1.0
+	cfg.limiter.burst = 2
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelError, "test")
 
@@ -265,7 +238,7 @@ type testStatisticsHandler struct {
 	mu sync.RWMutex
 }
 
-func (m *testStatisticsHandler) Record(ctx context.Context, input *data.FizzBuzzInput) error {
+func (m *testStatisticsHandler) Record(ctx context.Context, input *data.FizzBuzzInput, client string) error {
 	return nil
 }
 
@@ -273,6 +246,26 @@ func (m *testStatisticsHandler) GetMostFrequent(ctx context.Context) (*data.Stat
 	return nil, nil
 }
 
+func (m *testStatisticsHandler) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
+func (m *testStatisticsHandler) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
+	return []*data.StatisticsEntry{}, nil
+}
+
+func (m *testStatisticsHandler) GetTopNForClient(ctx context.Context, client string, n int) ([]*data.StatisticsEntry, error) {
+	return []*data.StatisticsEntry{}, nil
+}
+
+func (m *testStatisticsHandler) GetTopClients(ctx context.Context, input *data.FizzBuzzInput, n int) ([]data.ClientHits, error) {
+	return []data.ClientHits{}, nil
+}
+
+func (m *testStatisticsHandler) GetStats(ctx context.Context) (data.StatsSummary, error) {
+	return data.StatsSummary{}, nil
+}
+
 func (m *testStatisticsHandler) GetDatabaseHealth(ctx context.Context) (map[string]interface{}, error) {
 	return map[string]interface{}{
 		"status": "available",
@@ -298,6 +291,10 @@ func (m *testStatisticsHandler) GetPoolStats(ctx context.Context) (*data.PoolSta
 	}, nil
 }
 
+func (m *testStatisticsHandler) Flush(ctx context.Context) error {
+	return nil
+}
+
 func (m *testStatisticsHandler) Close() error {
 	return nil
 }
@@ -329,17 +326,10 @@ func isServerResponding(url string) bool {
 
 // BenchmarkRateLimiterShutdown benchmarks the shutdown performance
 func BenchmarkRateLimiterShutdown(b *testing.B) {
-	cfg := config{
-		limiter: struct {
-			enabled bool
-			rps     float64
-			burst   int
-		}{
-			enabled: true,
-			rps:     10.0,
-			burst:   20,
-		},
-	}
+	var cfg config
+	cfg.limiter.enabled = true
+	cfg.limiter.rps = 10.0
+	cfg.limiter.burst = 20
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelError, "test")
 
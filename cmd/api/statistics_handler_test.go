@@ -36,7 +36,7 @@ func TestStatisticsHandler_WithService(t *testing.T) {
 
 	// Test Record with context
 	input := &data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
-	err := handler.Record(ctx, input)
+	err := handler.Record(ctx, input, "")
 	if err != nil {
 		t.Errorf("Expected no error from Record, got: %v", err)
 	}
@@ -100,7 +100,7 @@ func TestStatisticsHandler_NilService(t *testing.T) {
 
 	// Test Record with nil service - should return error
 	input := &data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
-	err := handler.Record(ctx, input)
+	err := handler.Record(ctx, input, "")
 	if err == nil {
 		t.Error("Expected error when service is nil, got nil")
 	}
@@ -146,7 +146,7 @@ func TestStatisticsHandler_ContextTimeout(t *testing.T) {
 	defer cancel()
 
 	input := &data.FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
-	err := handler.Record(ctx, input)
+	err := handler.Record(ctx, input, "")
 	if err == nil {
 		t.Error("Expected timeout error, got nil")
 	}
@@ -173,6 +173,10 @@ func (m *MockStatisticsRepository) GetMostFrequent(ctx context.Context) (*data.S
 	return nil, nil
 }
 
+func (m *MockStatisticsRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
 func (m *MockStatisticsRepository) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	return []*data.StatisticsEntry{}, nil
 }
@@ -255,6 +255,10 @@ func (m *mockRepositoryForIntegrationTesting) GetMostFrequent(ctx context.Contex
 	}, nil
 }
 
+func (m *mockRepositoryForIntegrationTesting) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
 func (m *mockRepositoryForIntegrationTesting) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	return []*data.StatisticsEntry{}, nil
 }
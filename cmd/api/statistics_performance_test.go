@@ -21,7 +21,7 @@ func BenchmarkStatisticsRecord(b *testing.B) {
 	cfg := getTestConfig()
 	logger := jsonlog.New(io.Discard, jsonlog.LevelError, "test") // Minimize logging for benchmarks
 
-	handler, err := initializePostgreSQLStatistics(cfg, logger)
+	handler, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 	if err != nil {
 		b.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 	}
@@ -41,7 +41,7 @@ func BenchmarkStatisticsRecord(b *testing.B) {
 	b.ResetTimer()
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			err := handler.Record(ctx, input)
+			err := handler.Record(ctx, input, "")
 			if err != nil {
 				b.Errorf("Record failed: %v", err)
 			}
@@ -58,7 +58,7 @@ func BenchmarkStatisticsGetMostFrequent(b *testing.B) {
 	cfg := getTestConfig()
 	logger := jsonlog.New(io.Discard, jsonlog.LevelError, "test") // Minimize logging for benchmarks
 
-	handler, err := initializePostgreSQLStatistics(cfg, logger)
+	handler, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 	if err != nil {
 		b.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 	}
@@ -78,7 +78,7 @@ func BenchmarkStatisticsGetMostFrequent(b *testing.B) {
 
 	// Insert some test data
 	for i := 0; i < 5; i++ {
-		err := handler.Record(ctx, input)
+		err := handler.Record(ctx, input, "")
 		if err != nil {
 			b.Fatalf("Failed to populate test data: %v", err)
 		}
@@ -118,7 +118,7 @@ func BenchmarkConnectionPoolUtilization(b *testing.B) {
 
 			logger := jsonlog.New(io.Discard, jsonlog.LevelError, "test")
 
-			handler, err := initializePostgreSQLStatistics(cfg, logger)
+			handler, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 			if err != nil {
 				b.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 			}
@@ -138,7 +138,7 @@ func BenchmarkConnectionPoolUtilization(b *testing.B) {
 			b.ResetTimer()
 			b.RunParallel(func(pb *testing.PB) {
 				for pb.Next() {
-					err := handler.Record(ctx, input)
+					err := handler.Record(ctx, input, "")
 					if err != nil {
 						b.Errorf("Record failed: %v", err)
 					}
@@ -157,7 +157,7 @@ func BenchmarkMixedOperations(b *testing.B) {
 	cfg := getTestConfig()
 	logger := jsonlog.New(io.Discard, jsonlog.LevelError, "test")
 
-	handler, err := initializePostgreSQLStatistics(cfg, logger)
+	handler, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 	if err != nil {
 		b.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 	}
@@ -176,7 +176,7 @@ func BenchmarkMixedOperations(b *testing.B) {
 
 	// Populate some initial data
 	for i := 0; i < 10; i++ {
-		err := handler.Record(ctx, input)
+		err := handler.Record(ctx, input, "")
 		if err != nil {
 			b.Fatalf("Failed to populate test data: %v", err)
 		}
@@ -197,7 +197,7 @@ func BenchmarkMixedOperations(b *testing.B) {
 				}
 			} else {
 				// Write operation
-				err := handler.Record(ctx, input)
+				err := handler.Record(ctx, input, "")
 				if err != nil {
 					b.Errorf("Record failed: %v", err)
 				}
@@ -215,7 +215,7 @@ func BenchmarkStatisticsWithTimeout(b *testing.B) {
 	cfg := getTestConfig()
 	logger := jsonlog.New(io.Discard, jsonlog.LevelError, "test")
 
-	handler, err := initializePostgreSQLStatistics(cfg, logger)
+	handler, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 	if err != nil {
 		b.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 	}
@@ -235,7 +235,7 @@ func BenchmarkStatisticsWithTimeout(b *testing.B) {
 			// Use realistic timeout like HTTP handlers
 			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 
-			err := handler.Record(ctx, input)
+			err := handler.Record(ctx, input, "")
 			if err != nil {
 				b.Errorf("Record failed: %v", err)
 			}
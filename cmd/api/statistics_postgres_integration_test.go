@@ -23,7 +23,7 @@ func TestPostgreSQLStatisticsInitialization(t *testing.T) {
 	cfg := getTestConfig()
 	logger := getTestLogger()
 
-	handlerInterface, err := initializePostgreSQLStatistics(cfg, logger)
+	handlerInterface, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 	}
@@ -50,7 +50,7 @@ func TestPostgreSQLStatisticsInitialization(t *testing.T) {
 	}
 
 	// Test Record operation
-	err = handler.Record(ctx, input)
+	err = handler.Record(ctx, input, "")
 	if err != nil {
 		t.Errorf("Failed to record statistics: %v", err)
 	}
@@ -81,7 +81,7 @@ func TestConnectionPoolingPerformance(t *testing.T) {
 	cfg := getTestConfig()
 	logger := getTestLogger()
 
-	handler, err := initializePostgreSQLStatistics(cfg, logger)
+	handler, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 	}
@@ -107,7 +107,7 @@ func TestConnectionPoolingPerformance(t *testing.T) {
 				Str2:  "buzz",
 			}
 
-			err := handler.Record(ctx, input)
+			err := handler.Record(ctx, input, "")
 			resultChan <- err
 		}(i)
 	}
@@ -144,7 +144,7 @@ func TestContextTimeoutIntegration(t *testing.T) {
 	cfg := getTestConfig()
 	logger := getTestLogger()
 
-	handler, err := initializePostgreSQLStatistics(cfg, logger)
+	handler, err := initializePostgreSQLStatistics(cfg, logger, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to initialize PostgreSQL statistics: %v", err)
 	}
@@ -162,7 +162,7 @@ func TestContextTimeoutIntegration(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Nanosecond)
 	defer cancel()
 
-	err = handler.Record(ctx, input)
+	err = handler.Record(ctx, input, "")
 	if err == nil {
 		t.Error("Expected timeout error but operation succeeded")
 	}
@@ -53,7 +53,7 @@ func TestStatisticsHandlerRecord(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
 			defer cancel()
 
-			err := tt.handler.Record(ctx, tt.input)
+			err := tt.handler.Record(ctx, tt.input, "")
 
 			if tt.expectError && err == nil {
 				t.Errorf("expected error but got nil")
@@ -153,7 +153,7 @@ func TestContextTimeoutHandling(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
 	defer cancel()
 
-	err := handler.Record(ctx, input)
+	err := handler.Record(ctx, input, "")
 	if err == nil {
 		t.Error("expected timeout error but got nil")
 	}
@@ -186,6 +186,10 @@ func (m *mockRepository) GetMostFrequent(ctx context.Context) (*data.StatisticsE
 	}, nil
 }
 
+func (m *mockRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
 func (m *mockRepository) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	return []*data.StatisticsEntry{}, nil
 }
@@ -230,6 +234,10 @@ func (s *slowMockRepository) GetMostFrequent(ctx context.Context) (*data.Statist
 	}
 }
 
+func (s *slowMockRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*data.StatisticsEntry, error) {
+	return s.GetMostFrequent(ctx)
+}
+
 func (s *slowMockRepository) GetTopN(ctx context.Context, n int) ([]*data.StatisticsEntry, error) {
 	return []*data.StatisticsEntry{}, nil
 }
@@ -0,0 +1,142 @@
+// Command apikey issues, lists and revokes the API keys accepted by the API in api-key auth mode.
+// It connects to the database configured by the same DB_* environment variables as the API.
+//
+//	apikey issue -client acme -name "production"
+//	apikey list
+//	apikey revoke -id 3
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"fizzbuzz/internal/data"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const usage = `usage: apikey <command> [flags]
+
+commands:
+  issue   -client <id> [-name <description>]   issue a key and print it once
+  list                                         list keys, without their secrets
+  revoke  -id <key id>                         revoke a key
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pool, err := openPool(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "apikey:", err)
+		os.Exit(1)
+	}
+	repository := data.NewPostgreSQLAPIKeyRepository(pool, 0)
+	defer repository.Close()
+
+	if err := run(ctx, repository, os.Args[1], os.Args[2:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "apikey:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes one command against repository, writing its output to out
+func run(ctx context.Context, repository data.APIKeyRepository, command string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("apikey "+command, flag.ContinueOnError)
+
+	switch command {
+	case "issue":
+		clientID := fs.String("client", "", "Client ID attached to requests made with the key")
+		name := fs.String("name", "", "Description of the key, e.g. the service or environment using it")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		plaintext, key, err := data.IssueAPIKey(ctx, repository, *clientID, *name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "issued key %d for client %s; store it now, it cannot be shown again:\n%s\n", key.ID, key.ClientID, plaintext)
+		return nil
+
+	case "list":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+
+		keys, err := repository.List(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tCLIENT\tNAME\tPREFIX\tCREATED\tREVOKED")
+		for _, key := range keys {
+			revoked := "-"
+			if key.Revoked() {
+				revoked = key.RevokedAt.UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n",
+				key.ID, key.ClientID, key.Name, key.Prefix, key.CreatedAt.UTC().Format(time.RFC3339), revoked)
+		}
+		return tw.Flush()
+
+	case "revoke":
+		id := fs.Int64("id", 0, "ID of the key to revoke, as shown by list")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *id <= 0 {
+			return errors.New("-id must be provided")
+		}
+
+		if err := repository.Revoke(ctx, *id); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "revoked key %d\n", *id)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q\n%s", command, usage)
+	}
+}
+
+// openPool connects to the database described by the DB_* environment variables
+func openPool(ctx context.Context) (*pgxpool.Pool, error) {
+	port, err := strconv.Atoi(getEnv("DB_PORT", "5432"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_PORT: %w", err)
+	}
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		getEnv("DB_HOST", "localhost"), port, getEnv("DB_USER", "fizzbuzz_user"),
+		getEnv("DB_PASSWORD", "fizzbuzz_pass"), getEnv("DB_NAME", "fizzbuzz"), getEnv("DB_SSL_MODE", "disable"))
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection pool: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+	return pool, nil
+}
+
+// getEnv returns the environment variable key, or defaultValue when it is not set
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"fizzbuzz/internal/data"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	repository := data.NewMemoryAPIKeyRepository()
+
+	var out bytes.Buffer
+	if err := run(ctx, repository, "issue", []string{"-client", "acme", "-name", "production"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	plaintext := lines[len(lines)-1]
+	if !strings.HasPrefix(plaintext, data.APIKeyPrefix) {
+		t.Fatalf("expected the key on the last line, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := run(ctx, repository, "revoke", []string{"-id", "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	key, err := repository.GetByHash(ctx, data.HashAPIKey(plaintext))
+	if err != nil || !key.Revoked() {
+		t.Errorf("expected the issued key to be revoked, got %+v, %v", key, err)
+	}
+
+	out.Reset()
+	if err := run(ctx, repository, "list", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if listing := out.String(); !strings.Contains(listing, "acme") || strings.Contains(listing, plaintext) {
+		t.Errorf("expected the listing to show the client but not the key, got %q", listing)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		args    []string
+		wantErr string
+	}{
+		{"issue without client", "issue", nil, "client id must be provided"},
+		{"revoke without id", "revoke", nil, "-id must be provided"},
+		{"revoke unknown id", "revoke", []string{"-id", "42"}, data.ErrAPIKeyNotFound.Error()},
+		{"unknown command", "rotate", nil, `unknown command "rotate"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(ctx, repository, tt.command, tt.args, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// APIKeyPrefix starts every issued API key so leaked keys are easy to recognise
+const APIKeyPrefix = "fb_"
+
+// apiKeyDisplayLength is how many leading characters of a key are kept to identify it in listings
+const apiKeyDisplayLength = 11
+
+// ErrAPIKeyNotFound is returned when no API key matches a hash or ID
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKey describes an issued API key. The key itself is never stored, only its SHA-256.
+type APIKey struct {
+	// ID identifies the key for revocation
+	ID int64 `json:"id"`
+	// ClientID is the identity attached to requests made with the key
+	ClientID string `json:"client_id"`
+	// Name is a free-form description, e.g. the service or environment using the key
+	Name string `json:"name"`
+	// Prefix is the first characters of the key
+	Prefix string `json:"prefix"`
+	// CreatedAt is when the key was issued
+	CreatedAt time.Time `json:"created_at"`
+	// RevokedAt is when the key was revoked, nil while it is active
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key has been revoked
+func (k *APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// APIKeyRepository stores API keys by hash
+type APIKeyRepository interface {
+	// Insert stores key under hash and sets its ID and CreatedAt
+	Insert(ctx context.Context, key *APIKey, hash string) error
+	// GetByHash returns the key with the given hash, revoked or not, or ErrAPIKeyNotFound
+	GetByHash(ctx context.Context, hash string) (*APIKey, error)
+	// Revoke marks the key revoked; revoking a revoked key is not an error
+	Revoke(ctx context.Context, id int64) error
+	// List returns every key, oldest first
+	List(ctx context.Context) ([]*APIKey, error)
+	// Close releases the repository's connections
+	Close() error
+}
+
+// GenerateAPIKey returns a new random API key and its hash
+func GenerateAPIKey() (plaintext, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	plaintext = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return plaintext, HashAPIKey(plaintext), nil
+}
+
+// HashAPIKey returns the hex SHA-256 an API key is stored and looked up by
+func HashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueAPIKey generates a key for clientID, stores its hash and returns the plaintext,
+// which cannot be recovered afterwards
+func IssueAPIKey(ctx context.Context, repository APIKeyRepository, clientID, name string) (string, *APIKey, error) {
+	clientID = strings.TrimSpace(clientID)
+	if clientID == "" {
+		return "", nil, errors.New("client id must be provided")
+	}
+
+	plaintext, hash, err := GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := &APIKey{ClientID: clientID, Name: name, Prefix: plaintext[:apiKeyDisplayLength]}
+	if err := repository.Insert(ctx, key, hash); err != nil {
+		return "", nil, err
+	}
+	return plaintext, key, nil
+}
+
+// PostgreSQLAPIKeyRepository implements APIKeyRepository on the api_keys table
+type PostgreSQLAPIKeyRepository struct {
+	pool    *pgxpool.Pool
+	timeout time.Duration
+}
+
+// NewPostgreSQLAPIKeyRepository creates a repository using pool, bounding each query by timeout
+func NewPostgreSQLAPIKeyRepository(pool *pgxpool.Pool, timeout time.Duration) *PostgreSQLAPIKeyRepository {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &PostgreSQLAPIKeyRepository{pool: pool, timeout: timeout}
+}
+
+// Insert implements APIKeyRepository.Insert
+func (r *PostgreSQLAPIKeyRepository) Insert(ctx context.Context, key *APIKey, hash string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	err := r.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (client_id, name, key_prefix, key_hash)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, key.ClientID, key.Name, key.Prefix, hash).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert api key: %w", err)
+	}
+	return nil
+}
+
+// GetByHash implements APIKeyRepository.GetByHash
+func (r *PostgreSQLAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var key APIKey
+	err := r.pool.QueryRow(ctx, `
+		SELECT id, client_id, name, key_prefix, created_at, revoked_at
+		FROM api_keys
+		WHERE key_hash = $1
+	`, hash).Scan(&key.ID, &key.ClientID, &key.Name, &key.Prefix, &key.CreatedAt, &key.RevokedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up api key: %w", err)
+	}
+	return &key, nil
+}
+
+// Revoke implements APIKeyRepository.Revoke
+func (r *PostgreSQLAPIKeyRepository) Revoke(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// List implements APIKeyRepository.List
+func (r *PostgreSQLAPIKeyRepository) List(ctx context.Context) ([]*APIKey, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, client_id, name, key_prefix, created_at, revoked_at
+		FROM api_keys
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		var key APIKey
+		if err := rows.Scan(&key.ID, &key.ClientID, &key.Name, &key.Prefix, &key.CreatedAt, &key.RevokedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, &key)
+	}
+	return keys, rows.Err()
+}
+
+// Close implements APIKeyRepository.Close by closing the connection pool
+func (r *PostgreSQLAPIKeyRepository) Close() error {
+	r.pool.Close()
+	return nil
+}
+
+// MemoryAPIKeyRepository implements APIKeyRepository in process memory, for tests and development
+type MemoryAPIKeyRepository struct {
+	mu     sync.RWMutex
+	nextID int64
+	keys   map[string]*APIKey // By hash
+}
+
+// NewMemoryAPIKeyRepository creates an empty repository
+func NewMemoryAPIKeyRepository() *MemoryAPIKeyRepository {
+	return &MemoryAPIKeyRepository{keys: make(map[string]*APIKey)}
+}
+
+// Insert implements APIKeyRepository.Insert
+func (m *MemoryAPIKeyRepository) Insert(ctx context.Context, key *APIKey, hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.keys[hash]; exists {
+		return errors.New("api key hash already exists")
+	}
+	m.nextID++
+	key.ID = m.nextID
+	key.CreatedAt = time.Now()
+
+	stored := *key
+	m.keys[hash] = &stored
+	return nil
+}
+
+// GetByHash implements APIKeyRepository.GetByHash
+func (m *MemoryAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, exists := m.keys[hash]
+	if !exists {
+		return nil, ErrAPIKeyNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+// Revoke implements APIKeyRepository.Revoke
+func (m *MemoryAPIKeyRepository) Revoke(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range m.keys {
+		if key.ID == id {
+			if key.RevokedAt == nil {
+				now := time.Now()
+				key.RevokedAt = &now
+			}
+			return nil
+		}
+	}
+	return ErrAPIKeyNotFound
+}
+
+// List implements APIKeyRepository.List
+func (m *MemoryAPIKeyRepository) List(ctx context.Context) ([]*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		found := *key
+		keys = append(keys, &found)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+// Close implements APIKeyRepository.Close
+func (m *MemoryAPIKeyRepository) Close() error {
+	return nil
+}
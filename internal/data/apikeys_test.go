@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	first, firstHash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(first, APIKeyPrefix) || len(first) != len(APIKeyPrefix)+43 {
+		t.Errorf("unexpected key format %q", first)
+	}
+	if first == second {
+		t.Error("expected two generated keys to differ")
+	}
+	if firstHash != HashAPIKey(first) || len(firstHash) != 64 {
+		t.Errorf("expected the hex SHA-256 of the key, got %q", firstHash)
+	}
+}
+
+func TestIssueAndRevokeAPIKey(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryAPIKeyRepository()
+
+	if _, _, err := IssueAPIKey(ctx, repository, "  ", "empty"); err == nil {
+		t.Error("expected an error for an empty client id")
+	}
+
+	plaintext, issued, err := IssueAPIKey(ctx, repository, "acme", "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued.ID == 0 || issued.Prefix != plaintext[:apiKeyDisplayLength] || issued.CreatedAt.IsZero() {
+		t.Errorf("unexpected issued key %+v", issued)
+	}
+
+	found, err := repository.GetByHash(ctx, HashAPIKey(plaintext))
+	if err != nil || found.ClientID != "acme" || found.Revoked() {
+		t.Fatalf("expected an active key for acme, got %+v, %v", found, err)
+	}
+	if _, err := repository.GetByHash(ctx, HashAPIKey("fb_unknown")); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("expected ErrAPIKeyNotFound, got %v", err)
+	}
+
+	// Revocation is idempotent and keeps the key so it can be told apart from an unknown one
+	for i := 0; i < 2; i++ {
+		if err := repository.Revoke(ctx, issued.ID); err != nil {
+			t.Fatalf("revoke %d: %v", i, err)
+		}
+	}
+	found, err = repository.GetByHash(ctx, HashAPIKey(plaintext))
+	if err != nil || !found.Revoked() {
+		t.Errorf("expected a revoked key, got %+v, %v", found, err)
+	}
+	if err := repository.Revoke(ctx, 99); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("expected ErrAPIKeyNotFound revoking an unknown id, got %v", err)
+	}
+
+	keys, err := repository.List(ctx)
+	if err != nil || len(keys) != 1 || keys[0].ID != issued.ID {
+		t.Errorf("expected the one issued key, got %v, %v", keys, err)
+	}
+}
@@ -0,0 +1,355 @@
+// Package data provides a background statistics writer that takes database writes off the request path.
+// Aggregates hits per parameter combination in memory and flushes them in batches.
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"fizzbuzz/internal/jsonlog"
+)
+
+// Errors returned by BufferedStatisticsRepository.Record
+var (
+	ErrWriteBufferFull = errors.New("statistics write buffer is full")
+	ErrWriterClosed    = errors.New("statistics writer is closed")
+)
+
+// BufferedWriterConfig holds configuration for the background statistics writer
+type BufferedWriterConfig struct {
+	// BatchSize is the number of distinct parameter combinations that triggers a flush,
+	// and the maximum number of rows written per upsert
+	BatchSize int
+	// BufferSize is the number of hits that can be queued before Record starts rejecting them
+	BufferSize int
+	// FlushInterval is the maximum time a hit waits in memory before being written
+	FlushInterval time.Duration
+	// WriteTimeout bounds each background flush
+	WriteTimeout time.Duration
+}
+
+// DefaultBufferedWriterConfig returns a sensible default configuration
+func DefaultBufferedWriterConfig() BufferedWriterConfig {
+	return BufferedWriterConfig{
+		BatchSize:     100,             // Flush once 100 distinct combinations are pending
+		BufferSize:    1000,            // Queue up to 1000 hits between flushes
+		FlushInterval: 1 * time.Second, // Flush at least once per second
+		WriteTimeout:  5 * time.Second, // 5s timeout for each flush
+	}
+}
+
+// BufferedStatisticsRepository wraps a StatisticsRepository so that Record never waits on the database.
+// Hits are queued, aggregated per parameters hash by a single writer goroutine, and flushed with
+// BatchRecorder when the batch fills up or the flush interval elapses. Reads go straight to the
+// wrapped repository, so they may lag writes by up to FlushInterval.
+type BufferedStatisticsRepository struct {
+	repository StatisticsRepository
+	config     BufferedWriterConfig
+	logger     *jsonlog.Logger
+
+	// hits queues recorded inputs for the writer goroutine
+	hits chan bufferedHit
+	// flushRequests asks the writer goroutine to flush now and report the result
+	flushRequests chan flushRequest
+	// quit tells the writer goroutine to drain and exit; done is closed once it has
+	quit      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// reports counts rejected hits, and flushed ones unless the wrapped repository reports them
+	reports   writeReports
+	delegated bool
+}
+
+// bufferedHit is one queued hit and the client it is attributed to, if any
+type bufferedHit struct {
+	input  FizzBuzzInput
+	client string
+}
+
+// flushRequest carries a caller's deadline to the writer goroutine and the flush result back
+type flushRequest struct {
+	ctx   context.Context
+	reply chan error
+}
+
+// NewBufferedStatisticsRepository creates a buffered repository and starts its writer goroutine.
+// Non-positive configuration values fall back to DefaultBufferedWriterConfig.
+func NewBufferedStatisticsRepository(repository StatisticsRepository, config BufferedWriterConfig, logger *jsonlog.Logger) *BufferedStatisticsRepository {
+	defaults := DefaultBufferedWriterConfig()
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaults.BufferSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = defaults.FlushInterval
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = defaults.WriteTimeout
+	}
+
+	br := &BufferedStatisticsRepository{
+		repository:    repository,
+		config:        config,
+		logger:        logger,
+		hits:          make(chan bufferedHit, config.BufferSize),
+		flushRequests: make(chan flushRequest),
+		quit:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go br.run()
+
+	return br
+}
+
+// Record implements StatisticsRepository.Record by queueing the hit for the writer goroutine.
+// The returned entry carries no hit count, since the write has not happened yet.
+// Returns ErrWriteBufferFull instead of blocking when the queue is full.
+func (br *BufferedStatisticsRepository) Record(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
+	return br.enqueue(bufferedHit{input: input.Normalized()})
+}
+
+// RecordForClient implements ClientStatisticsRepository.RecordForClient by queueing the hit
+// with its client. The attribution is written with the batch, through the wrapped repository's
+// RecordForClient when it does not implement BatchRecorder.
+func (br *BufferedStatisticsRepository) RecordForClient(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
+	return br.enqueue(bufferedHit{input: input.Normalized(), client: client})
+}
+
+// enqueue hands hit to the writer goroutine without blocking
+func (br *BufferedStatisticsRepository) enqueue(hit bufferedHit) (*StatisticsEntry, error) {
+	select {
+	case <-br.quit:
+		br.reports.report(WriteFailed, 1)
+		return nil, ErrWriterClosed
+	default:
+	}
+
+	select {
+	case br.hits <- hit:
+		return &StatisticsEntry{
+			ParametersHash: hit.input.GenerateStatsKey(),
+			Parameters:     hit.input,
+		}, nil
+	default:
+		br.reports.report(WriteFailed, 1)
+		return nil, ErrWriteBufferFull
+	}
+}
+
+// SetWriteObserver implements WriteReporter. Hits rejected by the queue are reported here; flushed
+// hits are reported by the wrapped repository when it is a WriteReporter, and here otherwise,
+// those in failed flushes as dropped. Must be called before the first Record.
+func (br *BufferedStatisticsRepository) SetWriteObserver(observer WriteObserver) {
+	br.reports.set(observer)
+	if reporter, ok := br.repository.(WriteReporter); ok {
+		reporter.SetWriteObserver(observer)
+		br.delegated = true
+	}
+}
+
+// Flush writes every queued hit now and waits for the result.
+// Used during graceful shutdown to drain the buffer before closing the database.
+func (br *BufferedStatisticsRepository) Flush(ctx context.Context) error {
+	req := flushRequest{ctx: ctx, reply: make(chan error, 1)}
+
+	select {
+	case br.flushRequests <- req:
+	case <-br.done:
+		return nil // Writer already drained on Close
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-req.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// GetMostFrequent implements StatisticsRepository.GetMostFrequent
+func (br *BufferedStatisticsRepository) GetMostFrequent(ctx context.Context) (*StatisticsEntry, error) {
+	return br.repository.GetMostFrequent(ctx)
+}
+
+// GetMostFrequentInWindow implements StatisticsRepository.GetMostFrequentInWindow
+func (br *BufferedStatisticsRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*StatisticsEntry, error) {
+	return br.repository.GetMostFrequentInWindow(ctx, from, to)
+}
+
+// GetTopN implements StatisticsRepository.GetTopN
+func (br *BufferedStatisticsRepository) GetTopN(ctx context.Context, n int) ([]*StatisticsEntry, error) {
+	return br.repository.GetTopN(ctx, n)
+}
+
+// GetTopNForClient implements ClientStatisticsRepository.GetTopNForClient.
+// Returns ErrClientStatisticsUnsupported when the wrapped repository does not attribute hits.
+func (br *BufferedStatisticsRepository) GetTopNForClient(ctx context.Context, client string, n int) ([]*StatisticsEntry, error) {
+	repository, ok := br.repository.(ClientStatisticsRepository)
+	if !ok {
+		return nil, ErrClientStatisticsUnsupported
+	}
+	return repository.GetTopNForClient(ctx, client, n)
+}
+
+// GetTopClients implements ClientStatisticsRepository.GetTopClients.
+// Returns ErrClientStatisticsUnsupported when the wrapped repository does not attribute hits.
+func (br *BufferedStatisticsRepository) GetTopClients(ctx context.Context, input FizzBuzzInput, n int) ([]ClientHits, error) {
+	repository, ok := br.repository.(ClientStatisticsRepository)
+	if !ok {
+		return nil, ErrClientStatisticsUnsupported
+	}
+	return repository.GetTopClients(ctx, input, n)
+}
+
+// GetStats implements StatisticsRepository.GetStats
+func (br *BufferedStatisticsRepository) GetStats(ctx context.Context) (StatsSummary, error) {
+	return br.repository.GetStats(ctx)
+}
+
+// GetPoolStats implements StatisticsRepository.GetPoolStats
+func (br *BufferedStatisticsRepository) GetPoolStats(ctx context.Context) (*PoolStats, error) {
+	return br.repository.GetPoolStats(ctx)
+}
+
+// Close implements StatisticsRepository.Close.
+// Stops the writer goroutine after a final flush, then closes the wrapped repository.
+func (br *BufferedStatisticsRepository) Close() error {
+	br.closeOnce.Do(func() {
+		close(br.quit)
+	})
+	<-br.done
+
+	return br.repository.Close()
+}
+
+// run is the writer goroutine: it aggregates queued hits and flushes them on size, interval,
+// explicit Flush, or shutdown.
+func (br *BufferedStatisticsRepository) run() {
+	defer close(br.done)
+
+	pending := make(map[string]*StatisticsDelta)
+	ticker := time.NewTicker(br.config.FlushInterval)
+	defer ticker.Stop()
+
+	add := func(hit bufferedHit) {
+		key := hit.input.GenerateStatsKey()
+		delta, exists := pending[key]
+		if !exists {
+			delta = &StatisticsDelta{Input: hit.input}
+			pending[key] = delta
+		}
+		delta.add(hit.client, 1)
+	}
+
+	// drain moves everything currently queued into pending without blocking
+	drain := func() {
+		for {
+			select {
+			case hit := <-br.hits:
+				add(hit)
+			default:
+				return
+			}
+		}
+	}
+
+	flush := func(ctx context.Context) error {
+		err := br.flush(ctx, pending)
+		pending = make(map[string]*StatisticsDelta)
+		return err
+	}
+
+	background := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), br.config.WriteTimeout)
+		defer cancel()
+		flush(ctx)
+	}
+
+	for {
+		select {
+		case hit := <-br.hits:
+			add(hit)
+			if len(pending) >= br.config.BatchSize {
+				background()
+			}
+		case <-ticker.C:
+			background()
+		case req := <-br.flushRequests:
+			drain()
+			req.reply <- flush(req.ctx)
+		case <-br.quit:
+			drain()
+			background()
+			return
+		}
+	}
+}
+
+// flush writes pending in chunks of at most BatchSize rows.
+// Failed chunks are logged and dropped: statistics are best-effort, as on the synchronous path.
+func (br *BufferedStatisticsRepository) flush(ctx context.Context, pending map[string]*StatisticsDelta) error {
+	if len(pending) == 0 {
+		return nil
+	}
+
+	capacity := len(pending)
+	if capacity > br.config.BatchSize {
+		capacity = br.config.BatchSize
+	}
+	batch := make([]StatisticsDelta, 0, capacity)
+	var firstErr error
+
+	write := func() {
+		err := recordBatch(ctx, br.repository, batch)
+		hits := deltaHits(batch)
+		if !br.delegated {
+			if err != nil {
+				br.reports.report(WriteFailed, hits)
+			} else {
+				br.reports.report(WriteSucceeded, hits)
+			}
+		}
+
+		if err != nil {
+			if br.logger != nil {
+				br.logger.WarnWithContext(ctx, "statistics batch flush failed, dropping hits",
+					"error", err,
+					"rows", len(batch),
+					"dropped_hits", hits,
+					"operation", "Flush")
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for _, delta := range pending {
+		batch = append(batch, *delta)
+		if len(batch) == br.config.BatchSize {
+			write()
+		}
+	}
+	if len(batch) > 0 {
+		write()
+	}
+
+	return firstErr
+}
+
+// Compile-time verification that BufferedStatisticsRepository implements StatisticsRepository,
+// ClientStatisticsRepository and WriteReporter
+var (
+	_ StatisticsRepository       = (*BufferedStatisticsRepository)(nil)
+	_ ClientStatisticsRepository = (*BufferedStatisticsRepository)(nil)
+	_ WriteReporter              = (*BufferedStatisticsRepository)(nil)
+)
@@ -0,0 +1,303 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecordingRepository records every batch written through BatchRecorder.
+// When block is set, RecordBatch signals started and waits for block to be closed.
+type batchRecordingRepository struct {
+	*MockStatisticsRepository
+	mu      sync.Mutex
+	batches [][]StatisticsDelta
+	started chan struct{}
+	block   chan struct{}
+}
+
+func newBatchRecordingRepository() *batchRecordingRepository {
+	return &batchRecordingRepository{MockStatisticsRepository: NewMockStatisticsRepository()}
+}
+
+func (b *batchRecordingRepository) RecordBatch(ctx context.Context, batch []StatisticsDelta) error {
+	if b.block != nil {
+		b.started <- struct{}{}
+		<-b.block
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches = append(b.batches, append([]StatisticsDelta(nil), batch...))
+	return nil
+}
+
+// hits returns the total hits written per parameters hash
+func (b *batchRecordingRepository) hits() map[string]int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hits := make(map[string]int)
+	for _, batch := range b.batches {
+		for _, delta := range batch {
+			hits[delta.Input.GenerateStatsKey()] += delta.Hits
+		}
+	}
+	return hits
+}
+
+func (b *batchRecordingRepository) batchCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.batches)
+}
+
+// waitFor polls cond until it holds or the deadline passes
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBufferedStatisticsRepository(t *testing.T) {
+	ctx := context.Background()
+	fizzbuzz := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	foobar := FizzBuzzInput{Int1: 2, Int2: 7, Limit: 10, Str1: "foo", Str2: "bar"}
+	hourly := BufferedWriterConfig{BatchSize: 100, BufferSize: 100, FlushInterval: time.Hour}
+
+	t.Run("aggregates hits per parameters hash", func(t *testing.T) {
+		underlying := newBatchRecordingRepository()
+		repo := NewBufferedStatisticsRepository(underlying, hourly, nil)
+		defer repo.Close()
+
+		for _, input := range []FizzBuzzInput{fizzbuzz, foobar, fizzbuzz, fizzbuzz} {
+			entry, err := repo.Record(ctx, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry.ParametersHash != input.GenerateStatsKey() {
+				t.Errorf("unexpected hash %q", entry.ParametersHash)
+			}
+		}
+
+		if err := repo.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		if n := underlying.batchCount(); n != 1 {
+			t.Fatalf("expected a single batch, got %d", n)
+		}
+		hits := underlying.hits()
+		if hits[fizzbuzz.GenerateStatsKey()] != 3 || hits[foobar.GenerateStatsKey()] != 1 {
+			t.Errorf("unexpected aggregated hits: %v", hits)
+		}
+	})
+
+	t.Run("flushes when the batch fills", func(t *testing.T) {
+		underlying := newBatchRecordingRepository()
+		repo := NewBufferedStatisticsRepository(underlying, BufferedWriterConfig{BatchSize: 2, BufferSize: 100, FlushInterval: time.Hour}, nil)
+		defer repo.Close()
+
+		repo.Record(ctx, fizzbuzz)
+		repo.Record(ctx, foobar)
+
+		waitFor(t, func() bool { return underlying.batchCount() == 1 })
+	})
+
+	t.Run("flushes on interval", func(t *testing.T) {
+		underlying := newBatchRecordingRepository()
+		repo := NewBufferedStatisticsRepository(underlying, BufferedWriterConfig{FlushInterval: 10 * time.Millisecond}, nil)
+		defer repo.Close()
+
+		repo.Record(ctx, fizzbuzz)
+
+		waitFor(t, func() bool { return underlying.hits()[fizzbuzz.GenerateStatsKey()] == 1 })
+	})
+
+	t.Run("writes at most BatchSize rows per upsert", func(t *testing.T) {
+		underlying := newBatchRecordingRepository()
+		repo := NewBufferedStatisticsRepository(underlying, BufferedWriterConfig{BatchSize: 2, BufferSize: 100, FlushInterval: time.Hour}, nil)
+		defer repo.Close()
+
+		for limit := 1; limit <= 5; limit++ {
+			repo.Record(ctx, FizzBuzzInput{Int1: 3, Int2: 5, Limit: limit, Str1: "fizz", Str2: "buzz"})
+		}
+		repo.Flush(ctx)
+
+		underlying.mu.Lock()
+		defer underlying.mu.Unlock()
+		total := 0
+		for _, batch := range underlying.batches {
+			if len(batch) > 2 {
+				t.Errorf("batch of %d rows exceeds batch size", len(batch))
+			}
+			total += len(batch)
+		}
+		if total != 5 {
+			t.Errorf("expected 5 rows written, got %d", total)
+		}
+	})
+
+	t.Run("rejects hits when the buffer is full", func(t *testing.T) {
+		underlying := newBatchRecordingRepository()
+		underlying.started = make(chan struct{}, 10)
+		underlying.block = make(chan struct{})
+		repo := NewBufferedStatisticsRepository(underlying, BufferedWriterConfig{BatchSize: 1, BufferSize: 1, FlushInterval: time.Hour}, nil)
+
+		// The first hit fills the batch and blocks the writer inside RecordBatch
+		repo.Record(ctx, fizzbuzz)
+		<-underlying.started
+
+		if _, err := repo.Record(ctx, foobar); err != nil {
+			t.Fatalf("expected queued hit, got %v", err)
+		}
+		if _, err := repo.Record(ctx, foobar); !errors.Is(err, ErrWriteBufferFull) {
+			t.Errorf("expected ErrWriteBufferFull, got %v", err)
+		}
+
+		close(underlying.block)
+		repo.Close()
+	})
+
+	t.Run("close drains pending hits", func(t *testing.T) {
+		underlying := newBatchRecordingRepository()
+		repo := NewBufferedStatisticsRepository(underlying, hourly, nil)
+
+		repo.Record(ctx, fizzbuzz)
+		repo.Record(ctx, fizzbuzz)
+		if err := repo.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if hits := underlying.hits()[fizzbuzz.GenerateStatsKey()]; hits != 2 {
+			t.Errorf("expected 2 hits written on close, got %d", hits)
+		}
+		if _, err := repo.Record(ctx, fizzbuzz); !errors.Is(err, ErrWriterClosed) {
+			t.Errorf("expected ErrWriterClosed after close, got %v", err)
+		}
+		if err := repo.Flush(ctx); err != nil {
+			t.Errorf("expected flush after close to be a no-op, got %v", err)
+		}
+	})
+
+	t.Run("falls back to Record without BatchRecorder", func(t *testing.T) {
+		underlying := NewMockStatisticsRepository()
+		repo := NewBufferedStatisticsRepository(underlying, hourly, nil)
+		defer repo.Close()
+
+		for i := 0; i < 3; i++ {
+			repo.Record(ctx, fizzbuzz)
+		}
+		if err := repo.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		most, err := repo.GetMostFrequent(ctx)
+		if err != nil || most == nil || most.Hits != 3 {
+			t.Errorf("expected 3 hits through Record, got %+v, %v", most, err)
+		}
+	})
+
+	t.Run("carries client attribution in the batch", func(t *testing.T) {
+		underlying := newBatchRecordingRepository()
+		repo := NewBufferedStatisticsRepository(underlying, hourly, nil)
+		defer repo.Close()
+
+		repo.RecordForClient(ctx, fizzbuzz, "acme")
+		repo.RecordForClient(ctx, fizzbuzz, "acme")
+		repo.RecordForClient(ctx, fizzbuzz, "192.0.2.1")
+		repo.Record(ctx, fizzbuzz)
+		if err := repo.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		underlying.mu.Lock()
+		defer underlying.mu.Unlock()
+		if len(underlying.batches) != 1 || len(underlying.batches[0]) != 1 {
+			t.Fatalf("expected one row, got %+v", underlying.batches)
+		}
+		delta := underlying.batches[0][0]
+		if delta.Hits != 4 || delta.Clients["acme"] != 2 || delta.Clients["192.0.2.1"] != 1 || len(delta.Clients) != 2 {
+			t.Errorf("unexpected delta: %+v", delta)
+		}
+	})
+
+	t.Run("attributes hits through RecordForClient without BatchRecorder", func(t *testing.T) {
+		underlying := NewMemoryStatisticsRepository()
+		repo := NewBufferedStatisticsRepository(underlying, hourly, nil)
+		defer repo.Close()
+
+		repo.RecordForClient(ctx, fizzbuzz, "acme")
+		repo.RecordForClient(ctx, fizzbuzz, "acme")
+		repo.Record(ctx, fizzbuzz)
+		if err := repo.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		clients, err := repo.GetTopClients(ctx, fizzbuzz, 10)
+		if err != nil || len(clients) != 1 || clients[0].Hits != 2 {
+			t.Errorf("expected acme with 2 hits, got %+v, %v", clients, err)
+		}
+		if most, _ := repo.GetMostFrequent(ctx); most == nil || most.Hits != 3 {
+			t.Errorf("expected 3 hits in total, got %+v", most)
+		}
+	})
+
+	t.Run("flush reports write errors", func(t *testing.T) {
+		underlying := NewMockStatisticsRepository()
+		underlying.recordFunc = func(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
+			return nil, errors.New("database unavailable")
+		}
+		repo := NewBufferedStatisticsRepository(underlying, hourly, nil)
+		defer repo.Close()
+
+		repo.Record(ctx, fizzbuzz)
+		if err := repo.Flush(ctx); err == nil {
+			t.Error("expected flush error")
+		}
+	})
+
+	t.Run("reports flushed and dropped hits", func(t *testing.T) {
+		failing := false
+		underlying := NewMockStatisticsRepository()
+		underlying.recordFunc = func(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
+			if failing {
+				return nil, errors.New("database unavailable")
+			}
+			return &StatisticsEntry{Parameters: input, Hits: 1}, nil
+		}
+		repo := NewBufferedStatisticsRepository(underlying, hourly, nil)
+
+		var mu sync.Mutex
+		results := make(map[string]int)
+		repo.SetWriteObserver(func(result string, hits int) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[result] += hits
+		})
+
+		repo.Record(ctx, fizzbuzz)
+		repo.Record(ctx, fizzbuzz)
+		repo.Flush(ctx)
+
+		failing = true
+		repo.Record(ctx, foobar)
+		repo.Flush(ctx)
+
+		repo.Close()
+		repo.Record(ctx, foobar)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if results[WriteSucceeded] != 2 || results[WriteFailed] != 2 || len(results) != 2 {
+			t.Errorf("expected 2 hits written and 2 dropped, got %v", results)
+		}
+	})
+}
@@ -5,6 +5,7 @@ package data
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -35,16 +36,72 @@ func (s CircuitBreakerState) String() string {
 	}
 }
 
+// TripPolicy selects how failures open a closed circuit breaker
+type TripPolicy int
+
+const (
+	// TripConsecutiveFailures opens the circuit after FailureThreshold failures in a row;
+	// any success resets the count
+	TripConsecutiveFailures TripPolicy = iota
+	// TripFailureRate opens the circuit when the share of failed calls over the last Window
+	// reaches FailureRateThreshold, once the window holds at least MinimumRequests calls
+	TripFailureRate
+)
+
+// String returns the name accepted by ParseTripPolicy
+func (p TripPolicy) String() string {
+	switch p {
+	case TripConsecutiveFailures:
+		return "consecutive"
+	case TripFailureRate:
+		return "failure-rate"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseTripPolicy parses "consecutive" or "failure-rate"
+func ParseTripPolicy(s string) (TripPolicy, error) {
+	switch s {
+	case "consecutive":
+		return TripConsecutiveFailures, nil
+	case "failure-rate":
+		return TripFailureRate, nil
+	default:
+		return 0, fmt.Errorf("unknown circuit breaker policy %q (want consecutive or failure-rate)", s)
+	}
+}
+
 // CircuitBreakerConfig holds configuration for the circuit breaker
 type CircuitBreakerConfig struct {
-	// FailureThreshold is the number of failures before opening the circuit
+	// Policy selects how failures open the circuit (default TripConsecutiveFailures)
+	Policy TripPolicy
+	// FailureThreshold is the number of consecutive failures before opening the circuit
+	// under TripConsecutiveFailures
 	FailureThreshold int
+	// FailureRateThreshold is the failure ratio in (0, 1] that opens the circuit under TripFailureRate
+	FailureRateThreshold float64
+	// Window is the rolling period over which TripFailureRate measures the failure ratio
+	Window time.Duration
+	// MinimumRequests is the number of calls Window must hold before TripFailureRate can open
+	MinimumRequests int
+	// SlowCallThreshold, if positive, counts successful calls that take at least this long as
+	// failures. The caller still receives the slow call's result.
+	SlowCallThreshold time.Duration
 	// RecoveryTimeout is the time to wait before transitioning to half-open
 	RecoveryTimeout time.Duration
 	// SuccessThreshold is the number of successes needed in half-open to close
 	SuccessThreshold int
+	// MaxHalfOpenCalls is the number of probe calls allowed to run at once in half-open;
+	// further calls are rejected with ErrCircuitBreakerProbeLimit
+	MaxHalfOpenCalls int
 	// Timeout is the maximum time to wait for an operation
 	Timeout time.Duration
+	// Name identifies the breaker in state change notifications, e.g. "read" or "write"
+	Name string
+	// OnStateChange, if set, is called after every transition between states.
+	// It runs synchronously outside the breaker's lock, so it must not block.
+	OnStateChange func(name string, from, to CircuitBreakerState)
 }
 
 // DefaultCircuitBreakerConfig returns a sensible default configuration
@@ -53,7 +110,13 @@ func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
 		FailureThreshold: 5,                // Open after 5 failures
 		RecoveryTimeout:  30 * time.Second, // Wait 30s before trying again
 		SuccessThreshold: 3,                // Need 3 successes to close
+		MaxHalfOpenCalls: 1,                // Probe with one call at a time
 		Timeout:          5 * time.Second,  // 5s timeout for operations
+
+		// Used only by TripFailureRate
+		FailureRateThreshold: 0.5,              // Open when half the calls fail
+		Window:               60 * time.Second, // Measured over the last minute
+		MinimumRequests:      10,               // Once at least 10 calls were made
 	}
 }
 
@@ -64,16 +127,34 @@ type CircuitBreaker struct {
 	failures      int
 	successes     int
 	lastFailTime  time.Time
+	probes        int // half-open calls in flight
+	window        *rollingWindow
 	mu            sync.RWMutex
 	fallbackFunc  func(ctx context.Context) (interface{}, error)
 	healthChecker func(ctx context.Context) error
 }
 
-// NewCircuitBreaker creates a new circuit breaker with the given configuration
+// NewCircuitBreaker creates a new circuit breaker with the given configuration.
+// Non-positive TripFailureRate and MaxHalfOpenCalls settings fall back to DefaultCircuitBreakerConfig.
 func NewCircuitBreaker(config CircuitBreakerConfig) *CircuitBreaker {
+	defaults := DefaultCircuitBreakerConfig()
+	if config.MaxHalfOpenCalls <= 0 {
+		config.MaxHalfOpenCalls = defaults.MaxHalfOpenCalls
+	}
+	if config.FailureRateThreshold <= 0 || config.FailureRateThreshold > 1 {
+		config.FailureRateThreshold = defaults.FailureRateThreshold
+	}
+	if config.Window <= 0 {
+		config.Window = defaults.Window
+	}
+	if config.MinimumRequests <= 0 {
+		config.MinimumRequests = defaults.MinimumRequests
+	}
+
 	return &CircuitBreaker{
 		config: config,
 		state:  CircuitClosed,
+		window: newRollingWindow(config.Window, 10),
 	}
 }
 
@@ -91,8 +172,58 @@ func (cb *CircuitBreaker) SetHealthChecker(healthCheck func(ctx context.Context)
 	cb.healthChecker = healthCheck
 }
 
-// Call executes the given function with circuit breaker protection
+// Execute runs fn with cb's protection and returns its result, typed.
+// Returns ErrCircuitBreakerOpen (or ErrCircuitBreakerTransitioning when the recovery timeout has
+// elapsed) without calling fn while the circuit is open, and ErrCircuitBreakerProbeLimit while it
+// is half-open with MaxHalfOpenCalls probes in flight.
+func Execute[T any](ctx context.Context, cb *CircuitBreaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := cb.execute(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+// ExecuteWithFallback is Execute, except that when the breaker rejects the call it returns
+// fallback's result together with ErrCircuitBreakerOpenWithFallback, or
+// ErrCircuitBreakerOpenWithFallbackError if fallback fails.
+func ExecuteWithFallback[T any](ctx context.Context, cb *CircuitBreaker, fn, fallback func(ctx context.Context) (T, error)) (T, error) {
+	result, err := Execute(ctx, cb, fn)
+	if !isRejection(err) {
+		return result, err
+	}
+
+	result, err = fallback(ctx)
+	if err != nil {
+		return result, ErrCircuitBreakerOpenWithFallbackError{Err: err}
+	}
+	return result, ErrCircuitBreakerOpenWithFallback
+}
+
+// Call executes the given function with circuit breaker protection, using the function set
+// with SetFallbackFunc when the call is rejected. Prefer Execute for typed results.
 func (cb *CircuitBreaker) Call(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	cb.mu.RLock()
+	fallback := cb.fallbackFunc
+	cb.mu.RUnlock()
+
+	if fallback == nil {
+		return Execute(ctx, cb, fn)
+	}
+	return ExecuteWithFallback(ctx, cb, fn, fallback)
+}
+
+// isRejection reports whether err means the breaker did not run the call, including the call
+// that moves an open circuit to half-open
+func isRejection(err error) bool {
+	return errors.Is(err, ErrCircuitBreakerOpen) || errors.Is(err, ErrCircuitBreakerProbeLimit) ||
+		errors.Is(err, ErrCircuitBreakerTransitioning)
+}
+
+// execute dispatches fn according to the current state under the configured timeout
+func (cb *CircuitBreaker) execute(ctx context.Context, fn func(ctx context.Context) error) error {
 	// Create context with timeout
 	ctx, cancel := context.WithTimeout(ctx, cb.config.Timeout)
 	defer cancel()
@@ -107,64 +238,77 @@ func (cb *CircuitBreaker) Call(ctx context.Context, fn func(ctx context.Context)
 	case CircuitHalfOpen:
 		return cb.callHalfOpen(ctx, fn)
 	default:
-		return nil, errors.New("unknown circuit breaker state")
+		return errors.New("unknown circuit breaker state")
 	}
 }
 
 // callClosed handles calls when circuit is closed
-func (cb *CircuitBreaker) callClosed(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
-	result, err := fn(ctx)
+func (cb *CircuitBreaker) callClosed(ctx context.Context, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := fn(ctx)
 
 	if err != nil {
 		cb.recordFailure()
-		return nil, err
+		return err
+	}
+
+	// A slow call still returns its result, but counts against the database
+	if cb.isSlow(time.Since(start)) {
+		cb.recordFailure()
+		return nil
 	}
 
 	cb.recordSuccess()
-	return result, nil
+	return nil
+}
+
+// isSlow reports whether a successful call took long enough to count as a failure
+func (cb *CircuitBreaker) isSlow(elapsed time.Duration) bool {
+	return cb.config.SlowCallThreshold > 0 && elapsed >= cb.config.SlowCallThreshold
 }
 
 // callOpen handles calls when circuit is open
-func (cb *CircuitBreaker) callOpen(ctx context.Context) (interface{}, error) {
+func (cb *CircuitBreaker) callOpen(ctx context.Context) error {
 	// Check if enough time has passed to try recovery
 	cb.mu.RLock()
 	shouldTryRecovery := time.Since(cb.lastFailTime) >= cb.config.RecoveryTimeout
+	healthChecker := cb.healthChecker
 	cb.mu.RUnlock()
 
 	if shouldTryRecovery {
 		cb.transitionToHalfOpen()
 		// Perform a health check to see if we should transition to half-open
-		if cb.healthChecker != nil {
-			if err := cb.healthChecker(ctx); err == nil {
+		if healthChecker != nil {
+			if err := healthChecker(ctx); err == nil {
 				// Health check passed, transition to half-open
-				return nil, ErrCircuitBreakerTransitioning
+				return ErrCircuitBreakerTransitioning
 			}
 		}
 	}
 
-	// Circuit is still open, try fallback if available
-	cb.mu.RLock()
-	fallback := cb.fallbackFunc
-	cb.mu.RUnlock()
+	return ErrCircuitBreakerOpen
+}
 
-	if fallback != nil {
-		result, err := fallback(ctx)
-		if err != nil {
-			return nil, ErrCircuitBreakerOpenWithFallbackError{Err: err}
-		}
-		return result, ErrCircuitBreakerOpenWithFallback
+// callHalfOpen handles calls when circuit is half-open.
+// At most MaxHalfOpenCalls probes run at once so a recovering database is not flooded.
+func (cb *CircuitBreaker) callHalfOpen(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !cb.acquireProbe() {
+		return ErrCircuitBreakerProbeLimit
 	}
+	defer cb.releaseProbe()
 
-	return nil, ErrCircuitBreakerOpen
-}
-
-// callHalfOpen handles calls when circuit is half-open
-func (cb *CircuitBreaker) callHalfOpen(ctx context.Context, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
-	result, err := fn(ctx)
+	start := time.Now()
+	err := fn(ctx)
 
 	if err != nil {
 		cb.recordFailure()
-		return nil, err
+		return err
+	}
+
+	// A slow probe means the database has not recovered yet
+	if cb.isSlow(time.Since(start)) {
+		cb.recordFailure()
+		return nil
 	}
 
 	cb.recordSuccess()
@@ -173,27 +317,67 @@ func (cb *CircuitBreaker) callHalfOpen(ctx context.Context, fn func(ctx context.
 	cb.mu.RLock()
 	successes := cb.successes
 	threshold := cb.config.SuccessThreshold
+	state := cb.state
 	cb.mu.RUnlock()
 
-	if successes >= threshold {
+	if state == CircuitHalfOpen && successes >= threshold {
 		cb.transitionToClosed()
 	}
 
-	return result, nil
+	return nil
+}
+
+// acquireProbe reserves one of the MaxHalfOpenCalls probe slots
+func (cb *CircuitBreaker) acquireProbe() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.probes >= cb.config.MaxHalfOpenCalls {
+		return false
+	}
+	cb.probes++
+	return true
+}
+
+// releaseProbe frees a probe slot taken by acquireProbe
+func (cb *CircuitBreaker) releaseProbe() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probes--
 }
 
 // recordFailure increments failure count and transitions to open if threshold reached
 func (cb *CircuitBreaker) recordFailure() {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
+	from := cb.state
 
 	cb.failures++
 	cb.successes = 0 // Reset success counter
 	cb.lastFailTime = time.Now()
+	cb.window.record(cb.lastFailTime, true)
 
-	if cb.failures >= cb.config.FailureThreshold {
+	if cb.shouldOpen() {
 		cb.state = CircuitOpen
 	}
+	to := cb.state
+	cb.mu.Unlock()
+
+	cb.notifyStateChange(from, to)
+}
+
+// shouldOpen applies the trip policy after a failure; cb.mu must be held
+func (cb *CircuitBreaker) shouldOpen() bool {
+	if cb.config.Policy != TripFailureRate {
+		return cb.failures >= cb.config.FailureThreshold
+	}
+
+	// A failed probe reopens a half-open circuit straight away
+	if cb.state == CircuitHalfOpen {
+		return true
+	}
+	total, failed := cb.window.counts(time.Now())
+	return total >= cb.config.MinimumRequests &&
+		float64(failed)/float64(total) >= cb.config.FailureRateThreshold
 }
 
 // recordSuccess increments success count and resets failure count
@@ -203,25 +387,38 @@ func (cb *CircuitBreaker) recordSuccess() {
 
 	cb.successes++
 	cb.failures = 0 // Reset failure counter on success
+	cb.window.record(time.Now(), false)
 }
 
 // transitionToHalfOpen changes state to half-open
 func (cb *CircuitBreaker) transitionToHalfOpen() {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
+	from := cb.state
 	cb.state = CircuitHalfOpen
 	cb.successes = 0
+	cb.mu.Unlock()
+
+	cb.notifyStateChange(from, CircuitHalfOpen)
 }
 
 // transitionToClosed changes state to closed
 func (cb *CircuitBreaker) transitionToClosed() {
 	cb.mu.Lock()
-	defer cb.mu.Unlock()
-
+	from := cb.state
 	cb.state = CircuitClosed
 	cb.failures = 0
 	cb.successes = 0
+	cb.window.reset() // Failures from before the outage must not reopen the circuit
+	cb.mu.Unlock()
+
+	cb.notifyStateChange(from, CircuitClosed)
+}
+
+// notifyStateChange reports a transition to OnStateChange; it must be called without cb.mu held
+func (cb *CircuitBreaker) notifyStateChange(from, to CircuitBreakerState) {
+	if from != to && cb.config.OnStateChange != nil {
+		cb.config.OnStateChange(cb.config.Name, from, to)
+	}
 }
 
 // getState returns the current circuit breaker state
@@ -236,12 +433,19 @@ func (cb *CircuitBreaker) GetStats() CircuitBreakerStats {
 	cb.mu.RLock()
 	defer cb.mu.RUnlock()
 
-	return CircuitBreakerStats{
+	stats := CircuitBreakerStats{
 		State:        cb.state,
 		Failures:     cb.failures,
 		Successes:    cb.successes,
 		LastFailTime: cb.lastFailTime,
 	}
+
+	total, failed := cb.window.counts(time.Now())
+	stats.WindowRequests = total
+	if total > 0 {
+		stats.WindowFailureRate = float64(failed) / float64(total)
+	}
+	return stats
 }
 
 // CircuitBreakerStats provides statistics about the circuit breaker
@@ -250,6 +454,10 @@ type CircuitBreakerStats struct {
 	Failures     int                 `json:"failures"`
 	Successes    int                 `json:"successes"`
 	LastFailTime time.Time           `json:"last_fail_time,omitempty"`
+	// WindowRequests and WindowFailureRate describe calls over the rolling window,
+	// including slow calls counted as failures
+	WindowRequests    int     `json:"window_requests"`
+	WindowFailureRate float64 `json:"window_failure_rate"`
 }
 
 // Custom errors for circuit breaker
@@ -257,6 +465,7 @@ var (
 	ErrCircuitBreakerOpen             = errors.New("circuit breaker is open")
 	ErrCircuitBreakerOpenWithFallback = errors.New("circuit breaker is open, fallback used")
 	ErrCircuitBreakerTransitioning    = errors.New("circuit breaker transitioning to half-open")
+	ErrCircuitBreakerProbeLimit       = errors.New("circuit breaker is half-open and at its probe limit")
 )
 
 // ErrCircuitBreakerOpenWithFallbackError wraps fallback errors
@@ -271,3 +480,58 @@ func (e ErrCircuitBreakerOpenWithFallbackError) Error() string {
 func (e ErrCircuitBreakerOpenWithFallbackError) Unwrap() error {
 	return e.Err
 }
+
+// rollingWindow counts calls and failures over a sliding time window split into fixed buckets.
+// Buckets older than the window are skipped when counting and recycled when written.
+type rollingWindow struct {
+	bucketSize time.Duration
+	buckets    []windowBucket
+}
+
+type windowBucket struct {
+	start    time.Time
+	total    int
+	failures int
+}
+
+// newRollingWindow creates a window of the given length split into n buckets
+func newRollingWindow(window time.Duration, n int) *rollingWindow {
+	bucketSize := window / time.Duration(n)
+	if bucketSize <= 0 {
+		bucketSize = 1
+	}
+	return &rollingWindow{bucketSize: bucketSize, buckets: make([]windowBucket, n)}
+}
+
+// record adds a call at now to its bucket, clearing the bucket if it held an older period
+func (w *rollingWindow) record(now time.Time, failed bool) {
+	start := now.Truncate(w.bucketSize)
+	bucket := &w.buckets[int(start.UnixNano()/int64(w.bucketSize))%len(w.buckets)]
+	if !bucket.start.Equal(start) {
+		*bucket = windowBucket{start: start}
+	}
+
+	bucket.total++
+	if failed {
+		bucket.failures++
+	}
+}
+
+// counts returns the calls and failures recorded within the window ending at now
+func (w *rollingWindow) counts(now time.Time) (total, failures int) {
+	oldest := now.Truncate(w.bucketSize).Add(-w.bucketSize * time.Duration(len(w.buckets)-1))
+	for _, bucket := range w.buckets {
+		if !bucket.start.Before(oldest) {
+			total += bucket.total
+			failures += bucket.failures
+		}
+	}
+	return total, failures
+}
+
+// reset forgets every recorded call
+func (w *rollingWindow) reset() {
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{}
+	}
+}
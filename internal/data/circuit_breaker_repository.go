@@ -7,18 +7,39 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"fizzbuzz/internal/jsonlog"
 )
 
-// CircuitBreakerRepository wraps StatisticsRepository with circuit breaker protection
+// CircuitBreakerRepository wraps StatisticsRepository with circuit breaker protection.
+// Reads and writes go through separate breakers, so slow or failing writes do not
+// push reads into cache-only mode and vice versa.
 type CircuitBreakerRepository struct {
-	repository     StatisticsRepository
-	circuitBreaker *CircuitBreaker
-	cache          *cacheLayer
-	logger         *jsonlog.Logger
-	mu             sync.RWMutex
+	repository   StatisticsRepository
+	readBreaker  *CircuitBreaker
+	writeBreaker *CircuitBreaker
+	cache        *cacheLayer
+	logger       *jsonlog.Logger
+	mu           sync.RWMutex
+
+	// spool keeps writes rejected by the write breaker until it closes again
+	spool     *Spool
+	replaying atomic.Bool
+	replays   sync.WaitGroup
+
+	// reports counts hits written, spooled or dropped, including spooled hits replayed later
+	reports writeReports
+}
+
+// spoolReplayTimeout bounds a replay of the spool into the database
+const spoolReplayTimeout = 30 * time.Second
+
+// CircuitBreakerRepositoryStats reports the read and write breakers separately
+type CircuitBreakerRepositoryStats struct {
+	Read  CircuitBreakerStats `json:"read"`
+	Write CircuitBreakerStats `json:"write"`
 }
 
 // cacheLayer provides fallback data when database is unavailable
@@ -32,9 +53,45 @@ type cacheLayer struct {
 }
 
 // NewCircuitBreakerRepository creates a new circuit breaker protected repository
+// using DefaultCircuitBreakerConfig for both breakers
 func NewCircuitBreakerRepository(repository StatisticsRepository, logger *jsonlog.Logger) *CircuitBreakerRepository {
-	config := DefaultCircuitBreakerConfig()
-	cb := NewCircuitBreaker(config)
+	return NewCircuitBreakerRepositoryWithConfig(repository, DefaultCircuitBreakerConfig(), logger)
+}
+
+// NewCircuitBreakerRepositoryWithConfig creates a circuit breaker protected repository whose read
+// and write breakers both use config, named "read" and "write" in OnStateChange notifications.
+// Non-positive configuration values fall back to DefaultCircuitBreakerConfig.
+func NewCircuitBreakerRepositoryWithConfig(repository StatisticsRepository, config CircuitBreakerConfig, logger *jsonlog.Logger) *CircuitBreakerRepository {
+	defaults := DefaultCircuitBreakerConfig()
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.RecoveryTimeout <= 0 {
+		config.RecoveryTimeout = defaults.RecoveryTimeout
+	}
+	if config.SuccessThreshold <= 0 {
+		config.SuccessThreshold = defaults.SuccessThreshold
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaults.Timeout
+	}
+
+	var cbRepo *CircuitBreakerRepository
+
+	readConfig, writeConfig := config, config
+	readConfig.Name = "read"
+	writeConfig.Name = "write"
+	writeConfig.OnStateChange = func(name string, from, to CircuitBreakerState) {
+		if config.OnStateChange != nil {
+			config.OnStateChange(name, from, to)
+		}
+		// Writes are reaching the database again: replay what was spooled during the outage
+		if to == CircuitClosed {
+			cbRepo.replaySpool()
+		}
+	}
+	readBreaker := NewCircuitBreaker(readConfig)
+	writeBreaker := NewCircuitBreaker(writeConfig)
 
 	cache := &cacheLayer{
 		cacheTTL: 5 * time.Minute, // Cache data for 5 minutes in degraded mode
@@ -50,41 +107,57 @@ func NewCircuitBreakerRepository(repository StatisticsRepository, logger *jsonlo
 		},
 	}
 
-	cbRepo := &CircuitBreakerRepository{
-		repository:     repository,
-		circuitBreaker: cb,
-		cache:          cache,
-		logger:         logger,
+	cbRepo = &CircuitBreakerRepository{
+		repository:   repository,
+		readBreaker:  readBreaker,
+		writeBreaker: writeBreaker,
+		cache:        cache,
+		logger:       logger,
 	}
 
 	// Set health checker function
-	cb.SetHealthChecker(func(ctx context.Context) error {
+	healthChecker := func(ctx context.Context) error {
 		if healthRepo, ok := repository.(*PostgreSQLStatisticsRepository); ok {
 			_, err := healthRepo.Health(ctx)
 			return err
 		}
 		_, err := repository.GetMostFrequent(ctx)
 		return err
-	})
-
-	// Set fallback function for cache-only mode
-	cb.SetFallbackFunc(func(ctx context.Context) (interface{}, error) {
-		cbRepo.logger.Warn("using cache-only mode due to database unavailability")
-		return cbRepo.cache.getMostFrequentCached(), nil
-	})
+	}
+	readBreaker.SetHealthChecker(healthChecker)
+	writeBreaker.SetHealthChecker(healthChecker)
 
 	return cbRepo
 }
 
 // Record implements StatisticsRepository.Record with circuit breaker protection
 func (cbr *CircuitBreakerRepository) Record(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
-	result, err := cbr.circuitBreaker.Call(ctx, func(ctx context.Context) (interface{}, error) {
-		return cbr.repository.Record(ctx, input)
+	return cbr.record(ctx, input, "")
+}
+
+// RecordForClient implements ClientStatisticsRepository.RecordForClient with circuit breaker protection.
+// Falls back to an unattributed Record when the wrapped repository does not attribute hits.
+func (cbr *CircuitBreakerRepository) RecordForClient(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
+	return cbr.record(ctx, input, client)
+}
+
+// record counts one hit for input through the write breaker, attributed to client unless it is empty
+func (cbr *CircuitBreakerRepository) record(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
+	entry, err := Execute(ctx, cbr.writeBreaker, func(ctx context.Context) (*StatisticsEntry, error) {
+		return recordForClient(ctx, cbr.repository, input, client)
 	})
 
 	if err != nil {
+		// Keep the hit in the spool rather than dropping it while the breaker is open
+		delta := StatisticsDelta{Input: input}
+		delta.add(client, 1)
+		if isRejection(err) && cbr.spoolWrite(ctx, delta) == 1 {
+			return &StatisticsEntry{ParametersHash: input.GenerateStatsKey(), Parameters: input}, nil
+		}
+		cbr.reports.report(WriteFailed, 1)
+
 		// Log circuit breaker events
-		state := cbr.circuitBreaker.GetStats()
+		state := cbr.writeBreaker.GetStats()
 		cbr.logger.WarnWithContext(ctx, "database record operation failed",
 			"error", err,
 			"circuit_breaker_state", state.State.String(),
@@ -96,24 +169,56 @@ func (cbr *CircuitBreakerRepository) Record(ctx context.Context, input FizzBuzzI
 		return nil, err
 	}
 
-	if entry, ok := result.(*StatisticsEntry); ok {
-		// Update cache with successful result
-		cbr.cache.updateMostFrequent(entry)
-		return entry, nil
+	cbr.reports.report(WriteSucceeded, 1)
+
+	// Update cache with successful result
+	cbr.cache.updateMostFrequent(entry)
+	return entry, nil
+}
+
+// RecordBatch implements BatchRecorder with circuit breaker protection.
+// The whole batch counts as a single call towards the breaker's failure threshold.
+func (cbr *CircuitBreakerRepository) RecordBatch(ctx context.Context, batch []StatisticsDelta) error {
+	_, err := Execute(ctx, cbr.writeBreaker, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, recordBatch(ctx, cbr.repository, batch)
+	})
+
+	if err != nil {
+		dropped := batch
+		if isRejection(err) {
+			dropped = batch[cbr.spoolWrite(ctx, batch...):]
+			if len(dropped) == 0 {
+				return nil
+			}
+		}
+		cbr.reports.report(WriteFailed, deltaHits(dropped))
+
+		state := cbr.writeBreaker.GetStats()
+		cbr.logger.WarnWithContext(ctx, "database batch record operation failed",
+			"error", err,
+			"circuit_breaker_state", state.State.String(),
+			"failures", state.Failures,
+			"rows", len(batch),
+			"operation", "RecordBatch")
+
+		// As with Record, there is no meaningful fallback for writes
+		return err
 	}
 
-	return nil, fmt.Errorf("unexpected result type from Record operation")
+	cbr.reports.report(WriteSucceeded, deltaHits(batch))
+	return nil
 }
 
 // GetMostFrequent implements StatisticsRepository.GetMostFrequent with circuit breaker protection
 func (cbr *CircuitBreakerRepository) GetMostFrequent(ctx context.Context) (*StatisticsEntry, error) {
-	result, err := cbr.circuitBreaker.Call(ctx, func(ctx context.Context) (interface{}, error) {
-		return cbr.repository.GetMostFrequent(ctx)
-	})
+	entry, err := ExecuteWithFallback(ctx, cbr.readBreaker, cbr.repository.GetMostFrequent,
+		func(ctx context.Context) (*StatisticsEntry, error) {
+			return cbr.cache.getMostFrequentCached(), nil
+		})
 
 	if err != nil {
 		// Log circuit breaker events
-		state := cbr.circuitBreaker.GetStats()
+		state := cbr.readBreaker.GetStats()
 		cbr.logger.WarnWithContext(ctx, "database read operation failed",
 			"error", err,
 			"circuit_breaker_state", state.State.String(),
@@ -123,118 +228,287 @@ func (cbr *CircuitBreakerRepository) GetMostFrequent(ctx context.Context) (*Stat
 		// Check if we used fallback (cache-only mode)
 		if err == ErrCircuitBreakerOpenWithFallback {
 			cbr.logger.InfoWithContext(ctx, "using cached statistics due to database unavailability")
-			return cbr.cache.getMostFrequentCached(), nil
+			return entry, nil
 		}
 
 		return nil, err
 	}
 
-	if entry, ok := result.(*StatisticsEntry); ok {
-		// Update cache with successful result
-		cbr.cache.updateMostFrequent(entry)
-		return entry, nil
+	// Update cache with successful result
+	cbr.cache.updateMostFrequent(entry)
+	return entry, nil
+}
+
+// GetMostFrequentInWindow implements StatisticsRepository.GetMostFrequentInWindow with circuit breaker protection.
+// There is no fallback: the cache only holds cumulative counts, which would misreport a window.
+func (cbr *CircuitBreakerRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*StatisticsEntry, error) {
+	entry, err := Execute(ctx, cbr.readBreaker, func(ctx context.Context) (*StatisticsEntry, error) {
+		return cbr.repository.GetMostFrequentInWindow(ctx, from, to)
+	})
+
+	if err != nil {
+		state := cbr.readBreaker.GetStats()
+		cbr.logger.WarnWithContext(ctx, "database GetMostFrequentInWindow operation failed",
+			"error", err,
+			"circuit_breaker_state", state.State.String(),
+			"from", from,
+			"to", to,
+			"operation", "GetMostFrequentInWindow")
+
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("unexpected result type from GetMostFrequent operation")
+	return entry, nil
 }
 
 // GetTopN implements StatisticsRepository.GetTopN with circuit breaker protection
 func (cbr *CircuitBreakerRepository) GetTopN(ctx context.Context, n int) ([]*StatisticsEntry, error) {
-	result, err := cbr.circuitBreaker.Call(ctx, func(ctx context.Context) (interface{}, error) {
-		return cbr.repository.GetTopN(ctx, n)
-	})
+	entries, err := ExecuteWithFallback(ctx, cbr.readBreaker,
+		func(ctx context.Context) ([]*StatisticsEntry, error) {
+			return cbr.repository.GetTopN(ctx, n)
+		},
+		// For GetTopN, provide fallback with cached most frequent
+		func(ctx context.Context) ([]*StatisticsEntry, error) {
+			if cached := cbr.cache.getMostFrequentCached(); cached != nil {
+				return []*StatisticsEntry{cached}, nil
+			}
+			return []*StatisticsEntry{}, nil
+		})
 
 	if err != nil {
-		state := cbr.circuitBreaker.GetStats()
+		state := cbr.readBreaker.GetStats()
 		cbr.logger.WarnWithContext(ctx, "database GetTopN operation failed",
 			"error", err,
 			"circuit_breaker_state", state.State.String(),
 			"n", n,
 			"operation", "GetTopN")
 
-		// For GetTopN, provide fallback with cached most frequent
 		if err == ErrCircuitBreakerOpenWithFallback {
-			cached := cbr.cache.getMostFrequentCached()
-			if cached != nil {
-				return []*StatisticsEntry{cached}, nil
-			}
-			return []*StatisticsEntry{}, nil
+			return entries, nil
 		}
 
 		return nil, err
 	}
 
-	if entries, ok := result.([]*StatisticsEntry); ok {
-		return entries, nil
+	return entries, nil
+}
+
+// GetTopNForClient implements ClientStatisticsRepository.GetTopNForClient with circuit breaker protection.
+// There is no fallback: the cache holds no per-client counts.
+func (cbr *CircuitBreakerRepository) GetTopNForClient(ctx context.Context, client string, n int) ([]*StatisticsEntry, error) {
+	repository, ok := cbr.repository.(ClientStatisticsRepository)
+	if !ok {
+		return nil, ErrClientStatisticsUnsupported
+	}
+
+	entries, err := Execute(ctx, cbr.readBreaker, func(ctx context.Context) ([]*StatisticsEntry, error) {
+		return repository.GetTopNForClient(ctx, client, n)
+	})
+
+	if err != nil {
+		state := cbr.readBreaker.GetStats()
+		cbr.logger.WarnWithContext(ctx, "database GetTopNForClient operation failed",
+			"error", err,
+			"circuit_breaker_state", state.State.String(),
+			"n", n,
+			"operation", "GetTopNForClient")
+
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetTopClients implements ClientStatisticsRepository.GetTopClients with circuit breaker protection.
+// There is no fallback: the cache holds no per-client counts.
+func (cbr *CircuitBreakerRepository) GetTopClients(ctx context.Context, input FizzBuzzInput, n int) ([]ClientHits, error) {
+	repository, ok := cbr.repository.(ClientStatisticsRepository)
+	if !ok {
+		return nil, ErrClientStatisticsUnsupported
+	}
+
+	clients, err := Execute(ctx, cbr.readBreaker, func(ctx context.Context) ([]ClientHits, error) {
+		return repository.GetTopClients(ctx, input, n)
+	})
+
+	if err != nil {
+		state := cbr.readBreaker.GetStats()
+		cbr.logger.WarnWithContext(ctx, "database GetTopClients operation failed",
+			"error", err,
+			"circuit_breaker_state", state.State.String(),
+			"n", n,
+			"operation", "GetTopClients")
+
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("unexpected result type from GetTopN operation")
+	return clients, nil
 }
 
 // GetStats implements StatisticsRepository.GetStats with circuit breaker protection
 func (cbr *CircuitBreakerRepository) GetStats(ctx context.Context) (StatsSummary, error) {
-	result, err := cbr.circuitBreaker.Call(ctx, func(ctx context.Context) (interface{}, error) {
-		return cbr.repository.GetStats(ctx)
-	})
+	stats, err := ExecuteWithFallback(ctx, cbr.readBreaker, cbr.repository.GetStats,
+		// Return fallback stats when database unavailable
+		func(ctx context.Context) (StatsSummary, error) {
+			return cbr.cache.getFallbackStats(), nil
+		})
 
 	if err != nil {
-		state := cbr.circuitBreaker.GetStats()
+		state := cbr.readBreaker.GetStats()
 		cbr.logger.WarnWithContext(ctx, "database GetStats operation failed",
 			"error", err,
 			"circuit_breaker_state", state.State.String(),
 			"operation", "GetStats")
 
-		// Return fallback stats when database unavailable
 		if err == ErrCircuitBreakerOpenWithFallback {
-			return cbr.cache.getFallbackStats(), nil
+			return stats, nil
 		}
 
 		return StatsSummary{}, err
 	}
 
-	if stats, ok := result.(StatsSummary); ok {
-		return stats, nil
-	}
-
-	return StatsSummary{}, fmt.Errorf("unexpected result type from GetStats operation")
+	return stats, nil
 }
 
 // GetPoolStats implements StatisticsRepository.GetPoolStats with circuit breaker protection
 func (cbr *CircuitBreakerRepository) GetPoolStats(ctx context.Context) (*PoolStats, error) {
-	result, err := cbr.circuitBreaker.Call(ctx, func(ctx context.Context) (interface{}, error) {
-		return cbr.repository.GetPoolStats(ctx)
-	})
+	poolStats, err := ExecuteWithFallback(ctx, cbr.readBreaker, cbr.repository.GetPoolStats,
+		// Return fallback pool stats when database unavailable
+		func(ctx context.Context) (*PoolStats, error) {
+			return cbr.cache.getFallbackPoolStats(), nil
+		})
 
 	if err != nil {
-		state := cbr.circuitBreaker.GetStats()
+		state := cbr.readBreaker.GetStats()
 		cbr.logger.WarnWithContext(ctx, "database GetPoolStats operation failed",
 			"error", err,
 			"circuit_breaker_state", state.State.String(),
 			"operation", "GetPoolStats")
 
-		// Return fallback pool stats when database unavailable
 		if err == ErrCircuitBreakerOpenWithFallback {
-			return cbr.cache.getFallbackPoolStats(), nil
+			return poolStats, nil
 		}
 
 		return nil, err
 	}
 
-	if poolStats, ok := result.(*PoolStats); ok {
-		return poolStats, nil
+	return poolStats, nil
+}
+
+// SetSpool makes writes rejected by the open write breaker go to spool instead of being dropped.
+// Hits already in the spool, e.g. from a previous run, are replayed straight away.
+func (cbr *CircuitBreakerRepository) SetSpool(spool *Spool) {
+	cbr.mu.Lock()
+	cbr.spool = spool
+	cbr.mu.Unlock()
+
+	if cbr.writeBreaker.GetStats().State == CircuitClosed {
+		cbr.replaySpool()
+	}
+}
+
+// GetSpoolStats returns the spool's depth and size; ok is false when no spool is set
+func (cbr *CircuitBreakerRepository) GetSpoolStats() (stats SpoolStats, ok bool) {
+	cbr.mu.RLock()
+	spool := cbr.spool
+	cbr.mu.RUnlock()
+
+	if spool == nil {
+		return SpoolStats{}, false
+	}
+	return spool.Stats(), true
+}
+
+// spoolWrite appends deltas to the spool in order, stopping at the first one that does not fit,
+// and returns how many were kept
+func (cbr *CircuitBreakerRepository) spoolWrite(ctx context.Context, deltas ...StatisticsDelta) int {
+	cbr.mu.RLock()
+	spool := cbr.spool
+	cbr.mu.RUnlock()
+
+	if spool == nil {
+		return 0
+	}
+
+	for i, delta := range deltas {
+		if err := spool.Append(delta); err != nil {
+			cbr.logger.WarnWithContext(ctx, "failed to spool statistics write",
+				"error", err,
+				"spool_depth", spool.Stats().Depth,
+				"operation", "Spool")
+			return i
+		}
+		cbr.reports.report(WriteSpooled, delta.Hits)
+	}
+	return len(deltas)
+}
+
+// SetWriteObserver implements WriteReporter: writes are reported as they succeed, fail or are
+// spooled, and spooled hits again as successes once replayed
+func (cbr *CircuitBreakerRepository) SetWriteObserver(observer WriteObserver) {
+	cbr.reports.set(observer)
+}
+
+// replaySpool writes spooled hits to the database in the background through the write breaker.
+// At most one replay runs at a time; hits that fail to replay stay in the spool.
+func (cbr *CircuitBreakerRepository) replaySpool() {
+	cbr.mu.RLock()
+	spool := cbr.spool
+	cbr.mu.RUnlock()
+
+	if spool == nil || spool.Stats().Depth == 0 || !cbr.replaying.CompareAndSwap(false, true) {
+		return
 	}
 
-	return nil, fmt.Errorf("unexpected result type from GetPoolStats operation")
+	cbr.replays.Add(1)
+	go func() {
+		defer cbr.replays.Done()
+		defer cbr.replaying.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), spoolReplayTimeout)
+		defer cancel()
+
+		written, err := spool.Replay(ctx, 100, func(ctx context.Context, batch []StatisticsDelta) error {
+			_, err := Execute(ctx, cbr.writeBreaker, func(ctx context.Context) (struct{}, error) {
+				return struct{}{}, recordBatch(ctx, cbr.repository, batch)
+			})
+			return err
+		})
+		cbr.reports.report(WriteSucceeded, written)
+		if err != nil {
+			cbr.logger.Warn("statistics spool replay stopped, remaining hits stay spooled",
+				"error", err,
+				"replayed_hits", written,
+				"spool_depth", spool.Stats().Depth)
+			return
+		}
+		cbr.logger.Info("statistics spool replayed", "replayed_hits", written)
+	}()
 }
 
-// Close implements StatisticsRepository.Close
+// Close implements StatisticsRepository.Close.
+// Waits for an in-flight spool replay and closes the spool before the wrapped repository.
 func (cbr *CircuitBreakerRepository) Close() error {
+	cbr.replays.Wait()
+
+	cbr.mu.RLock()
+	spool := cbr.spool
+	cbr.mu.RUnlock()
+
+	if spool != nil {
+		if err := spool.Close(); err != nil {
+			cbr.logger.Warn("failed to close statistics spool", "error", err)
+		}
+	}
 	return cbr.repository.Close()
 }
 
-// GetCircuitBreakerStats returns current circuit breaker statistics for monitoring
-func (cbr *CircuitBreakerRepository) GetCircuitBreakerStats() CircuitBreakerStats {
-	return cbr.circuitBreaker.GetStats()
+// GetCircuitBreakerStats returns current read and write circuit breaker statistics for monitoring
+func (cbr *CircuitBreakerRepository) GetCircuitBreakerStats() CircuitBreakerRepositoryStats {
+	return CircuitBreakerRepositoryStats{
+		Read:  cbr.readBreaker.GetStats(),
+		Write: cbr.writeBreaker.GetStats(),
+	}
 }
 
 // cache layer methods
@@ -283,10 +557,15 @@ func (c *cacheLayer) getFallbackPoolStats() *PoolStats {
 
 // String implements fmt.Stringer for debugging
 func (cbr *CircuitBreakerRepository) String() string {
-	state := cbr.circuitBreaker.GetStats()
-	stateJSON, _ := json.Marshal(state)
+	stateJSON, _ := json.Marshal(cbr.GetCircuitBreakerStats())
 	return fmt.Sprintf("CircuitBreakerRepository{state=%s}", string(stateJSON))
 }
 
-// Compile-time verification that CircuitBreakerRepository implements StatisticsRepository
-var _ StatisticsRepository = (*CircuitBreakerRepository)(nil)
+// Compile-time verification that CircuitBreakerRepository implements StatisticsRepository, BatchRecorder,
+// ClientStatisticsRepository and WriteReporter
+var (
+	_ StatisticsRepository       = (*CircuitBreakerRepository)(nil)
+	_ BatchRecorder              = (*CircuitBreakerRepository)(nil)
+	_ ClientStatisticsRepository = (*CircuitBreakerRepository)(nil)
+	_ WriteReporter              = (*CircuitBreakerRepository)(nil)
+)
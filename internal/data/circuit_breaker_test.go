@@ -0,0 +1,391 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"fizzbuzz/internal/jsonlog"
+)
+
+// transitionRecorder collects OnStateChange notifications
+type transitionRecorder struct {
+	mu          sync.Mutex
+	transitions []string
+}
+
+func (tr *transitionRecorder) record(name string, from, to CircuitBreakerState) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.transitions = append(tr.transitions, name+":"+from.String()+"->"+to.String())
+}
+
+func (tr *transitionRecorder) get() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([]string(nil), tr.transitions...)
+}
+
+func TestCircuitBreakerOnStateChange(t *testing.T) {
+	recorder := &transitionRecorder{}
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 2,
+		RecoveryTimeout:  10 * time.Millisecond,
+		SuccessThreshold: 1,
+		Timeout:          time.Second,
+		Name:             "test",
+		OnStateChange:    recorder.record,
+	})
+
+	ctx := context.Background()
+	fail := func(ctx context.Context) (interface{}, error) { return nil, errors.New("database down") }
+	succeed := func(ctx context.Context) (interface{}, error) { return "ok", nil }
+
+	cb.Call(ctx, fail)
+	cb.Call(ctx, fail)
+	if _, err := cb.Call(ctx, succeed); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("expected open circuit to reject calls, got %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	cb.Call(ctx, succeed) // moves to half-open
+	if _, err := cb.Call(ctx, succeed); err != nil {
+		t.Fatalf("expected half-open probe to succeed, got %v", err)
+	}
+
+	want := []string{"test:closed->open", "test:open->half-open", "test:half-open->closed"}
+	got := recorder.get()
+	if len(got) != len(want) {
+		t.Fatalf("expected transitions %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transition %d: expected %s, got %s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestCircuitBreakerRepositorySeparatesReadsAndWrites(t *testing.T) {
+	recorder := &transitionRecorder{}
+	mock := NewMockStatisticsRepository()
+	mock.recordFunc = func(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
+		return nil, errors.New("write timeout")
+	}
+
+	cbRepo := NewCircuitBreakerRepositoryWithConfig(mock, CircuitBreakerConfig{
+		FailureThreshold: 3,
+		OnStateChange:    recorder.record,
+	}, jsonlog.New(nil, jsonlog.LevelError, "test"))
+
+	ctx := context.Background()
+	input := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	for i := 0; i < 3; i++ {
+		cbRepo.Record(ctx, input)
+	}
+
+	stats := cbRepo.GetCircuitBreakerStats()
+	if stats.Write.State != CircuitOpen {
+		t.Errorf("expected write breaker to be open, got %s", stats.Write.State)
+	}
+	if stats.Read.State != CircuitClosed {
+		t.Errorf("expected read breaker to stay closed, got %s", stats.Read.State)
+	}
+
+	// Reads still reach the database while writes are rejected
+	if _, err := cbRepo.GetStats(ctx); err != nil {
+		t.Errorf("expected reads to succeed with the write breaker open, got %v", err)
+	}
+	if _, err := cbRepo.Record(ctx, input); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Errorf("expected writes to be rejected, got %v", err)
+	}
+
+	if got := recorder.get(); len(got) != 1 || got[0] != "write:closed->open" {
+		t.Errorf("expected a single write transition, got %v", got)
+	}
+}
+
+func TestNewCircuitBreakerRepositoryWithConfigDefaults(t *testing.T) {
+	cbRepo := NewCircuitBreakerRepositoryWithConfig(NewMockStatisticsRepository(), CircuitBreakerConfig{}, nil)
+
+	defaults := DefaultCircuitBreakerConfig()
+	for name, cb := range map[string]*CircuitBreaker{"read": cbRepo.readBreaker, "write": cbRepo.writeBreaker} {
+		if cb.config.Name != name {
+			t.Errorf("expected breaker name %q, got %q", name, cb.config.Name)
+		}
+		if cb.config.FailureThreshold != defaults.FailureThreshold || cb.config.RecoveryTimeout != defaults.RecoveryTimeout ||
+			cb.config.SuccessThreshold != defaults.SuccessThreshold || cb.config.Timeout != defaults.Timeout {
+			t.Errorf("%s: expected default configuration, got %+v", name, cb.config)
+		}
+	}
+}
+
+func TestCircuitBreakerFailureRatePolicy(t *testing.T) {
+	fail := func(ctx context.Context) (interface{}, error) { return nil, errors.New("database down") }
+	succeed := func(ctx context.Context) (interface{}, error) { return "ok", nil }
+
+	tests := []struct {
+		name     string
+		calls    int
+		failEach int // every failEach-th call fails
+		wantOpen bool
+	}{
+		{"half the calls fail", 10, 2, true},
+		{"a third of the calls fail", 12, 3, false},
+		{"below minimum requests", 8, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker(CircuitBreakerConfig{
+				Policy:               TripFailureRate,
+				FailureThreshold:     3, // ignored by the failure-rate policy
+				FailureRateThreshold: 0.5,
+				Window:               time.Minute,
+				MinimumRequests:      10,
+				RecoveryTimeout:      time.Minute,
+				Timeout:              time.Second,
+			})
+
+			for i := 1; i <= tt.calls; i++ {
+				if i%tt.failEach == 0 {
+					cb.Call(context.Background(), fail)
+				} else {
+					cb.Call(context.Background(), succeed)
+				}
+			}
+
+			stats := cb.GetStats()
+			if (stats.State == CircuitOpen) != tt.wantOpen {
+				t.Errorf("expected open=%v, got state %s (%d calls, rate %.2f)",
+					tt.wantOpen, stats.State, stats.WindowRequests, stats.WindowFailureRate)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerSlowCalls(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold:  2,
+		SlowCallThreshold: 5 * time.Millisecond,
+		RecoveryTimeout:   time.Minute,
+		Timeout:           time.Second,
+	})
+
+	slow := func(ctx context.Context) (interface{}, error) {
+		time.Sleep(10 * time.Millisecond)
+		return "slow", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := cb.Call(context.Background(), slow)
+		if err != nil || result != "slow" {
+			t.Fatalf("expected slow call result to be returned, got %v, %v", result, err)
+		}
+	}
+
+	if state := cb.GetStats().State; state != CircuitOpen {
+		t.Errorf("expected slow calls to open the circuit, got %s", state)
+	}
+}
+
+func TestRollingWindow(t *testing.T) {
+	w := newRollingWindow(10*time.Second, 10)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	w.record(now, true)
+	w.record(now.Add(5*time.Second), false)
+	w.record(now.Add(9*time.Second), true)
+
+	if total, failures := w.counts(now.Add(9 * time.Second)); total != 3 || failures != 2 {
+		t.Errorf("expected 3 calls and 2 failures, got %d and %d", total, failures)
+	}
+
+	// The first call has slid out of the window
+	if total, failures := w.counts(now.Add(10 * time.Second)); total != 2 || failures != 1 {
+		t.Errorf("expected 2 calls and 1 failure, got %d and %d", total, failures)
+	}
+
+	// Writing into a recycled bucket discards its old period
+	w.record(now.Add(20*time.Second), false)
+	if total, _ := w.counts(now.Add(20 * time.Second)); total != 1 {
+		t.Errorf("expected 1 call after the window slid, got %d", total)
+	}
+
+	w.reset()
+	if total, _ := w.counts(now.Add(20 * time.Second)); total != 0 {
+		t.Errorf("expected no calls after reset, got %d", total)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{FailureThreshold: 1, RecoveryTimeout: time.Minute, Timeout: time.Second})
+	ctx := context.Background()
+
+	entry, err := Execute(ctx, cb, func(ctx context.Context) (*StatisticsEntry, error) {
+		return &StatisticsEntry{Hits: 7}, nil
+	})
+	if err != nil || entry.Hits != 7 {
+		t.Fatalf("expected typed result, got %+v, %v", entry, err)
+	}
+
+	Execute(ctx, cb, func(ctx context.Context) (int, error) { return 0, errors.New("database down") })
+
+	if _, err := Execute(ctx, cb, func(ctx context.Context) (int, error) { return 1, nil }); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Errorf("expected ErrCircuitBreakerOpen, got %v", err)
+	}
+
+	n, err := ExecuteWithFallback(ctx, cb,
+		func(ctx context.Context) (int, error) { return 1, nil },
+		func(ctx context.Context) (int, error) { return 42, nil })
+	if n != 42 || !errors.Is(err, ErrCircuitBreakerOpenWithFallback) {
+		t.Errorf("expected fallback result 42, got %d, %v", n, err)
+	}
+
+	_, err = ExecuteWithFallback(ctx, cb,
+		func(ctx context.Context) (int, error) { return 1, nil },
+		func(ctx context.Context) (int, error) { return 0, errors.New("cache empty") })
+	var fallbackErr ErrCircuitBreakerOpenWithFallbackError
+	if !errors.As(err, &fallbackErr) {
+		t.Errorf("expected ErrCircuitBreakerOpenWithFallbackError, got %v", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpenProbeLimit(t *testing.T) {
+	cb := NewCircuitBreaker(CircuitBreakerConfig{
+		FailureThreshold: 1,
+		RecoveryTimeout:  time.Millisecond,
+		SuccessThreshold: 2,
+		MaxHalfOpenCalls: 1,
+		Timeout:          time.Second,
+	})
+	ctx := context.Background()
+
+	Execute(ctx, cb, func(ctx context.Context) (int, error) { return 0, errors.New("database down") })
+	time.Sleep(5 * time.Millisecond)
+	Execute(ctx, cb, func(ctx context.Context) (int, error) { return 0, nil }) // moves to half-open
+	if state := cb.GetStats().State; state != CircuitHalfOpen {
+		t.Fatalf("expected half-open, got %s", state)
+	}
+
+	// Hold the only probe slot
+	release := make(chan struct{})
+	probing := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		_, err := Execute(ctx, cb, func(ctx context.Context) (int, error) {
+			close(probing)
+			<-release
+			return 1, nil
+		})
+		done <- err
+	}()
+	<-probing
+
+	if _, err := Execute(ctx, cb, func(ctx context.Context) (int, error) { return 1, nil }); !errors.Is(err, ErrCircuitBreakerProbeLimit) {
+		t.Errorf("expected ErrCircuitBreakerProbeLimit, got %v", err)
+	}
+	if n, err := ExecuteWithFallback(ctx, cb,
+		func(ctx context.Context) (int, error) { return 1, nil },
+		func(ctx context.Context) (int, error) { return 2, nil }); n != 2 || !errors.Is(err, ErrCircuitBreakerOpenWithFallback) {
+		t.Errorf("expected the fallback while probes are exhausted, got %d, %v", n, err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+
+	// The slot is free again; a second success closes the circuit
+	if _, err := Execute(ctx, cb, func(ctx context.Context) (int, error) { return 1, nil }); err != nil {
+		t.Fatalf("expected probe to run, got %v", err)
+	}
+	if state := cb.GetStats().State; state != CircuitClosed {
+		t.Errorf("expected closed after %d successful probes, got %s", 2, state)
+	}
+}
+
+func TestCircuitBreakerRepositorySpool(t *testing.T) {
+	var mu sync.Mutex
+	healthy := false
+	recorded := 0
+
+	mock := NewMockStatisticsRepository()
+	mock.recordFunc = func(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !healthy {
+			return nil, errors.New("database down")
+		}
+		recorded++
+		return &StatisticsEntry{Parameters: input, Hits: recorded}, nil
+	}
+
+	cbRepo := NewCircuitBreakerRepositoryWithConfig(mock, CircuitBreakerConfig{
+		FailureThreshold: 1,
+		RecoveryTimeout:  time.Millisecond,
+		SuccessThreshold: 1,
+	}, jsonlog.New(io.Discard, jsonlog.LevelError, "test"))
+	spool, _ := openTestSpool(t, 0)
+	cbRepo.SetSpool(spool)
+
+	results := make(map[string]int)
+	cbRepo.SetWriteObserver(func(result string, hits int) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[result] += hits
+	})
+
+	ctx := context.Background()
+	input := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+
+	// The failure that opens the breaker is lost; rejected writes are spooled
+	if _, err := cbRepo.Record(ctx, input); err == nil {
+		t.Fatal("expected the first write to fail")
+	}
+	if _, err := cbRepo.Record(ctx, input); err != nil {
+		t.Fatalf("expected the rejected write to be spooled, got %v", err)
+	}
+	if err := cbRepo.RecordBatch(ctx, []StatisticsDelta{{Input: input, Hits: 2}}); err != nil {
+		t.Fatalf("expected the rejected batch to be spooled, got %v", err)
+	}
+	if stats, ok := cbRepo.GetSpoolStats(); !ok || stats.Depth != 3 {
+		t.Fatalf("expected spool depth 3, got %+v, %v", stats, ok)
+	}
+
+	// Recover: the next call moves to half-open (and is spooled), the probe after it closes the breaker
+	mu.Lock()
+	healthy = true
+	mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+	cbRepo.Record(ctx, input)
+	if _, err := cbRepo.Record(ctx, input); err != nil {
+		t.Fatalf("expected probe to succeed, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if stats, _ := cbRepo.GetSpoolStats(); stats.Depth == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("spool was not replayed after the breaker closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Close waits for the replay to finish writing
+	cbRepo.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if recorded != 5 { // the probe plus four spooled hits
+		t.Errorf("expected 5 recorded hits, got %d", recorded)
+	}
+	// The call after recovery is spooled or, if the breaker already let it through, written
+	if results[WriteSucceeded] != recorded || results[WriteSpooled] < 3 || results[WriteFailed] != 1 {
+		t.Errorf("expected %d hits written, at least 3 spooled and 1 dropped, got %v", recorded, results)
+	}
+}
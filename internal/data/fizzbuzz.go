@@ -1,6 +1,7 @@
 package data
 
 import (
+	"iter"
 	"runtime"
 	"strconv"
 	"sync"
@@ -10,36 +11,84 @@ import (
 // divisible by int1 are replaced with str1, numbers divisible by int2 are
 // replaced with str2, and numbers divisible by both are replaced with str1+str2.
 func FizzBuzz(int1, int2, limit int, str1, str2 string) []string {
+	return FizzBuzzRules([]Rule{{Divisor: int1, Word: str1}, {Divisor: int2, Word: str2}}, limit)
+}
+
+// FizzBuzzRules generates a sequence of numbers from 1 to the limit where each
+// number is replaced by the concatenated words of every rule whose divisor divides it,
+// in declared order. Numbers matching no rule keep their string representation.
+func FizzBuzzRules(rules []Rule, limit int) []string {
+	return FizzBuzzRange(rules, 1, limit)
+}
+
+// FizzBuzzRange materializes the FizzBuzz values of numbers start through end inclusive.
+// Each value is computed directly, so a page deep into the sequence costs the same as the first.
+func FizzBuzzRange(rules []Rule, start, end int) []string {
 	// Pre-allocate slice with capacity for optimal performance
-	result := make([]string, 0, limit)
-
-	for i := 1; i <= limit; i++ {
-		// Check divisibility by both int1 and int2
-		divisibleByInt1 := i%int1 == 0
-		divisibleByInt2 := i%int2 == 0
-
-		if divisibleByInt1 && divisibleByInt2 {
-			// Divisible by both: concatenate str1+str2
-			result = append(result, str1+str2)
-		} else if divisibleByInt1 {
-			// Divisible only by int1
-			result = append(result, str1)
-		} else if divisibleByInt2 {
-			// Divisible only by int2
-			result = append(result, str2)
-		} else {
-			// Not divisible by either: use string representation of number
-			result = append(result, strconv.Itoa(i))
-		}
+	result := make([]string, 0, max(end-max(start, 1)+1, 0))
+
+	for _, value := range Range(rules, start, end) {
+		result = append(result, value)
 	}
 
 	return result
 }
 
+// Sequence returns a lazy generator over the FizzBuzz sequence from 1 to limit.
+// Yields each number alongside its FizzBuzz value without allocating the sequence,
+// so arbitrarily large sequences can be consumed with range-over-func.
+func Sequence(rules []Rule, limit int) iter.Seq2[int, string] {
+	return Range(rules, 1, limit)
+}
+
+// Range returns a lazy generator over the FizzBuzz values of numbers start through end inclusive.
+// Start is clamped to 1; an empty sequence is produced when end is below start.
+func Range(rules []Rule, start, end int) iter.Seq2[int, string] {
+	if start < 1 {
+		start = 1
+	}
+
+	return func(yield func(int, string) bool) {
+		for i := start; i <= end; i++ {
+			// Stop at end explicitly so end == math.MaxInt cannot overflow the counter
+			if !yield(i, FizzBuzzValue(i, rules)) || i == end {
+				return
+			}
+		}
+	}
+}
+
+// FizzBuzzValue returns the FizzBuzz value for a single number under the given rules.
+// Lets callers compute elements on demand without materializing the whole sequence.
+func FizzBuzzValue(i int, rules []Rule) string {
+	var value string
+	matched := false
+
+	for _, rule := range rules {
+		if i%rule.Divisor == 0 {
+			// Concatenate words of all matching rules in declared order
+			value += rule.Word
+			matched = true
+		}
+	}
+
+	if !matched {
+		// Not divisible by any divisor: use string representation of number
+		return strconv.Itoa(i)
+	}
+	return value
+}
+
 // ConcurrentFizzBuzz generates a FizzBuzz sequence using goroutines for high-volume scenarios.
 // Uses a worker pool pattern with intelligent work distribution for optimal performance.
 // Best suited for limits > 100,000 where concurrency overhead is justified.
 func ConcurrentFizzBuzz(int1, int2, limit int, str1, str2 string) []string {
+	return ConcurrentFizzBuzzRules([]Rule{{Divisor: int1, Word: str1}, {Divisor: int2, Word: str2}}, limit)
+}
+
+// ConcurrentFizzBuzzRules is the rule-based counterpart of ConcurrentFizzBuzz.
+// Splits the range across one worker per CPU core and writes results in place.
+func ConcurrentFizzBuzzRules(rules []Rule, limit int) []string {
 	if limit <= 0 {
 		return []string{}
 	}
@@ -71,29 +120,14 @@ func ConcurrentFizzBuzz(int1, int2, limit int, str1, str2 string) []string {
 			end += remainder
 		}
 
-		go func(workerStart, workerEnd, offset int) {
+		go func(workerStart, workerEnd int) {
 			defer wg.Done()
 
-			// Process assigned range
-			for i := workerStart; i <= workerEnd; i++ {
-				divisibleByInt1 := i%int1 == 0
-				divisibleByInt2 := i%int2 == 0
-
-				var value string
-				if divisibleByInt1 && divisibleByInt2 {
-					value = str1 + str2
-				} else if divisibleByInt1 {
-					value = str1
-				} else if divisibleByInt2 {
-					value = str2
-				} else {
-					value = strconv.Itoa(i)
-				}
-
-				// Write directly to the final position (i-1 for 0-based indexing)
+			// Process assigned range, writing directly to the final position (i-1 for 0-based indexing)
+			for i, value := range Range(rules, workerStart, workerEnd) {
 				result[i-1] = value
 			}
-		}(start, end, w*chunkSize)
+		}(start, end)
 	}
 
 	// Wait for all workers to complete
@@ -2,6 +2,7 @@ package data
 
 import (
 	"fmt"
+	"math"
 	"reflect"
 	"runtime"
 	"sync"
@@ -208,6 +209,135 @@ func BenchmarkFizzBuzzAllocs(b *testing.B) {
 	}
 }
 
+func TestFizzBuzzRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []Rule
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "two rules match legacy FizzBuzz",
+			rules:    []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}},
+			limit:    15,
+			expected: FizzBuzz(3, 5, 15, "fizz", "buzz"),
+		},
+		{
+			name:     "three rules concatenate in declared order",
+			rules:    []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"}},
+			limit:    21,
+			expected: []string{"1", "2", "fizz", "4", "buzz", "fizz", "bazz", "8", "fizz", "buzz", "11", "fizz", "13", "bazz", "fizzbuzz", "16", "17", "fizz", "19", "buzz", "fizzbazz"},
+		},
+		{
+			name:     "declared order wins over divisor order",
+			rules:    []Rule{{Divisor: 5, Word: "buzz"}, {Divisor: 3, Word: "fizz"}},
+			limit:    15,
+			expected: []string{"1", "2", "fizz", "4", "buzz", "fizz", "7", "8", "fizz", "buzz", "11", "fizz", "13", "14", "buzzfizz"},
+		},
+		{
+			name:     "single rule",
+			rules:    []Rule{{Divisor: 2, Word: "even"}},
+			limit:    4,
+			expected: []string{"1", "even", "3", "even"},
+		},
+		{
+			name:     "no rules",
+			rules:    nil,
+			limit:    3,
+			expected: []string{"1", "2", "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FizzBuzzRules(tt.rules, tt.limit)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("FizzBuzzRules() = %v, want %v", result, tt.expected)
+			}
+
+			concurrent := ConcurrentFizzBuzzRules(tt.rules, tt.limit)
+			if !reflect.DeepEqual(concurrent, tt.expected) {
+				t.Errorf("ConcurrentFizzBuzzRules() = %v, want %v", concurrent, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSequence(t *testing.T) {
+	rules := []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}}
+	expected := FizzBuzz(3, 5, 15, "fizz", "buzz")
+
+	var got []string
+	next := 1
+	for n, value := range Sequence(rules, 15) {
+		if n != next {
+			t.Fatalf("expected number %d, got %d", next, n)
+		}
+		got = append(got, value)
+		next++
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Sequence() = %v, want %v", got, expected)
+	}
+}
+
+func TestRange(t *testing.T) {
+	rules := []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}}
+
+	collect := func(seq func(func(int, string) bool)) []string {
+		var values []string
+		for _, value := range seq {
+			values = append(values, value)
+		}
+		return values
+	}
+
+	tests := []struct {
+		name       string
+		start, end int
+		expected   []string
+	}{
+		{"middle of sequence", 9, 15, []string{"fizz", "buzz", "11", "fizz", "13", "14", "fizzbuzz"}},
+		{"single element", 30, 30, []string{"fizzbuzz"}},
+		{"start clamped to 1", -5, 3, []string{"1", "2", "fizz"}},
+		{"empty when end before start", 10, 9, nil},
+		{"far into the sequence", 999_999_999, 1_000_000_001, []string{"fizz", "buzz", "1000000001"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collect(Range(rules, tt.start, tt.end))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Range(%d, %d) = %v, want %v", tt.start, tt.end, got, tt.expected)
+			}
+		})
+	}
+
+	t.Run("stops when consumer breaks", func(t *testing.T) {
+		count := 0
+		for n := range Range(rules, 1, math.MaxInt) {
+			count++
+			if n == 5 {
+				break
+			}
+		}
+		if count != 5 {
+			t.Errorf("expected 5 yielded values, got %d", count)
+		}
+	})
+
+	t.Run("ends at math.MaxInt without overflow", func(t *testing.T) {
+		count := 0
+		for range Range(rules, math.MaxInt-2, math.MaxInt) {
+			count++
+		}
+		if count != 3 {
+			t.Errorf("expected 3 yielded values, got %d", count)
+		}
+	})
+}
+
 // CONCURRENT VERSION TESTS
 
 func TestConcurrentFizzBuzz(t *testing.T) {
@@ -0,0 +1,317 @@
+// Package data provides an in-memory StatisticsRepository for running without PostgreSQL.
+// Builds on StatisticsTracker for hit counting and adds hourly history buckets for window queries.
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStatisticsRepository implements StatisticsRepository entirely in process memory.
+// Intended for development and CI; statistics are lost when the process exits.
+type MemoryStatisticsRepository struct {
+	// mu serializes writes so the tracker and the history buckets stay consistent
+	mu sync.RWMutex
+	// tracker holds the cumulative hit count per parameter combination
+	tracker *StatisticsTracker
+	// history maps the start of each UTC hour to the hits per parameters hash in that hour
+	history map[time.Time]map[string]int
+	// clients maps each client to its counters per parameters hash
+	clients map[string]map[string]*clientCounter
+}
+
+// clientCounter counts one client's hits on one parameter combination
+type clientCounter struct {
+	hits      int
+	createdAt time.Time
+	updatedAt time.Time
+}
+
+// NewMemoryStatisticsRepository creates an empty in-memory repository.
+func NewMemoryStatisticsRepository() *MemoryStatisticsRepository {
+	return &MemoryStatisticsRepository{
+		tracker: NewStatisticsTracker(),
+		history: make(map[time.Time]map[string]int),
+		clients: make(map[string]map[string]*clientCounter),
+	}
+}
+
+// Record implements StatisticsRepository.Record.
+// Increments the cumulative count and the bucket for the current hour.
+func (m *MemoryStatisticsRepository) Record(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
+	return m.record(ctx, input, "")
+}
+
+// RecordForClient implements ClientStatisticsRepository.RecordForClient.
+// Increments the same counters as Record plus the client's own.
+func (m *MemoryStatisticsRepository) RecordForClient(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
+	return m.record(ctx, input, client)
+}
+
+// record counts one hit for input, attributed to client unless it is empty
+func (m *MemoryStatisticsRepository) record(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	input = input.Normalized()
+	key := input.GenerateStatsKey()
+	bucket := time.Now().UTC().Truncate(time.Hour)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tracker.Record(&input)
+
+	hits, exists := m.history[bucket]
+	if !exists {
+		hits = make(map[string]int)
+		m.history[bucket] = hits
+	}
+	hits[key]++
+
+	if client != "" {
+		m.recordClient(client, key)
+	}
+
+	return m.entry(key), nil
+}
+
+// recordClient counts one hit of client on key. Callers must hold m.mu.
+func (m *MemoryStatisticsRepository) recordClient(client, key string) {
+	now := time.Now()
+
+	counters, exists := m.clients[client]
+	if !exists {
+		counters = make(map[string]*clientCounter)
+		m.clients[client] = counters
+	}
+	counter, exists := counters[key]
+	if !exists {
+		counter = &clientCounter{createdAt: now}
+		counters[key] = counter
+	}
+	counter.hits++
+	counter.updatedAt = now
+}
+
+// GetMostFrequent implements StatisticsRepository.GetMostFrequent.
+// Ties are broken by creation time, matching the PostgreSQL ordering.
+func (m *MemoryStatisticsRepository) GetMostFrequent(ctx context.Context) (*StatisticsEntry, error) {
+	entries, err := m.GetTopN(ctx, 1)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// GetMostFrequentInWindow implements StatisticsRepository.GetMostFrequentInWindow.
+// Sums the hourly buckets in [from, to), so from is effectively rounded down to the hour.
+func (m *MemoryStatisticsRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*StatisticsEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	start := from.UTC().Truncate(time.Hour)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	windowHits := make(map[string]int)
+	for bucket, hits := range m.history {
+		if bucket.Before(start) || !bucket.Before(to) {
+			continue
+		}
+		for key, n := range hits {
+			windowHits[key] += n
+		}
+	}
+
+	entries := make([]*StatisticsEntry, 0, len(windowHits))
+	for key, n := range windowHits {
+		entry := m.entry(key)
+		entry.Hits = n
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	sortEntries(entries)
+	return entries[0], nil
+}
+
+// GetTopN implements StatisticsRepository.GetTopN.
+// Returns copies ordered by hit count descending, then creation time ascending.
+func (m *MemoryStatisticsRepository) GetTopN(ctx context.Context, n int) ([]*StatisticsEntry, error) {
+	if n <= 0 {
+		return []*StatisticsEntry{}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	entries := m.snapshot()
+	sortEntries(entries)
+
+	if n > len(entries) {
+		n = len(entries)
+	}
+	return entries[:n], nil
+}
+
+// GetTopNForClient implements ClientStatisticsRepository.GetTopNForClient.
+// Entries carry the client's hit count and the client's first and last request times.
+func (m *MemoryStatisticsRepository) GetTopNForClient(ctx context.Context, client string, n int) ([]*StatisticsEntry, error) {
+	if n <= 0 {
+		return []*StatisticsEntry{}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	entries := make([]*StatisticsEntry, 0, len(m.clients[client]))
+	for key, counter := range m.clients[client] {
+		entry := m.entry(key)
+		entry.Hits = counter.hits
+		entry.CreatedAt = counter.createdAt
+		entry.UpdatedAt = counter.updatedAt
+		entries = append(entries, entry)
+	}
+	m.mu.RUnlock()
+
+	sortEntries(entries)
+
+	if n > len(entries) {
+		n = len(entries)
+	}
+	return entries[:n], nil
+}
+
+// GetTopClients implements ClientStatisticsRepository.GetTopClients.
+// Ties are broken by client name, matching the PostgreSQL ordering.
+func (m *MemoryStatisticsRepository) GetTopClients(ctx context.Context, input FizzBuzzInput, n int) ([]ClientHits, error) {
+	if n <= 0 {
+		return []ClientHits{}, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	key := input.GenerateStatsKey()
+
+	m.mu.RLock()
+	clients := []ClientHits{}
+	for client, counters := range m.clients {
+		if counter, exists := counters[key]; exists {
+			clients = append(clients, ClientHits{Client: client, Hits: int64(counter.hits)})
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(clients, func(i, j int) bool {
+		if clients[i].Hits != clients[j].Hits {
+			return clients[i].Hits > clients[j].Hits
+		}
+		return clients[i].Client < clients[j].Client
+	})
+
+	if n > len(clients) {
+		n = len(clients)
+	}
+	return clients[:n], nil
+}
+
+// GetStats implements StatisticsRepository.GetStats.
+func (m *MemoryStatisticsRepository) GetStats(ctx context.Context) (StatsSummary, error) {
+	if err := ctx.Err(); err != nil {
+		return StatsSummary{}, err
+	}
+
+	var summary StatsSummary
+	for _, entry := range m.snapshot() {
+		summary.TotalUniqueRequests++
+		summary.TotalRequests += int64(entry.Hits)
+		summary.MaxHits = max(summary.MaxHits, int64(entry.Hits))
+
+		if summary.FirstRequestTime == nil || entry.CreatedAt.Before(*summary.FirstRequestTime) {
+			summary.FirstRequestTime = &entry.CreatedAt
+		}
+		if summary.LastRequestTime == nil || entry.UpdatedAt.After(*summary.LastRequestTime) {
+			summary.LastRequestTime = &entry.UpdatedAt
+		}
+	}
+
+	if summary.TotalUniqueRequests > 0 {
+		summary.AvgHitsPerUniqueRequest = float64(summary.TotalRequests) / float64(summary.TotalUniqueRequests)
+	}
+
+	return summary, nil
+}
+
+// GetPoolStats implements StatisticsRepository.GetPoolStats.
+// There is no connection pool, so the repository always reports itself healthy with zero connections.
+func (m *MemoryStatisticsRepository) GetPoolStats(ctx context.Context) (*PoolStats, error) {
+	return &PoolStats{
+		Status:      "healthy",
+		CollectedAt: time.Now(),
+	}, nil
+}
+
+// Close implements StatisticsRepository.Close. There are no resources to release.
+func (m *MemoryStatisticsRepository) Close() error {
+	return nil
+}
+
+// snapshot returns copies of every tracked entry.
+func (m *MemoryStatisticsRepository) snapshot() []*StatisticsEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	m.tracker.mu.RLock()
+	keys := make([]string, 0, len(m.tracker.entries))
+	for key := range m.tracker.entries {
+		keys = append(keys, key)
+	}
+	m.tracker.mu.RUnlock()
+
+	entries := make([]*StatisticsEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, m.entry(key))
+	}
+	return entries
+}
+
+// entry returns a copy of the tracked entry for key, so callers cannot mutate the tracker.
+// Callers must hold m.mu.
+func (m *MemoryStatisticsRepository) entry(key string) *StatisticsEntry {
+	m.tracker.mu.RLock()
+	defer m.tracker.mu.RUnlock()
+
+	entry := *m.tracker.entries[key]
+	return &entry
+}
+
+// sortEntries orders entries by hits descending, then creation time and hash ascending.
+func sortEntries(entries []*StatisticsEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Hits != b.Hits {
+			return a.Hits > b.Hits
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ParametersHash < b.ParametersHash
+	})
+}
+
+// Compile-time verification that MemoryStatisticsRepository implements StatisticsRepository
+// and ClientStatisticsRepository
+var (
+	_ StatisticsRepository       = (*MemoryStatisticsRepository)(nil)
+	_ ClientStatisticsRepository = (*MemoryStatisticsRepository)(nil)
+)
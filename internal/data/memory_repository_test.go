@@ -0,0 +1,232 @@
+package data
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStatisticsRepository(t *testing.T) {
+	ctx := context.Background()
+	fizzbuzz := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	foobar := FizzBuzzInput{Int1: 2, Int2: 7, Limit: 10, Str1: "foo", Str2: "bar"}
+	rules := FizzBuzzInput{Limit: 21, Rules: []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 7, Word: "bazz"}}}
+
+	t.Run("empty repository", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+
+		most, err := repo.GetMostFrequent(ctx)
+		if err != nil || most != nil {
+			t.Errorf("expected nil entry and error, got %v, %v", most, err)
+		}
+
+		top, err := repo.GetTopN(ctx, 5)
+		if err != nil || len(top) != 0 {
+			t.Errorf("expected no entries, got %v, %v", top, err)
+		}
+
+		summary, err := repo.GetStats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.TotalRequests != 0 || summary.FirstRequestTime != nil || summary.LastRequestTime != nil {
+			t.Errorf("expected empty summary, got %+v", summary)
+		}
+	})
+
+	t.Run("records and ranks entries", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+		for i, input := range []FizzBuzzInput{fizzbuzz, foobar, foobar, rules, rules, rules} {
+			entry, err := repo.Record(ctx, input)
+			if err != nil {
+				t.Fatalf("record %d: %v", i, err)
+			}
+			if entry.ParametersHash != input.GenerateStatsKey() {
+				t.Errorf("record %d: unexpected hash %q", i, entry.ParametersHash)
+			}
+		}
+
+		most, err := repo.GetMostFrequent(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(most.Parameters, rules.Normalized()) || most.Hits != 3 {
+			t.Errorf("expected rules input with 3 hits, got %+v", most)
+		}
+
+		top, err := repo.GetTopN(ctx, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(top) != 3 {
+			t.Fatalf("expected 3 entries, got %d", len(top))
+		}
+		for i, hits := range []int{3, 2, 1} {
+			if top[i].Hits != hits {
+				t.Errorf("entry %d: expected %d hits, got %d", i, hits, top[i].Hits)
+			}
+		}
+
+		// Returned entries are copies
+		top[0].Hits = 100
+		if most, _ := repo.GetMostFrequent(ctx); most.Hits != 3 {
+			t.Errorf("mutating a returned entry changed the repository: %d hits", most.Hits)
+		}
+
+		summary, err := repo.GetStats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.TotalUniqueRequests != 3 || summary.TotalRequests != 6 || summary.MaxHits != 3 || summary.AvgHitsPerUniqueRequest != 2 {
+			t.Errorf("unexpected summary: %+v", summary)
+		}
+		if summary.FirstRequestTime == nil || summary.LastRequestTime == nil || summary.LastRequestTime.Before(*summary.FirstRequestTime) {
+			t.Errorf("unexpected request times: %v, %v", summary.FirstRequestTime, summary.LastRequestTime)
+		}
+	})
+
+	t.Run("ties favour the earliest entry", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+		repo.Record(ctx, foobar)
+		time.Sleep(time.Millisecond)
+		repo.Record(ctx, fizzbuzz)
+
+		most, _ := repo.GetMostFrequent(ctx)
+		if !reflect.DeepEqual(most.Parameters, foobar) {
+			t.Errorf("expected first recorded entry, got %+v", most.Parameters)
+		}
+	})
+
+	t.Run("two-rule and legacy inputs share one entry", func(t *testing.T) {
+		legacy := FizzBuzzInput{Int1: 3, Int2: 7, Limit: 21, Str1: "fizz", Str2: "bazz"}
+
+		for _, order := range [][]FizzBuzzInput{{rules, legacy}, {legacy, rules}} {
+			repo := NewMemoryStatisticsRepository()
+			for _, input := range order {
+				entry, err := repo.Record(ctx, input)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(entry.Parameters, legacy) {
+					t.Errorf("expected legacy parameters, got %+v", entry.Parameters)
+				}
+			}
+
+			top, _ := repo.GetTopN(ctx, 10)
+			if len(top) != 1 || top[0].Hits != 2 || !reflect.DeepEqual(top[0].Parameters, legacy) {
+				t.Errorf("expected one legacy entry with 2 hits, got %+v", top)
+			}
+		}
+	})
+
+	t.Run("window counts only hits inside the window", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+		repo.Record(ctx, fizzbuzz)
+		repo.Record(ctx, foobar)
+		repo.Record(ctx, foobar)
+
+		// Simulate older traffic for fizzbuzz two days ago
+		old := time.Now().UTC().Add(-48 * time.Hour).Truncate(time.Hour)
+		repo.history[old] = map[string]int{fizzbuzz.GenerateStatsKey(): 10}
+
+		now := time.Now()
+		recent, err := repo.GetMostFrequentInWindow(ctx, now.Add(-24*time.Hour), now.Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if recent == nil || !reflect.DeepEqual(recent.Parameters, foobar) || recent.Hits != 2 {
+			t.Errorf("expected foobar with 2 hits in the last day, got %+v", recent)
+		}
+
+		week, _ := repo.GetMostFrequentInWindow(ctx, now.Add(-7*24*time.Hour), now.Add(time.Minute))
+		if week == nil || !reflect.DeepEqual(week.Parameters, fizzbuzz) || week.Hits != 11 {
+			t.Errorf("expected fizzbuzz with 11 hits in the last week, got %+v", week)
+		}
+
+		empty, _ := repo.GetMostFrequentInWindow(ctx, now.Add(time.Hour), now.Add(2*time.Hour))
+		if empty != nil {
+			t.Errorf("expected no entry in a future window, got %+v", empty)
+		}
+	})
+
+	t.Run("attributes hits to clients", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+		repo.RecordForClient(ctx, fizzbuzz, "acme")
+		repo.RecordForClient(ctx, foobar, "acme")
+		repo.RecordForClient(ctx, foobar, "acme")
+		repo.RecordForClient(ctx, fizzbuzz, "192.0.2.1")
+		repo.Record(ctx, fizzbuzz)
+
+		most, _ := repo.GetMostFrequent(ctx)
+		if !reflect.DeepEqual(most.Parameters, fizzbuzz) || most.Hits != 3 {
+			t.Errorf("expected attributed and anonymous hits in the totals, got %+v", most)
+		}
+
+		top, err := repo.GetTopNForClient(ctx, "acme", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(top) != 2 || !reflect.DeepEqual(top[0].Parameters, foobar) || top[0].Hits != 2 || top[1].Hits != 1 {
+			t.Errorf("unexpected top requests for acme: %+v", top)
+		}
+		if none, _ := repo.GetTopNForClient(ctx, "globex", 10); len(none) != 0 {
+			t.Errorf("expected no requests for an unknown client, got %+v", none)
+		}
+
+		clients, err := repo.GetTopClients(ctx, fizzbuzz, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []ClientHits{{Client: "192.0.2.1", Hits: 1}, {Client: "acme", Hits: 1}}
+		if !reflect.DeepEqual(clients, want) {
+			t.Errorf("expected %+v, got %+v", want, clients)
+		}
+		if clients, _ := repo.GetTopClients(ctx, foobar, 1); len(clients) != 1 || clients[0].Client != "acme" {
+			t.Errorf("unexpected top client for foobar: %+v", clients)
+		}
+	})
+
+	t.Run("pool stats and close", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+
+		stats, err := repo.GetPoolStats(ctx)
+		if err != nil || stats.Status != "healthy" {
+			t.Errorf("expected healthy pool stats, got %+v, %v", stats, err)
+		}
+		if err := repo.Close(); err != nil {
+			t.Errorf("unexpected close error: %v", err)
+		}
+	})
+
+	t.Run("concurrent records", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				repo.Record(ctx, fizzbuzz)
+				repo.GetTopN(ctx, 3)
+			}()
+		}
+		wg.Wait()
+
+		most, _ := repo.GetMostFrequent(ctx)
+		if most.Hits != 50 {
+			t.Errorf("expected 50 hits, got %d", most.Hits)
+		}
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		repo := NewMemoryStatisticsRepository()
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		if _, err := repo.Record(cancelled, fizzbuzz); err == nil {
+			t.Error("expected error for cancelled context")
+		}
+	})
+}
@@ -7,11 +7,24 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
+
+	"fizzbuzz/internal/health"
 )
 
+// Rule pairs a divisor with the word substituted for its multiples.
+// Words of every matching rule are concatenated in the order the rules are declared.
+type Rule struct {
+	// Divisor is the integer whose multiples are replaced (must be between 1 and 10,000)
+	Divisor int `json:"divisor"`
+	// Word is the replacement string for multiples of Divisor (max 50 characters)
+	Word string `json:"word"`
+}
+
 // FizzBuzzInput represents the input parameters for a FizzBuzz request.
-// Contains the two divisor integers, the sequence limit, and the replacement strings.
+// Contains either the legacy two divisor integers and replacement strings, or an
+// ordered list of rules, together with the sequence limit.
 type FizzBuzzInput struct {
 	// Int1 is the first divisor integer (must be between 1 and 10,000)
 	Int1 int `json:"int1"`
@@ -23,24 +36,73 @@ type FizzBuzzInput struct {
 	Str1 string `json:"str1"`
 	// Str2 is the replacement string for numbers divisible by Int2 (max 50 characters)
 	Str2 string `json:"str2"`
+	// Rules is the ordered rule set; when provided it replaces Int1/Int2/Str1/Str2
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// RuleSet returns the effective ordered rules for the input.
+// Legacy two-rule payloads are translated to {Int1, Str1}, {Int2, Str2}.
+func (f FizzBuzzInput) RuleSet() []Rule {
+	if len(f.Rules) > 0 {
+		return f.Rules
+	}
+	return []Rule{
+		{Divisor: f.Int1, Word: f.Str1},
+		{Divisor: f.Int2, Word: f.Str2},
+	}
+}
+
+// Normalized returns the input in the shape it is stored under.
+// A two-rule set hashes like the legacy int1/int2/str1/str2 payload, so it is
+// folded into those fields; whichever shape arrives first, an entry keeps the same parameters.
+func (f FizzBuzzInput) Normalized() FizzBuzzInput {
+	if len(f.Rules) != 2 {
+		return f
+	}
+	return FizzBuzzInput{
+		Int1:  f.Rules[0].Divisor,
+		Int2:  f.Rules[1].Divisor,
+		Limit: f.Limit,
+		Str1:  f.Rules[0].Word,
+		Str2:  f.Rules[1].Word,
+	}
 }
 
 // String returns a string representation of FizzBuzzInput for debugging and logging.
 func (f FizzBuzzInput) String() string {
+	if len(f.Rules) > 0 {
+		rules := make([]string, 0, len(f.Rules))
+		for _, rule := range f.Rules {
+			rules = append(rules, fmt.Sprintf("%d:%q", rule.Divisor, rule.Word))
+		}
+		return fmt.Sprintf("FizzBuzzInput{rules=[%s], limit=%d}", strings.Join(rules, " "), f.Limit)
+	}
 	return fmt.Sprintf("FizzBuzzInput{int1=%d, int2=%d, limit=%d, str1=%q, str2=%q}",
 		f.Int1, f.Int2, f.Limit, f.Str1, f.Str2)
 }
 
 // GenerateStatsKey creates a unique key for statistics tracking based on the input parameters.
 // Uses SHA256 hash of JSON representation to ensure collision-free unique keys.
+// Two-rule inputs hash to the legacy int1/int2/str1/str2 shape so existing keys stay
+// stable; any other rule set hashes the full ordered rule list.
 func (f FizzBuzzInput) GenerateStatsKey() string {
+	rules := f.RuleSet()
+
 	// Create consistent map representation for hashing
-	data := map[string]interface{}{
-		"int1":  f.Int1,
-		"int2":  f.Int2,
-		"limit": f.Limit,
-		"str1":  f.Str1,
-		"str2":  f.Str2,
+	var data map[string]interface{}
+	if len(rules) == 2 {
+		data = map[string]interface{}{
+			"int1":  rules[0].Divisor,
+			"int2":  rules[1].Divisor,
+			"limit": f.Limit,
+			"str1":  rules[0].Word,
+			"str2":  rules[1].Word,
+		}
+	} else {
+		data = map[string]interface{}{
+			"limit": f.Limit,
+			"rules": rules,
+		}
 	}
 
 	// Marshal to JSON for consistent representation
@@ -69,6 +131,8 @@ type HealthCheckResponse struct {
 	Status     string              `json:"status"`
 	SystemInfo SystemInfo          `json:"system_info"`
 	Database   *DatabaseHealthInfo `json:"database,omitempty"`
+	// Checks lists each registered dependency check, as reported by /v1/health/ready
+	Checks []health.CheckResult `json:"checks,omitempty"`
 }
 
 // SystemInfo contains basic application information for health checks.
@@ -2,6 +2,7 @@ package data
 
 import (
 	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -211,6 +212,65 @@ func TestFizzBuzzInputGenerateStatsKey(t *testing.T) {
 	}
 }
 
+// TestFizzBuzzInputRuleSet tests translation of legacy and rule-based inputs
+func TestFizzBuzzInputRuleSet(t *testing.T) {
+	t.Run("legacy input translates to two rules", func(t *testing.T) {
+		input := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+		want := []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}}
+
+		if got := input.RuleSet(); !reflect.DeepEqual(got, want) {
+			t.Errorf("RuleSet() = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("rules take precedence", func(t *testing.T) {
+		rules := []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"}}
+		input := FizzBuzzInput{Limit: 21, Rules: rules}
+
+		if got := input.RuleSet(); !reflect.DeepEqual(got, rules) {
+			t.Errorf("RuleSet() = %v, want %v", got, rules)
+		}
+	})
+
+	t.Run("string representation lists rules", func(t *testing.T) {
+		input := FizzBuzzInput{Limit: 21, Rules: []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 7, Word: "bazz"}}}
+		want := `FizzBuzzInput{rules=[3:"fizz" 7:"bazz"], limit=21}`
+
+		if got := input.String(); got != want {
+			t.Errorf("String() = %v, want %v", got, want)
+		}
+	})
+}
+
+// TestFizzBuzzInputGenerateStatsKeyRules tests statistics keys for rule-based inputs
+func TestFizzBuzzInputGenerateStatsKeyRules(t *testing.T) {
+	legacy := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	twoRules := FizzBuzzInput{Limit: 15, Rules: []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}}}
+	threeRules := FizzBuzzInput{Limit: 15, Rules: []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "bazz"}}}
+	reordered := FizzBuzzInput{Limit: 15, Rules: []Rule{{Divisor: 5, Word: "buzz"}, {Divisor: 3, Word: "fizz"}, {Divisor: 7, Word: "bazz"}}}
+	otherWord := FizzBuzzInput{Limit: 15, Rules: []Rule{{Divisor: 3, Word: "fizz"}, {Divisor: 5, Word: "buzz"}, {Divisor: 7, Word: "boom"}}}
+
+	if legacy.GenerateStatsKey() != twoRules.GenerateStatsKey() {
+		t.Error("Expected legacy payload and equivalent two-rule payload to share a key")
+	}
+	if threeRules.GenerateStatsKey() == legacy.GenerateStatsKey() {
+		t.Error("Expected third rule to change the key")
+	}
+	if threeRules.GenerateStatsKey() == reordered.GenerateStatsKey() {
+		t.Error("Expected rule order to change the key")
+	}
+	if threeRules.GenerateStatsKey() == otherWord.GenerateStatsKey() {
+		t.Error("Expected rule word to change the key")
+	}
+
+	if got := twoRules.Normalized(); !reflect.DeepEqual(got, legacy) {
+		t.Errorf("Normalized() = %v, want %v", got, legacy)
+	}
+	if got := threeRules.Normalized(); !reflect.DeepEqual(got, threeRules) {
+		t.Errorf("Normalized() = %v, want %v", got, threeRules)
+	}
+}
+
 // TestJSONSerialization tests JSON marshaling and unmarshaling
 func TestJSONSerialization(t *testing.T) {
 	t.Run("FizzBuzzInput JSON serialization", func(t *testing.T) {
@@ -243,7 +303,7 @@ func TestJSONSerialization(t *testing.T) {
 		}
 
 		// Verify round-trip consistency
-		if unmarshaled != input {
+		if !reflect.DeepEqual(unmarshaled, input) {
 			t.Errorf("Round-trip failed: got %v, want %v", unmarshaled, input)
 		}
 	})
@@ -0,0 +1,109 @@
+package data
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor is returned when a cursor token is malformed or was issued for different parameters.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorHashLength is the number of parameter hash characters bound into a cursor token.
+const cursorHashLength = 16
+
+// Pagination holds the optional paging parameters of a FizzBuzz request.
+// Offset counts elements to skip from the start of the sequence; Cursor, when set,
+// supersedes Offset (and PageSize if it is omitted) with the values it encodes.
+type Pagination struct {
+	// Offset is the zero-based index of the first element of the page
+	Offset int `json:"offset"`
+	// PageSize is the number of elements returned per page (max 100,000)
+	PageSize int `json:"page_size"`
+	// Cursor is an opaque token from a previous page's metadata
+	Cursor string `json:"cursor"`
+}
+
+// Enabled reports whether any paging parameter was supplied.
+func (p Pagination) Enabled() bool {
+	return p.Offset != 0 || p.PageSize != 0 || p.Cursor != ""
+}
+
+// Bounds returns the inclusive range of sequence numbers covered by the page.
+func (p Pagination) Bounds(limit int) (start, end int) {
+	start = p.Offset + 1
+	end = p.Offset + p.PageSize
+	if end > limit {
+		end = limit
+	}
+	return start, end
+}
+
+// PageMetadata describes a page of a FizzBuzz sequence and links to its neighbours.
+type PageMetadata struct {
+	// Offset is the zero-based index of the first element of the page
+	Offset int `json:"offset"`
+	// PageSize is the requested number of elements per page
+	PageSize int `json:"page_size"`
+	// Limit is the length of the whole sequence
+	Limit int `json:"limit"`
+	// TotalPages is the number of pages needed to walk the whole sequence
+	TotalPages int `json:"total_pages"`
+	// NextCursor fetches the following page (omitted on the last page)
+	NextCursor string `json:"next_cursor,omitempty"`
+	// PrevCursor fetches the preceding page (omitted on the first page)
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// CalculatePageMetadata builds the metadata and neighbour cursors for a page of input's sequence.
+func CalculatePageMetadata(input FizzBuzzInput, p Pagination) PageMetadata {
+	metadata := PageMetadata{
+		Offset:     p.Offset,
+		PageSize:   p.PageSize,
+		Limit:      input.Limit,
+		TotalPages: (input.Limit + p.PageSize - 1) / p.PageSize,
+	}
+
+	if p.Offset+p.PageSize < input.Limit {
+		metadata.NextCursor = EncodeCursor(input, p.Offset+p.PageSize, p.PageSize)
+	}
+	if p.Offset > 0 {
+		metadata.PrevCursor = EncodeCursor(input, max(p.Offset-p.PageSize, 0), p.PageSize)
+	}
+
+	return metadata
+}
+
+// EncodeCursor returns an opaque token for the page at offset, bound to input's parameters
+// so it cannot be replayed against a different sequence.
+func EncodeCursor(input FizzBuzzInput, offset, pageSize int) string {
+	token := fmt.Sprintf("%d.%d.%s", offset, pageSize, input.GenerateStatsKey()[:cursorHashLength])
+	return base64.RawURLEncoding.EncodeToString([]byte(token))
+}
+
+// DecodeCursor extracts the offset and page size from a cursor issued by EncodeCursor.
+// Returns ErrInvalidCursor if the token is malformed or was issued for other parameters.
+func DecodeCursor(input FizzBuzzInput, cursor string) (offset, pageSize int, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 || parts[2] != input.GenerateStatsKey()[:cursorHashLength] {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	offset, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+	pageSize, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, ErrInvalidCursor
+	}
+
+	return offset, pageSize, nil
+}
@@ -0,0 +1,93 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPaginationBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		page       Pagination
+		limit      int
+		start, end int
+	}{
+		{"first page", Pagination{Offset: 0, PageSize: 10}, 100, 1, 10},
+		{"middle page", Pagination{Offset: 40, PageSize: 10}, 100, 41, 50},
+		{"last partial page", Pagination{Offset: 95, PageSize: 10}, 100, 96, 100},
+		{"deep page", Pagination{Offset: 1_999_999_990, PageSize: 10}, 2_000_000_000, 1_999_999_991, 2_000_000_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := tt.page.Bounds(tt.limit)
+			if start != tt.start || end != tt.end {
+				t.Errorf("Bounds(%d) = (%d, %d), want (%d, %d)", tt.limit, start, end, tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestCalculatePageMetadata(t *testing.T) {
+	input := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 25, Str1: "fizz", Str2: "buzz"}
+
+	t.Run("first page has next cursor only", func(t *testing.T) {
+		metadata := CalculatePageMetadata(input, Pagination{Offset: 0, PageSize: 10})
+
+		if metadata.TotalPages != 3 {
+			t.Errorf("expected 3 total pages, got %d", metadata.TotalPages)
+		}
+		if metadata.PrevCursor != "" {
+			t.Errorf("expected no previous cursor, got %q", metadata.PrevCursor)
+		}
+
+		offset, pageSize, err := DecodeCursor(input, metadata.NextCursor)
+		if err != nil {
+			t.Fatalf("unexpected error decoding next cursor: %v", err)
+		}
+		if offset != 10 || pageSize != 10 {
+			t.Errorf("expected next cursor at offset 10 size 10, got offset %d size %d", offset, pageSize)
+		}
+	})
+
+	t.Run("last page has previous cursor only", func(t *testing.T) {
+		metadata := CalculatePageMetadata(input, Pagination{Offset: 20, PageSize: 10})
+
+		if metadata.NextCursor != "" {
+			t.Errorf("expected no next cursor, got %q", metadata.NextCursor)
+		}
+
+		offset, _, err := DecodeCursor(input, metadata.PrevCursor)
+		if err != nil {
+			t.Fatalf("unexpected error decoding previous cursor: %v", err)
+		}
+		if offset != 10 {
+			t.Errorf("expected previous cursor at offset 10, got %d", offset)
+		}
+	})
+}
+
+func TestDecodeCursor(t *testing.T) {
+	input := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	other := FizzBuzzInput{Int1: 3, Int2: 7, Limit: 100, Str1: "fizz", Str2: "buzz"}
+	cursor := EncodeCursor(input, 50, 25)
+
+	tests := []struct {
+		name   string
+		input  FizzBuzzInput
+		cursor string
+	}{
+		{"not base64", input, "!!!"},
+		{"wrong shape", input, "YWJj"},
+		{"different parameters", other, cursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := DecodeCursor(tt.input, tt.cursor)
+			if !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("expected ErrInvalidCursor, got %v", err)
+			}
+		})
+	}
+}
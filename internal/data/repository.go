@@ -5,7 +5,10 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"fizzbuzz/internal/jsonlog"
@@ -24,6 +27,11 @@ type StatisticsRepository interface {
 	// Returns nil when no statistics exist (empty database).
 	GetMostFrequent(ctx context.Context) (*StatisticsEntry, error)
 
+	// GetMostFrequentInWindow retrieves the parameter combination requested most often
+	// between from (inclusive) and to (exclusive). Hits counts requests inside the window only.
+	// Returns nil when no requests fall within the window.
+	GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*StatisticsEntry, error)
+
 	// GetTopN retrieves the N most frequently requested parameter combinations.
 	// Returns slice ordered by hit count descending, then creation time ascending.
 	GetTopN(ctx context.Context, n int) ([]*StatisticsEntry, error)
@@ -41,6 +49,150 @@ type StatisticsRepository interface {
 	GetPoolStats(ctx context.Context) (*PoolStats, error)
 }
 
+// StatisticsDelta is a pre-aggregated number of hits for one parameter combination.
+// Used by batch writers to persist many requests in a single round trip.
+type StatisticsDelta struct {
+	// Input is the FizzBuzz parameter combination that was requested
+	Input FizzBuzzInput
+	// Hits is the number of requests to add to its counters
+	Hits int
+	// Clients breaks Hits down by the client each hit is attributed to; hits recorded
+	// without a client are not listed
+	Clients map[string]int
+}
+
+// add counts hits more requests, attributed to client unless it is empty
+func (d *StatisticsDelta) add(client string, hits int) {
+	d.Hits += hits
+	if client == "" {
+		return
+	}
+	if d.Clients == nil {
+		d.Clients = make(map[string]int)
+	}
+	d.Clients[client] += hits
+}
+
+// deltaHits returns the total number of hits in batch
+func deltaHits(batch []StatisticsDelta) int {
+	hits := 0
+	for _, delta := range batch {
+		hits += delta.Hits
+	}
+	return hits
+}
+
+// Outcomes of statistics writes reported to a WriteObserver
+const (
+	// WriteSucceeded means the hits reached the repository
+	WriteSucceeded = "success"
+	// WriteSpooled means the hits were kept in the spool until the database is back
+	WriteSpooled = "spooled"
+	// WriteFailed means the hits were dropped
+	WriteFailed = "failure"
+)
+
+// WriteObserver is told the outcome of statistics writes and how many hits each one covered
+type WriteObserver func(result string, hits int)
+
+// WriteReporter is implemented by repositories that report the outcome of their writes, including
+// the ones they complete in the background. Each hit is reported once, by the layer that decides
+// its fate, so wrappers hand the observer down instead of reporting what they delegate.
+type WriteReporter interface {
+	SetWriteObserver(observer WriteObserver)
+}
+
+// writeReports holds the observer of a WriteReporter
+type writeReports struct {
+	mu       sync.RWMutex
+	observer WriteObserver
+}
+
+func (wr *writeReports) set(observer WriteObserver) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.observer = observer
+}
+
+// report tells the observer, if any, that hits had result
+func (wr *writeReports) report(result string, hits int) {
+	wr.mu.RLock()
+	observer := wr.observer
+	wr.mu.RUnlock()
+
+	if observer != nil && hits > 0 {
+		observer(result, hits)
+	}
+}
+
+// ClientHits is the number of times one client requested a parameter combination
+type ClientHits struct {
+	// Client is an API key's client ID or a client IP address
+	Client string `json:"client"`
+	// Hits is the number of requests the client made with the parameter combination
+	Hits int64 `json:"hits"`
+}
+
+// ErrClientStatisticsUnsupported is returned by per-client queries when the repository does not
+// attribute hits to clients
+var ErrClientStatisticsUnsupported = errors.New("statistics repository does not attribute hits to clients")
+
+// ClientStatisticsRepository is implemented by repositories that attribute hits to the client
+// that made them, so consumers driving a popular parameter combination can be told apart.
+type ClientStatisticsRepository interface {
+	// RecordForClient records input like Record and attributes the hit to client.
+	RecordForClient(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error)
+
+	// GetTopNForClient retrieves the n parameter combinations client requested most often.
+	// Hits counts that client's requests only.
+	GetTopNForClient(ctx context.Context, client string, n int) ([]*StatisticsEntry, error)
+
+	// GetTopClients retrieves the n clients that requested input most often, most hits first.
+	GetTopClients(ctx context.Context, input FizzBuzzInput, n int) ([]ClientHits, error)
+}
+
+// BatchRecorder is implemented by repositories that can apply many deltas in one round trip.
+type BatchRecorder interface {
+	// RecordBatch adds each delta's hits to its parameter combination.
+	// Each parameter combination must appear at most once per batch.
+	RecordBatch(ctx context.Context, batch []StatisticsDelta) error
+}
+
+// recordBatch applies batch through repository's BatchRecorder implementation when it has one,
+// falling back to one Record or RecordForClient call per hit.
+func recordBatch(ctx context.Context, repository StatisticsRepository, batch []StatisticsDelta) error {
+	if batcher, ok := repository.(BatchRecorder); ok {
+		return batcher.RecordBatch(ctx, batch)
+	}
+
+	for _, delta := range batch {
+		attributed := 0
+		for client, hits := range delta.Clients {
+			for i := 0; i < hits; i++ {
+				if _, err := recordForClient(ctx, repository, delta.Input, client); err != nil {
+					return err
+				}
+			}
+			attributed += hits
+		}
+		for i := attributed; i < delta.Hits; i++ {
+			if _, err := repository.Record(ctx, delta.Input); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// recordForClient records input attributed to client when repository supports it, and
+// unattributed otherwise.
+func recordForClient(ctx context.Context, repository StatisticsRepository, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
+	if recorder, ok := repository.(ClientStatisticsRepository); ok && client != "" {
+		return recorder.RecordForClient(ctx, input, client)
+	}
+	return repository.Record(ctx, input)
+}
+
 // StatsSummary provides aggregate statistics for monitoring and analytics.
 // Used by health checks and operational dashboards.
 type StatsSummary struct {
@@ -109,12 +261,26 @@ func NewPostgreSQLStatisticsRepository(pool *pgxpool.Pool, timeout time.Duration
 // Record implements StatisticsRepository.Record using atomic upsert operations.
 // Uses the increment_statistics database function for thread-safe hit counting.
 func (r *PostgreSQLStatisticsRepository) Record(ctx context.Context, input FizzBuzzInput) (*StatisticsEntry, error) {
+	return r.record(ctx, input, "")
+}
+
+// RecordForClient implements ClientStatisticsRepository.RecordForClient.
+// Uses the increment_client_statistics database function, which also counts the hit for client.
+func (r *PostgreSQLStatisticsRepository) RecordForClient(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
+	return r.record(ctx, input, client)
+}
+
+// record counts one hit for input, attributed to client unless it is empty
+func (r *PostgreSQLStatisticsRepository) record(ctx context.Context, input FizzBuzzInput, client string) (*StatisticsEntry, error) {
 	start := time.Now()
 
 	// Create context with timeout for operation
 	ctx, cancel := context.WithTimeout(ctx, r.timeout)
 	defer cancel()
 
+	// Store two-rule inputs in the legacy columns their hash is derived from
+	input = input.Normalized()
+
 	// Generate parameter hash for unique identification
 	hash := input.GenerateStatsKey()
 
@@ -126,11 +292,23 @@ func (r *PostgreSQLStatisticsRepository) Record(ctx context.Context, input FizzB
 			"timeout", r.timeout)
 	}
 
+	// Rule-based inputs persist their full rule set alongside the legacy columns
+	rules, err := encodeRules(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode rules: %w", err)
+	}
+
 	// Execute atomic upsert using database function
 	var currentHits int64
-	err := r.pool.QueryRow(ctx, `
-		SELECT increment_statistics($1, $2, $3, $4, $5, $6)
-	`, hash, input.Int1, input.Int2, input.Limit, input.Str1, input.Str2).Scan(&currentHits)
+	if client == "" {
+		err = r.pool.QueryRow(ctx, `
+			SELECT increment_statistics($1, $2, $3, $4, $5, $6, $7)
+		`, hash, input.Int1, input.Int2, input.Limit, input.Str1, input.Str2, rules).Scan(&currentHits)
+	} else {
+		err = r.pool.QueryRow(ctx, `
+			SELECT increment_client_statistics($1, $2, $3, $4, $5, $6, $7, $8)
+		`, client, hash, input.Int1, input.Int2, input.Limit, input.Str1, input.Str2, rules).Scan(&currentHits)
+	}
 
 	duration := time.Since(start)
 
@@ -171,6 +349,85 @@ func (r *PostgreSQLStatisticsRepository) Record(ctx context.Context, input FizzB
 	return entry, nil
 }
 
+// RecordBatch implements BatchRecorder with a single multi-row upsert.
+// Uses the increment_statistics_batch database function, which also updates the hourly history.
+func (r *PostgreSQLStatisticsRepository) RecordBatch(ctx context.Context, batch []StatisticsDelta) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+
+	// Create context with timeout for operation
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Build parallel column arrays for unnest()
+	n := len(batch)
+	hashes := make([]string, n)
+	int1s := make([]int32, n)
+	int2s := make([]int32, n)
+	limits := make([]int32, n)
+	str1s := make([]string, n)
+	str2s := make([]string, n)
+	rules := make([]*string, n)
+	hits := make([]int64, n)
+	clients := make([]*string, n)
+
+	for i, delta := range batch {
+		delta.Input = delta.Input.Normalized()
+		encoded, err := encodeRules(delta.Input)
+		if err != nil {
+			return fmt.Errorf("failed to encode rules: %w", err)
+		}
+		if encoded != nil {
+			js := encoded.(string)
+			rules[i] = &js
+		}
+
+		hashes[i] = delta.Input.GenerateStatsKey()
+		int1s[i] = int32(delta.Input.Int1)
+		int2s[i] = int32(delta.Input.Int2)
+		limits[i] = int32(delta.Input.Limit)
+		str1s[i] = delta.Input.Str1
+		str2s[i] = delta.Input.Str2
+		hits[i] = int64(delta.Hits)
+
+		if len(delta.Clients) > 0 {
+			encoded, err := json.Marshal(delta.Clients)
+			if err != nil {
+				return fmt.Errorf("failed to encode client hits: %w", err)
+			}
+			js := string(encoded)
+			clients[i] = &js
+		}
+	}
+
+	_, err := r.pool.Exec(ctx, `
+		SELECT increment_statistics_batch($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, hashes, int1s, int2s, limits, str1s, str2s, rules, hits, clients)
+
+	if r.logger != nil {
+		if err != nil {
+			r.logger.WarnWithContext(ctx, "database batch record operation failed",
+				"operation", "RecordBatch",
+				"rows", n,
+				"error", err,
+				"duration_ms", time.Since(start).Milliseconds())
+		} else {
+			r.logger.DebugWithContext(ctx, "database batch record operation completed",
+				"operation", "RecordBatch",
+				"rows", n,
+				"duration_ms", time.Since(start).Milliseconds())
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to record statistics batch: %w", err)
+	}
+	return nil
+}
+
 // GetMostFrequent implements StatisticsRepository.GetMostFrequent.
 // Uses optimized database function for efficient query with proper indexing.
 func (r *PostgreSQLStatisticsRepository) GetMostFrequent(ctx context.Context) (*StatisticsEntry, error) {
@@ -199,6 +456,34 @@ func (r *PostgreSQLStatisticsRepository) GetMostFrequent(ctx context.Context) (*
 	return entry, nil
 }
 
+// GetMostFrequentInWindow implements StatisticsRepository.GetMostFrequentInWindow.
+// Aggregates the hourly history buckets, so from is effectively rounded down to the hour.
+func (r *PostgreSQLStatisticsRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*StatisticsEntry, error) {
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Query most frequent within the window using database function
+	rows, err := r.pool.Query(ctx, "SELECT * FROM get_most_frequent_request_in_window($1, $2)", from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query most frequent in window: %w", err)
+	}
+	defer rows.Close()
+
+	// Check if any results exist
+	if !rows.Next() {
+		return nil, rows.Err() // No requests within the window
+	}
+
+	// Scan result into StatisticsEntry
+	entry, err := r.scanStatisticsEntry(rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan most frequent in window result: %w", err)
+	}
+
+	return entry, nil
+}
+
 // GetTopN implements StatisticsRepository.GetTopN.
 // Returns the N most frequently requested parameter combinations.
 func (r *PostgreSQLStatisticsRepository) GetTopN(ctx context.Context, n int) ([]*StatisticsEntry, error) {
@@ -236,6 +521,81 @@ func (r *PostgreSQLStatisticsRepository) GetTopN(ctx context.Context, n int) ([]
 	return entries, nil
 }
 
+// GetTopNForClient implements ClientStatisticsRepository.GetTopNForClient.
+// Joins the client's counters to the parameter combinations they count.
+func (r *PostgreSQLStatisticsRepository) GetTopNForClient(ctx context.Context, client string, n int) ([]*StatisticsEntry, error) {
+	if n <= 0 {
+		return []*StatisticsEntry{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT s.parameters_hash, s.int1, s.int2, s.limit_value, s.str1, s.str2,
+			c.hits, c.created_at, c.updated_at, s.rules
+		FROM fizzbuzz_client_statistics c
+		JOIN fizzbuzz_statistics s ON s.parameters_hash = c.parameters_hash
+		WHERE c.client = $1
+		ORDER BY c.hits DESC, c.created_at ASC
+		LIMIT $2
+	`, client, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top %d requests of client: %w", n, err)
+	}
+	defer rows.Close()
+
+	var entries []*StatisticsEntry
+	for rows.Next() {
+		entry, err := r.scanStatisticsEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan client request result: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetTopClients implements ClientStatisticsRepository.GetTopClients.
+func (r *PostgreSQLStatisticsRepository) GetTopClients(ctx context.Context, input FizzBuzzInput, n int) ([]ClientHits, error) {
+	if n <= 0 {
+		return []ClientHits{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT client, hits
+		FROM fizzbuzz_client_statistics
+		WHERE parameters_hash = $1
+		ORDER BY hits DESC, client ASC
+		LIMIT $2
+	`, input.GenerateStatsKey(), n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top %d clients: %w", n, err)
+	}
+	defer rows.Close()
+
+	clients := []ClientHits{}
+	for rows.Next() {
+		var client ClientHits
+		if err := rows.Scan(&client.Client, &client.Hits); err != nil {
+			return nil, fmt.Errorf("failed to scan top client result: %w", err)
+		}
+		clients = append(clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error during row iteration: %w", err)
+	}
+
+	return clients, nil
+}
+
 // GetStats implements StatisticsRepository.GetStats.
 // Provides aggregate statistics for monitoring and operational dashboards.
 func (r *PostgreSQLStatisticsRepository) GetStats(ctx context.Context) (StatsSummary, error) {
@@ -391,6 +751,7 @@ func (r *PostgreSQLStatisticsRepository) calculatePoolStatus(stat *pgxpool.Stat)
 func (r *PostgreSQLStatisticsRepository) scanStatisticsEntry(rows pgx.Rows) (*StatisticsEntry, error) {
 	var entry StatisticsEntry
 	var createdAt, updatedAt time.Time
+	var rules []byte
 
 	err := rows.Scan(
 		&entry.ParametersHash,
@@ -402,17 +763,43 @@ func (r *PostgreSQLStatisticsRepository) scanStatisticsEntry(rows pgx.Rows) (*St
 		&entry.Hits,
 		&createdAt,
 		&updatedAt,
+		&rules,
 	)
 
 	if err != nil {
 		return nil, err
 	}
 
+	if len(rules) > 0 {
+		if err := json.Unmarshal(rules, &entry.Parameters.Rules); err != nil {
+			return nil, fmt.Errorf("failed to decode rules: %w", err)
+		}
+	}
+
 	entry.CreatedAt = createdAt
 	entry.UpdatedAt = updatedAt
 
 	return &entry, nil
 }
 
-// Compile-time verification that PostgreSQLStatisticsRepository implements StatisticsRepository
-var _ StatisticsRepository = (*PostgreSQLStatisticsRepository)(nil)
+// encodeRules returns the JSON encoding of a rule-based input's rules for the
+// rules JSONB column, or nil (SQL NULL) for legacy two-rule inputs.
+func encodeRules(input FizzBuzzInput) (any, error) {
+	if len(input.Rules) == 0 {
+		return nil, nil
+	}
+
+	js, err := json.Marshal(input.Rules)
+	if err != nil {
+		return nil, err
+	}
+	return string(js), nil
+}
+
+// Compile-time verification that PostgreSQLStatisticsRepository implements StatisticsRepository,
+// BatchRecorder and ClientStatisticsRepository
+var (
+	_ StatisticsRepository       = (*PostgreSQLStatisticsRepository)(nil)
+	_ BatchRecorder              = (*PostgreSQLStatisticsRepository)(nil)
+	_ ClientStatisticsRepository = (*PostgreSQLStatisticsRepository)(nil)
+)
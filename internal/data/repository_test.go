@@ -2,6 +2,7 @@ package data
 
 import (
 	"context"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -81,6 +82,12 @@ func (m *MockStatisticsRepository) GetMostFrequent(ctx context.Context) (*Statis
 	return mostFrequent, nil
 }
 
+// GetMostFrequentInWindow implements StatisticsRepository.GetMostFrequentInWindow for mock testing.
+// The mock keeps no history, so every recorded hit is treated as falling inside the window.
+func (m *MockStatisticsRepository) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*StatisticsEntry, error) {
+	return m.GetMostFrequent(ctx)
+}
+
 // GetTopN implements StatisticsRepository.GetTopN for mock testing.
 func (m *MockStatisticsRepository) GetTopN(ctx context.Context, n int) ([]*StatisticsEntry, error) {
 	if m.getTopNFunc != nil {
@@ -212,7 +219,7 @@ func testRecordNewEntry(t *testing.T, repo StatisticsRepository) {
 		t.Errorf("Expected hits = 1, got %d", entry.Hits)
 	}
 
-	if entry.Parameters != input {
+	if !reflect.DeepEqual(entry.Parameters, input) {
 		t.Errorf("Parameters mismatch: expected %v, got %v", input, entry.Parameters)
 	}
 }
@@ -281,7 +288,7 @@ func testGetMostFrequentSingle(t *testing.T, repo StatisticsRepository) {
 		t.Fatal("GetMostFrequent() returned nil")
 	}
 
-	if most.Parameters != recorded.Parameters {
+	if !reflect.DeepEqual(most.Parameters, recorded.Parameters) {
 		t.Errorf("Parameters mismatch: expected %v, got %v", recorded.Parameters, most.Parameters)
 	}
 }
@@ -318,7 +325,7 @@ func testGetMostFrequentMultiple(t *testing.T, repo StatisticsRepository) {
 	}
 
 	// input2 should be most frequent with 2 hits
-	if most.Parameters != input2 {
+	if !reflect.DeepEqual(most.Parameters, input2) {
 		t.Errorf("Expected most frequent to be input2, got %v", most.Parameters)
 	}
 }
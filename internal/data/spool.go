@@ -0,0 +1,507 @@
+// Package data provides a local spool file that keeps statistics writes during database outages.
+// Hits are appended as JSON lines while the write circuit breaker is open and replayed once it closes.
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrSpoolFull is returned by Spool.Append when the spool file has reached its maximum size
+var ErrSpoolFull = errors.New("statistics spool is full")
+
+// SpoolStats reports how much is waiting in the spool
+type SpoolStats struct {
+	// Depth is the number of spooled hits waiting to be replayed
+	Depth int `json:"depth"`
+	// SizeBytes is the current size of the spool file
+	SizeBytes int64 `json:"size_bytes"`
+	// MaxBytes is the size at which new hits are rejected
+	MaxBytes int64 `json:"max_bytes"`
+}
+
+// spoolRecord is one line of the spool file
+type spoolRecord struct {
+	Input      FizzBuzzInput  `json:"input"`
+	Hits       int            `json:"hits"`
+	Clients    map[string]int `json:"clients,omitempty"`
+	RecordedAt time.Time      `json:"recorded_at"`
+
+	// end is the offset just past the record's line in the spool file
+	end int64
+}
+
+// Spool is a file of statistics hits, appended to during outages and compacted as they are
+// replayed. It is safe for concurrent use and survives restarts: hits left over from a previous
+// run, including a replay cut short, are counted when the file is reopened.
+type Spool struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	maxBytes int64
+	size     int64
+	depth    int
+
+	// replay serializes replays: each one owns the records in the file when it started
+	replay sync.Mutex
+
+	// syncInterval batches the fsyncs of appends; zero syncs every append
+	syncInterval time.Duration
+	dirty        bool
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+// OpenSpool opens or creates the spool file at path. Appends fail with ErrSpoolFull once the file
+// reaches maxBytes; a non-positive maxBytes defaults to 64 MiB. Appends are synced to disk every
+// syncInterval, or before Append returns when syncInterval is zero or negative.
+func OpenSpool(path string, maxBytes int64, syncInterval time.Duration) (*Spool, error) {
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20 // Default 64 MiB
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open statistics spool: %w", err)
+	}
+
+	s := &Spool{path: path, file: file, maxBytes: maxBytes, syncInterval: max(syncInterval, 0), done: make(chan struct{})}
+
+	// Count hits spooled by a previous run
+	records, size, err := s.readAll()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Cut off a torn last line so that the next append starts a line of its own
+	if info, err := file.Stat(); err != nil || info.Size() > size {
+		if err == nil {
+			err = file.Truncate(size)
+		}
+		if err == nil {
+			err = file.Sync()
+		}
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate torn statistics spool record: %w", err)
+		}
+	}
+	s.size = size
+	for _, record := range records {
+		s.depth += record.Hits
+	}
+
+	// Drop what a replay cut short by a crash had already written
+	if _, err := s.resumeLocked(records); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if s.syncInterval > 0 {
+		go s.syncPeriodically()
+	}
+
+	return s, nil
+}
+
+// Append spools hits for one parameter combination
+func (s *Spool) Append(delta StatisticsDelta) error {
+	line, err := encodeSpoolRecord(delta)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+int64(len(line)) > s.maxBytes {
+		return ErrSpoolFull
+	}
+	if err := s.writeLocked(line, delta.Hits); err != nil {
+		return err
+	}
+
+	s.dirty = true
+	if s.syncInterval == 0 {
+		return s.syncLocked()
+	}
+	return nil
+}
+
+// Replay passes everything in the spool to write, aggregated per parameter combination, in
+// batches of at most batchSize deltas. Hits stay in the file until the replay ends: each written
+// batch is recorded in a checkpoint file next to the spool, and the file is compacted once at the
+// end, without what was written. A failed batch leaves the rest spooled; after a crash, or a
+// replay that never finishes, the checkpoint lets the next run skip what was written, so at most
+// the batch in flight is written twice.
+// Hits are replayed at the time of the replay, so hourly history attributes them to the hour the
+// database came back.
+// Returns the number of hits written.
+func (s *Spool) Replay(ctx context.Context, batchSize int, write func(ctx context.Context, batch []StatisticsDelta) error) (int, error) {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	s.replay.Lock()
+	defer s.replay.Unlock()
+
+	// Take the spooled hits; concurrent appends land after the bytes this replay owns
+	s.mu.Lock()
+	records, owned, err := s.readAll()
+	if err == nil {
+		// A checkpoint left by an earlier replay whose compaction failed
+		var resumed bool
+		if resumed, err = s.resumeLocked(records); resumed {
+			records, owned, err = s.readAll()
+		}
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	deltas := aggregateSpoolRecords(records)
+	if len(deltas) == 0 {
+		return 0, nil
+	}
+
+	checksum, err := s.checksum(owned)
+	if err != nil {
+		return 0, err
+	}
+	checkpoint := spoolCheckpoint{owned: owned, checksum: checksum}
+
+	var replayErr error
+	for start := 0; start < len(deltas); start += batchSize {
+		end := start + batchSize
+		if end > len(deltas) {
+			end = len(deltas)
+		}
+
+		if replayErr = write(ctx, deltas[start:end]); replayErr != nil {
+			break
+		}
+		checkpoint.deltas = end
+		if replayErr = s.saveCheckpoint(checkpoint); replayErr != nil {
+			break
+		}
+	}
+
+	written := deltaHits(deltas[:checkpoint.deltas])
+	if checkpoint.deltas == 0 {
+		return 0, replayErr
+	}
+
+	s.mu.Lock()
+	err = s.compactLocked(deltas[checkpoint.deltas:], owned, written)
+	s.mu.Unlock()
+	if err == nil {
+		err = s.removeCheckpoint()
+	}
+	return written, errors.Join(replayErr, err)
+}
+
+// Stats returns the spool's current depth and size
+func (s *Spool) Stats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SpoolStats{Depth: s.depth, SizeBytes: s.size, MaxBytes: s.maxBytes}
+}
+
+// Close syncs and closes the spool file; spooled hits stay on disk for the next run
+func (s *Spool) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return errors.Join(s.syncLocked(), s.file.Close())
+}
+
+// syncPeriodically syncs appends to disk every syncInterval until the spool is closed
+func (s *Spool) syncPeriodically() {
+	ticker := time.NewTicker(s.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			// A failed sync is retried on the next tick
+			s.mu.Lock()
+			s.syncLocked()
+			s.mu.Unlock()
+		}
+	}
+}
+
+// syncLocked flushes appends to disk if there are any; s.mu must be held
+func (s *Spool) syncLocked() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync statistics spool: %w", err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// writeLocked appends an encoded line; s.mu must be held
+func (s *Spool) writeLocked(line []byte, hits int) error {
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write statistics spool: %w", err)
+	}
+	s.depth += hits
+	return nil
+}
+
+// compactLocked drops replayed hits from the spool: the file is rewritten as remaining, the deltas
+// of the first owned bytes that were not written, followed by whatever was appended after them.
+// The new file is synced and renamed over the old one, so a crash leaves one or the other. hits
+// is the number of hits the rewrite drops; s.mu must be held.
+func (s *Spool) compactLocked(remaining []StatisticsDelta, owned int64, hits int) error {
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to compact statistics spool: %w", err)
+	}
+
+	size, err := s.writeCompacted(tmp, remaining, owned)
+	if err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(s.path))
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact statistics spool: %w", err)
+	}
+
+	s.file.Close()
+	s.file = tmp
+	s.size = size
+	s.depth -= hits
+	s.dirty = false
+	return nil
+}
+
+// spoolCheckpoint records how far a replay got: the first deltas deltas aggregated from the first
+// owned bytes of the spool were written. checksum is the CRC-32 of those bytes, which ties the
+// checkpoint to one version of the file: once the file is compacted it no longer matches.
+type spoolCheckpoint struct {
+	owned    int64
+	checksum uint32
+	deltas   int
+}
+
+// spoolCheckpointFormat has a fixed width, so that saving a checkpoint overwrites the last one
+// in a single small write
+const spoolCheckpointFormat = "%020d %08x %020d\n"
+
+// checkpointPath is the file holding the checkpoint of the spool at path
+func checkpointPath(path string) string {
+	return path + ".checkpoint"
+}
+
+// saveCheckpoint durably records checkpoint, creating the checkpoint file if needed
+func (s *Spool) saveCheckpoint(checkpoint spoolCheckpoint) error {
+	path := checkpointPath(s.path)
+	_, statErr := os.Stat(path)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to save statistics spool checkpoint: %w", err)
+	}
+	defer file.Close()
+
+	line := fmt.Sprintf(spoolCheckpointFormat, checkpoint.owned, checkpoint.checksum, checkpoint.deltas)
+	if _, err = file.WriteAt([]byte(line), 0); err == nil {
+		err = file.Sync()
+	}
+	if err == nil && statErr != nil {
+		err = syncDir(filepath.Dir(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save statistics spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+// removeCheckpoint deletes the checkpoint file once its replay is compacted away
+func (s *Spool) removeCheckpoint() error {
+	if err := os.Remove(checkpointPath(s.path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove statistics spool checkpoint: %w", err)
+	}
+	return nil
+}
+
+// resumeLocked compacts away the deltas a checkpoint says were written by a replay that did not
+// get to compact the spool, then removes the checkpoint. A missing, unreadable or stale checkpoint
+// is removed without touching the spool. records are the spool's records. Reports whether the
+// spool was compacted; s.mu must be held unless the spool is not shared yet.
+func (s *Spool) resumeLocked(records []spoolRecord) (bool, error) {
+	contents, err := os.ReadFile(checkpointPath(s.path))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	var checkpoint spoolCheckpoint
+	valid := err == nil
+	if valid {
+		_, err := fmt.Sscanf(string(contents), "%d %x %d", &checkpoint.owned, &checkpoint.checksum, &checkpoint.deltas)
+		valid = err == nil && checkpoint.owned <= s.size && checkpoint.deltas > 0
+	}
+	if valid {
+		checksum, err := s.checksum(checkpoint.owned)
+		if err != nil {
+			return false, err
+		}
+		valid = checksum == checkpoint.checksum
+	}
+
+	if valid {
+		// The checkpoint covers the records of its first owned bytes only
+		n := 0
+		for n < len(records) && records[n].end <= checkpoint.owned {
+			n++
+		}
+		deltas := aggregateSpoolRecords(records[:n])
+		if checkpoint.deltas > len(deltas) {
+			checkpoint.deltas = len(deltas)
+		}
+		if err := s.compactLocked(deltas[checkpoint.deltas:], checkpoint.owned, deltaHits(deltas[:checkpoint.deltas])); err != nil {
+			return false, err
+		}
+	}
+	return valid, s.removeCheckpoint()
+}
+
+// checksum returns the CRC-32 of the first n bytes of the spool file
+func (s *Spool) checksum(n int64) (uint32, error) {
+	hash := crc32.NewIEEE()
+	if _, err := io.Copy(hash, io.NewSectionReader(s.file, 0, n)); err != nil {
+		return 0, fmt.Errorf("failed to read statistics spool: %w", err)
+	}
+	return hash.Sum32(), nil
+}
+
+// writeCompacted writes remaining and the bytes of the spool file after owned to tmp and syncs
+// it, returning its size
+func (s *Spool) writeCompacted(tmp *os.File, remaining []StatisticsDelta, owned int64) (int64, error) {
+	var size int64
+	for _, delta := range remaining {
+		line, err := encodeSpoolRecord(delta)
+		if err != nil {
+			return 0, err
+		}
+		n, err := tmp.Write(line)
+		size += int64(n)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	appended := io.NewSectionReader(s.file, owned, s.size-owned)
+	n, err := io.Copy(tmp, appended)
+	size += n
+	if err != nil {
+		return 0, err
+	}
+
+	return size, tmp.Sync()
+}
+
+// syncDir syncs a directory so that a rename inside it is durable
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// readAll decodes every record in the spool file and returns them with the offset just past the
+// last complete line. A torn last line, left by a crash mid-write, has no newline: it is skipped
+// and not counted. The caller must hold s.mu unless the spool is not shared yet.
+func (s *Spool) readAll() ([]spoolRecord, int64, error) {
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("failed to read statistics spool: %w", err)
+	}
+
+	var records []spoolRecord
+	var size int64
+	reader := bufio.NewReader(s.file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return records, size, nil
+		}
+		size += int64(len(line))
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			var record spoolRecord
+			if json.Unmarshal(line, &record) == nil && record.Hits > 0 {
+				record.end = size
+				records = append(records, record)
+			}
+		}
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read statistics spool: %w", err)
+		}
+	}
+}
+
+// encodeSpoolRecord renders delta as one newline-terminated JSON line
+func encodeSpoolRecord(delta StatisticsDelta) ([]byte, error) {
+	line, err := json.Marshal(spoolRecord{Input: delta.Input, Hits: delta.Hits, Clients: delta.Clients, RecordedAt: time.Now().UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode statistics spool record: %w", err)
+	}
+	return append(line, '\n'), nil
+}
+
+// aggregateSpoolRecords sums hits per parameters hash, keeping first-seen order
+func aggregateSpoolRecords(records []spoolRecord) []StatisticsDelta {
+	index := make(map[string]int, len(records))
+	deltas := make([]StatisticsDelta, 0, len(records))
+
+	for _, record := range records {
+		key := record.Input.GenerateStatsKey()
+		i, exists := index[key]
+		if !exists {
+			i = len(deltas)
+			index[key] = i
+			deltas = append(deltas, StatisticsDelta{Input: record.Input})
+		}
+
+		// Hits not listed under a client were recorded without one
+		attributed := 0
+		for client, hits := range record.Clients {
+			deltas[i].add(client, hits)
+			attributed += hits
+		}
+		deltas[i].add("", record.Hits-attributed)
+	}
+
+	return deltas
+}
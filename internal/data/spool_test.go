@@ -0,0 +1,292 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestSpool(t *testing.T, maxBytes int64) (*Spool, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "statistics.spool")
+	spool, err := OpenSpool(path, maxBytes, 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	t.Cleanup(func() { spool.Close() })
+	return spool, path
+}
+
+func TestSpoolAppendAndReopen(t *testing.T) {
+	spool, path := openTestSpool(t, 0)
+	fizz := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+
+	for _, delta := range []StatisticsDelta{{Input: fizz, Hits: 1}, {Input: fizz, Hits: 4}} {
+		if err := spool.Append(delta); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+
+	stats := spool.Stats()
+	if stats.Depth != 5 || stats.SizeBytes == 0 || stats.MaxBytes != 64<<20 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	spool.Close()
+
+	// A torn line left by a crash is ignored
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"input":{"int1":3`)
+	file.Close()
+
+	reopened, err := OpenSpool(path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen spool: %v", err)
+	}
+	defer reopened.Close()
+
+	if depth := reopened.Stats().Depth; depth != 5 {
+		t.Errorf("expected depth 5 after reopening, got %d", depth)
+	}
+}
+
+func TestSpoolAppendAfterTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statistics.spool")
+	if err := os.WriteFile(path, []byte(`{"input":{"int1":3`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	spool, err := OpenSpool(path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to open spool: %v", err)
+	}
+	if stats := spool.Stats(); stats.Depth != 0 || stats.SizeBytes != 0 {
+		t.Errorf("expected the torn record to be dropped, got %+v", stats)
+	}
+	if err := spool.Append(StatisticsDelta{Input: FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}, Hits: 1}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	spool.Close()
+
+	reopened, err := OpenSpool(path, 0, 0)
+	if err != nil {
+		t.Fatalf("failed to reopen spool: %v", err)
+	}
+	defer reopened.Close()
+
+	if depth := reopened.Stats().Depth; depth != 1 {
+		t.Errorf("expected the hit appended after the torn record to survive, got depth %d", depth)
+	}
+	written, err := reopened.Replay(context.Background(), 10, func(ctx context.Context, batch []StatisticsDelta) error {
+		return nil
+	})
+	if err != nil || written != 1 {
+		t.Errorf("expected 1 hit replayed, got %d, %v", written, err)
+	}
+}
+
+func TestSpoolFull(t *testing.T) {
+	spool, _ := openTestSpool(t, 150)
+	delta := StatisticsDelta{Input: FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}, Hits: 1}
+
+	if err := spool.Append(delta); err != nil {
+		t.Fatalf("first append failed: %v", err)
+	}
+	if err := spool.Append(delta); !errors.Is(err, ErrSpoolFull) {
+		t.Errorf("expected ErrSpoolFull, got %v", err)
+	}
+	if depth := spool.Stats().Depth; depth != 1 {
+		t.Errorf("expected depth 1, got %d", depth)
+	}
+}
+
+func TestSpoolReplay(t *testing.T) {
+	fizz := FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+	foo := FizzBuzzInput{Int1: 2, Int2: 7, Limit: 50, Str1: "foo", Str2: "bar"}
+	baz := FizzBuzzInput{Int1: 4, Int2: 9, Limit: 90, Str1: "baz", Str2: "qux"}
+
+	fill := func(t *testing.T) *Spool {
+		spool, _ := openTestSpool(t, 0)
+		for _, delta := range []StatisticsDelta{{Input: fizz, Hits: 1}, {Input: foo, Hits: 1}, {Input: fizz, Hits: 2}, {Input: baz, Hits: 1}} {
+			if err := spool.Append(delta); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return spool
+	}
+
+	t.Run("aggregates and empties the spool", func(t *testing.T) {
+		spool := fill(t)
+
+		var batches [][]StatisticsDelta
+		written, err := spool.Replay(context.Background(), 2, func(ctx context.Context, batch []StatisticsDelta) error {
+			batches = append(batches, append([]StatisticsDelta(nil), batch...))
+			return nil
+		})
+		if err != nil || written != 5 {
+			t.Fatalf("expected 5 hits written, got %d, %v", written, err)
+		}
+
+		if len(batches) != 2 || len(batches[0]) != 2 || batches[0][0].Hits != 3 || batches[1][0].Input.Str1 != "baz" {
+			t.Errorf("unexpected batches: %+v", batches)
+		}
+		if stats := spool.Stats(); stats.Depth != 0 || stats.SizeBytes != 0 {
+			t.Errorf("expected empty spool, got %+v", stats)
+		}
+	})
+
+	t.Run("keeps client attribution", func(t *testing.T) {
+		spool, _ := openTestSpool(t, 0)
+		for _, delta := range []StatisticsDelta{
+			{Input: fizz, Hits: 2, Clients: map[string]int{"acme": 1}},
+			{Input: fizz, Hits: 1, Clients: map[string]int{"acme": 1}},
+		} {
+			if err := spool.Append(delta); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var replayed []StatisticsDelta
+		spool.Replay(context.Background(), 10, func(ctx context.Context, batch []StatisticsDelta) error {
+			replayed = append(replayed, batch...)
+			return nil
+		})
+		if len(replayed) != 1 || replayed[0].Hits != 3 || len(replayed[0].Clients) != 1 || replayed[0].Clients["acme"] != 2 {
+			t.Errorf("unexpected replay: %+v", replayed)
+		}
+	})
+
+	t.Run("keeps what fails to replay", func(t *testing.T) {
+		spool := fill(t)
+
+		calls := 0
+		written, err := spool.Replay(context.Background(), 2, func(ctx context.Context, batch []StatisticsDelta) error {
+			calls++
+			if calls == 2 {
+				return errors.New("database down")
+			}
+			return nil
+		})
+		if err == nil || written != 4 {
+			t.Fatalf("expected 4 hits written and an error, got %d, %v", written, err)
+		}
+		if depth := spool.Stats().Depth; depth != 1 {
+			t.Errorf("expected the failed batch to stay spooled, got depth %d", depth)
+		}
+	})
+
+	t.Run("keeps what a replay cut short did not write", func(t *testing.T) {
+		spool, path := openTestSpool(t, 0)
+		for _, delta := range []StatisticsDelta{{Input: fizz, Hits: 1}, {Input: foo, Hits: 1}, {Input: fizz, Hits: 2}, {Input: baz, Hits: 1}} {
+			if err := spool.Append(delta); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The second batch never returns, as when the process dies while writing it
+		started := make(chan struct{})
+		hang := make(chan struct{})
+		t.Cleanup(func() { close(hang) })
+		go spool.Replay(context.Background(), 1, func(ctx context.Context, batch []StatisticsDelta) error {
+			if batch[0].Input.Str1 == "foo" {
+				close(started)
+				<-hang
+			}
+			return nil
+		})
+		<-started
+
+		// Written batches stay in the file until the replay ends
+		if depth := spool.Stats().Depth; depth != 5 {
+			t.Errorf("expected the spool to be compacted only at the end of the replay, got depth %d", depth)
+		}
+
+		// Hits spooled during the replay are kept too
+		if err := spool.Append(StatisticsDelta{Input: baz, Hits: 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		reopened, err := OpenSpool(path, 0, 0)
+		if err != nil {
+			t.Fatalf("failed to reopen spool: %v", err)
+		}
+		defer reopened.Close()
+
+		if depth := reopened.Stats().Depth; depth != 4 {
+			t.Fatalf("expected the 4 hits not yet written to survive, got depth %d", depth)
+		}
+
+		hits := make(map[string]int)
+		written, err := reopened.Replay(context.Background(), 10, func(ctx context.Context, batch []StatisticsDelta) error {
+			for _, delta := range batch {
+				hits[delta.Input.Str1] += delta.Hits
+			}
+			return nil
+		})
+		if err != nil || written != 4 || hits["foo"] != 1 || hits["baz"] != 3 || hits["fizz"] != 0 {
+			t.Errorf("unexpected replay after reopening: %v, %d, %v", hits, written, err)
+		}
+	})
+}
+
+func TestSpoolStaleCheckpoint(t *testing.T) {
+	spool, path := openTestSpool(t, 0)
+	if err := spool.Append(StatisticsDelta{Input: FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}, Hits: 2}); err != nil {
+		t.Fatal(err)
+	}
+	spool.Close()
+
+	// A checkpoint whose checksum does not match the file belongs to a compacted version of it
+	stale := fmt.Sprintf(spoolCheckpointFormat, spool.Stats().SizeBytes, 0, 1)
+	if err := os.WriteFile(checkpointPath(path), []byte(stale), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := OpenSpool(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	if depth := reopened.Stats().Depth; depth != 2 {
+		t.Errorf("expected a stale checkpoint to be ignored, got depth %d", depth)
+	}
+	if _, err := os.Stat(checkpointPath(path)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the stale checkpoint to be removed, got %v", err)
+	}
+}
+
+func TestSpoolSyncInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statistics.spool")
+	spool, err := OpenSpool(path, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	delta := StatisticsDelta{Input: FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}, Hits: 2}
+	if err := spool.Append(delta); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := spool.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if err := spool.Close(); err == nil {
+		t.Error("expected an error closing twice")
+	}
+
+	reopened, err := OpenSpool(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if depth := reopened.Stats().Depth; depth != 2 {
+		t.Errorf("expected depth 2 after reopening, got %d", depth)
+	}
+}
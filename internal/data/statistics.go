@@ -52,6 +52,8 @@ func (st *StatisticsTracker) Record(input *FizzBuzzInput) {
 	// Generate unique key for parameter combination
 	key := input.GenerateStatsKey()
 
+	now := time.Now()
+
 	// Acquire write lock for concurrent safety
 	st.mu.Lock()
 	defer st.mu.Unlock()
@@ -60,6 +62,7 @@ func (st *StatisticsTracker) Record(input *FizzBuzzInput) {
 	if entry, exists := st.entries[key]; exists {
 		// Increment hit count for existing entry
 		entry.Hits++
+		entry.UpdatedAt = now
 
 		// Update most frequent if this entry now has the highest count
 		if st.mostFrequent == nil || entry.Hits > st.mostFrequent.Hits {
@@ -68,8 +71,11 @@ func (st *StatisticsTracker) Record(input *FizzBuzzInput) {
 	} else {
 		// Create new entry for first-time parameter combination
 		newEntry := &StatisticsEntry{
-			Parameters: *input,
-			Hits:       1,
+			ParametersHash: key,
+			Parameters:     input.Normalized(),
+			Hits:           1,
+			CreatedAt:      now,
+			UpdatedAt:      now,
 		}
 		st.entries[key] = newEntry
 
@@ -160,6 +166,8 @@ func (st *StatisticsTracker) GetMostFrequentCompat() *StatisticsEntry {
 type StatisticsService struct {
 	// repository provides persistent storage operations
 	repository StatisticsRepository
+	// reports counts Record outcomes when the repository does not report its own writes
+	reports writeReports
 }
 
 // NewStatisticsService creates a new service with the given repository dependency
@@ -169,15 +177,29 @@ func NewStatisticsService(repository StatisticsRepository) *StatisticsService {
 	}
 }
 
-// Record records statistics using repository.Record() with context and error handling
-func (ss *StatisticsService) Record(ctx context.Context, input *FizzBuzzInput) error {
-	_, err := ss.repository.Record(ctx, *input)
+// Record records statistics using repository.Record() with context and error handling.
+// The hit is attributed to client when it is not empty and the repository supports it.
+func (ss *StatisticsService) Record(ctx context.Context, input *FizzBuzzInput, client string) error {
+	_, err := recordForClient(ctx, ss.repository, *input, client)
 	if err != nil {
+		ss.reports.report(WriteFailed, 1)
 		return fmt.Errorf("statistics service record failed: %w", err)
 	}
+	ss.reports.report(WriteSucceeded, 1)
 	return nil
 }
 
+// SetWriteObserver reports the outcome of every recorded hit to observer: through the repository
+// when it is a WriteReporter, whose writes may complete after Record returns, and from Record
+// otherwise
+func (ss *StatisticsService) SetWriteObserver(observer WriteObserver) {
+	if reporter, ok := ss.repository.(WriteReporter); ok {
+		reporter.SetWriteObserver(observer)
+		return
+	}
+	ss.reports.set(observer)
+}
+
 // GetMostFrequent gets most frequent statistics from repository with context
 func (ss *StatisticsService) GetMostFrequent(ctx context.Context) (*StatisticsEntry, error) {
 	entry, err := ss.repository.GetMostFrequent(ctx)
@@ -187,12 +209,67 @@ func (ss *StatisticsService) GetMostFrequent(ctx context.Context) (*StatisticsEn
 	return entry, nil
 }
 
+// GetMostFrequentInWindow gets the most frequent statistics between from and to from repository with context
+func (ss *StatisticsService) GetMostFrequentInWindow(ctx context.Context, from, to time.Time) (*StatisticsEntry, error) {
+	entry, err := ss.repository.GetMostFrequentInWindow(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("statistics service get most frequent in window failed: %w", err)
+	}
+	return entry, nil
+}
+
+// GetTopN gets the n most frequent parameter combinations from repository with context
+func (ss *StatisticsService) GetTopN(ctx context.Context, n int) ([]*StatisticsEntry, error) {
+	entries, err := ss.repository.GetTopN(ctx, n)
+	if err != nil {
+		return nil, fmt.Errorf("statistics service get top n failed: %w", err)
+	}
+	return entries, nil
+}
+
+// GetTopNForClient gets the n parameter combinations client requested most often.
+// Returns ErrClientStatisticsUnsupported when the repository does not attribute hits to clients.
+func (ss *StatisticsService) GetTopNForClient(ctx context.Context, client string, n int) ([]*StatisticsEntry, error) {
+	repository, ok := ss.repository.(ClientStatisticsRepository)
+	if !ok {
+		return nil, ErrClientStatisticsUnsupported
+	}
+	entries, err := repository.GetTopNForClient(ctx, client, n)
+	if err != nil {
+		return nil, fmt.Errorf("statistics service get top n for client failed: %w", err)
+	}
+	return entries, nil
+}
+
+// GetTopClients gets the n clients that requested input most often.
+// Returns ErrClientStatisticsUnsupported when the repository does not attribute hits to clients.
+func (ss *StatisticsService) GetTopClients(ctx context.Context, input *FizzBuzzInput, n int) ([]ClientHits, error) {
+	repository, ok := ss.repository.(ClientStatisticsRepository)
+	if !ok {
+		return nil, ErrClientStatisticsUnsupported
+	}
+	clients, err := repository.GetTopClients(ctx, *input, n)
+	if err != nil {
+		return nil, fmt.Errorf("statistics service get top clients failed: %w", err)
+	}
+	return clients, nil
+}
+
+// GetStats gets aggregate statistics from repository with context
+func (ss *StatisticsService) GetStats(ctx context.Context) (StatsSummary, error) {
+	summary, err := ss.repository.GetStats(ctx)
+	if err != nil {
+		return StatsSummary{}, fmt.Errorf("statistics service get stats failed: %w", err)
+	}
+	return summary, nil
+}
+
 // Legacy compatibility methods for transition period
 
 // RecordLegacy provides legacy-compatible Record method (no context, no error return)
 // Used during transition to maintain compatibility with existing HTTP handlers
 func (ss *StatisticsService) RecordLegacy(input *FizzBuzzInput) {
-	err := ss.Record(context.Background(), input)
+	err := ss.Record(context.Background(), input, "")
 	if err != nil {
 		// In production, this would use structured logging
 		// For now, we silently handle the error to maintain compatibility
@@ -228,6 +305,43 @@ func (ss *StatisticsService) EntryCountLegacy() int {
 	return count
 }
 
+// Flush writes any statistics buffered by a background writer and waits for the result.
+// A no-op for repositories that write synchronously.
+func (ss *StatisticsService) Flush(ctx context.Context) error {
+	if buffered, ok := ss.repository.(*BufferedStatisticsRepository); ok {
+		if err := buffered.Flush(ctx); err != nil {
+			return fmt.Errorf("statistics service flush failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetCircuitBreakerStats returns the state of the circuit breakers protecting the repository,
+// looking through a background writer if there is one. ok is false when there is no breaker.
+func (ss *StatisticsService) GetCircuitBreakerStats() (stats CircuitBreakerRepositoryStats, ok bool) {
+	repository := ss.repository
+	if buffered, isBuffered := repository.(*BufferedStatisticsRepository); isBuffered {
+		repository = buffered.repository
+	}
+	if cbRepository, isProtected := repository.(*CircuitBreakerRepository); isProtected {
+		return cbRepository.GetCircuitBreakerStats(), true
+	}
+	return CircuitBreakerRepositoryStats{}, false
+}
+
+// GetSpoolStats returns the depth of the outage spool behind the circuit breaker, looking
+// through a background writer if there is one. ok is false when no spool is configured.
+func (ss *StatisticsService) GetSpoolStats() (stats SpoolStats, ok bool) {
+	repository := ss.repository
+	if buffered, isBuffered := repository.(*BufferedStatisticsRepository); isBuffered {
+		repository = buffered.repository
+	}
+	if cbRepository, isProtected := repository.(*CircuitBreakerRepository); isProtected {
+		return cbRepository.GetSpoolStats()
+	}
+	return SpoolStats{}, false
+}
+
 // Close closes the database repository connections
 // Story 4.6: Graceful shutdown support for PostgreSQL connections
 func (ss *StatisticsService) Close() error {
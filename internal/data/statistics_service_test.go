@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 )
 
 // Tests use existing MockStatisticsRepository from repository_test.go
@@ -67,7 +68,7 @@ func TestStatisticsService_Record(t *testing.T) {
 			mockRepo.recordFunc = tt.mockBehavior
 			service := NewStatisticsService(mockRepo)
 
-			err := service.Record(context.Background(), &tt.input)
+			err := service.Record(context.Background(), &tt.input, "")
 
 			if tt.expectedError && err == nil {
 				t.Error("Expected error but got none")
@@ -184,3 +185,36 @@ func TestStatisticsService_LegacyCompatibility(t *testing.T) {
 		}
 	})
 }
+
+func TestStatisticsService_Flush(t *testing.T) {
+	ctx := context.Background()
+	input := &FizzBuzzInput{Int1: 3, Int2: 5, Limit: 15, Str1: "fizz", Str2: "buzz"}
+
+	t.Run("synchronous repository", func(t *testing.T) {
+		service := NewStatisticsService(NewMockStatisticsRepository())
+		if err := service.Flush(ctx); err != nil {
+			t.Errorf("expected no-op flush, got %v", err)
+		}
+	})
+
+	t.Run("buffered repository", func(t *testing.T) {
+		mockRepo := NewMockStatisticsRepository()
+		buffered := NewBufferedStatisticsRepository(mockRepo, BufferedWriterConfig{FlushInterval: time.Hour}, nil)
+		defer buffered.Close()
+		service := NewStatisticsService(buffered)
+
+		if err := service.Record(ctx, input, ""); err != nil {
+			t.Fatal(err)
+		}
+		if most, _ := mockRepo.GetMostFrequent(ctx); most != nil {
+			t.Fatalf("expected hit to be buffered, found %+v", most)
+		}
+
+		if err := service.Flush(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if most, _ := mockRepo.GetMostFrequent(ctx); most == nil || most.Hits != 1 {
+			t.Errorf("expected flushed hit, got %+v", most)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package data
 
 import (
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -87,7 +88,7 @@ func TestStatisticsTracker_Record(t *testing.T) {
 
 	// Verify most frequent is still the first input (2 hits vs 1 hit)
 	most = tracker.GetMostFrequent()
-	if most.Parameters != *input1 {
+	if !reflect.DeepEqual(most.Parameters, *input1) {
 		t.Errorf("Expected most frequent to be input1, got %+v", most.Parameters)
 	}
 	if most.Hits != 2 {
@@ -105,7 +106,7 @@ func TestStatisticsTracker_MostFrequentUpdates(t *testing.T) {
 	// Record input1 once
 	tracker.Record(input1)
 	most := tracker.GetMostFrequent()
-	if most.Parameters != *input1 || most.Hits != 1 {
+	if !reflect.DeepEqual(most.Parameters, *input1) || most.Hits != 1 {
 		t.Errorf("Expected input1 with 1 hit, got %+v with %d hits", most.Parameters, most.Hits)
 	}
 
@@ -114,7 +115,7 @@ func TestStatisticsTracker_MostFrequentUpdates(t *testing.T) {
 	tracker.Record(input2)
 
 	most = tracker.GetMostFrequent()
-	if most.Parameters != *input2 || most.Hits != 2 {
+	if !reflect.DeepEqual(most.Parameters, *input2) || most.Hits != 2 {
 		t.Errorf("Expected input2 with 2 hits, got %+v with %d hits", most.Parameters, most.Hits)
 	}
 
@@ -123,7 +124,7 @@ func TestStatisticsTracker_MostFrequentUpdates(t *testing.T) {
 	tracker.Record(input1)
 
 	most = tracker.GetMostFrequent()
-	if most.Parameters != *input1 || most.Hits != 3 {
+	if !reflect.DeepEqual(most.Parameters, *input1) || most.Hits != 3 {
 		t.Errorf("Expected input1 with 3 hits, got %+v with %d hits", most.Parameters, most.Hits)
 	}
 }
@@ -306,7 +307,7 @@ func TestStatisticsTracker_EdgeCases(t *testing.T) {
 	for _, tc := range testCases {
 		found := false
 		for _, entry := range stats {
-			if entry.Parameters == tc.input {
+			if reflect.DeepEqual(entry.Parameters, tc.input) {
 				found = true
 				if entry.Hits != 1 {
 					t.Errorf("Expected 1 hit for %s, got %d", tc.name, entry.Hits)
@@ -344,13 +345,13 @@ func TestStatisticsTracker_GetStats(t *testing.T) {
 	// Verify stats data integrity
 	found0, found1 := false, false
 	for _, entry := range stats {
-		if entry.Parameters == *inputs[0] {
+		if reflect.DeepEqual(entry.Parameters, *inputs[0]) {
 			found0 = true
 			if entry.Hits != 2 {
 				t.Errorf("Expected 2 hits for input[0], got %d", entry.Hits)
 			}
 		}
-		if entry.Parameters == *inputs[1] {
+		if reflect.DeepEqual(entry.Parameters, *inputs[1]) {
 			found1 = true
 			if entry.Hits != 1 {
 				t.Errorf("Expected 1 hit for input[1], got %d", entry.Hits)
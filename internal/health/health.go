@@ -0,0 +1,141 @@
+// Package health provides a registry of named health checks for liveness and readiness probes.
+// Each check reports a status, its latency and whether its failure makes the service unready.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the outcome of a health check or of a whole report
+type Status string
+
+const (
+	// StatusPass means the dependency is healthy
+	StatusPass Status = "pass"
+	// StatusWarn means the dependency is degraded but the service can keep serving
+	StatusWarn Status = "warn"
+	// StatusFail means the dependency is unavailable
+	StatusFail Status = "fail"
+)
+
+// Result is what a check function reports about its dependency
+type Result struct {
+	// Status is the dependency's health
+	Status Status
+	// Message optionally explains a warn or fail status
+	Message string
+	// Details carries check-specific data such as pool metrics
+	Details any
+}
+
+// CheckFunc inspects one dependency. It must respect ctx cancellation.
+type CheckFunc func(ctx context.Context) Result
+
+// CheckResult is the outcome of one check in a Report
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    Status  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Message   string  `json:"message,omitempty"`
+	Details   any     `json:"details,omitempty"`
+}
+
+// Report aggregates the results of every registered check
+type Report struct {
+	// Status is fail if a critical check failed, warn if any other check did not pass, else pass
+	Status Status        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// check is a registered named check
+type check struct {
+	name     string
+	critical bool
+	fn       CheckFunc
+}
+
+// Registry holds named checks and runs them concurrently
+type Registry struct {
+	mu      sync.RWMutex
+	checks  []check
+	timeout time.Duration
+}
+
+// NewRegistry creates an empty registry whose checks are each bounded by timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = 1 * time.Second // Default 1s per check
+	}
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a named check. A failing critical check makes the whole report fail;
+// a failing non-critical check only degrades it to warn.
+func (r *Registry) Register(name string, critical bool, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check{name: name, critical: critical, fn: fn})
+}
+
+// Run executes every check concurrently and aggregates the results in registration order.
+// A check that does not return within the registry timeout is reported as failed.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]check(nil), r.checks...)
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = r.runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusPass, Checks: results}
+	for _, result := range results {
+		switch {
+		case result.Status == StatusFail && result.Critical:
+			report.Status = StatusFail
+		case result.Status != StatusPass && report.Status == StatusPass:
+			report.Status = StatusWarn
+		}
+	}
+
+	return report
+}
+
+// runCheck runs a single check under the registry timeout and measures its latency
+func (r *Registry) runCheck(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan Result, 1)
+	go func() {
+		done <- c.fn(ctx)
+	}()
+
+	var result Result
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = Result{Status: StatusFail, Message: "check timed out"}
+	}
+
+	return CheckResult{
+		Name:      c.name,
+		Status:    result.Status,
+		Critical:  c.critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Message:   result.Message,
+		Details:   result.Details,
+	}
+}
@@ -0,0 +1,113 @@
+package health
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func pass(ctx context.Context) Result { return Result{Status: StatusPass} }
+func warn(ctx context.Context) Result { return Result{Status: StatusWarn, Message: "degraded"} }
+func fail(ctx context.Context) Result { return Result{Status: StatusFail, Message: "down"} }
+
+func TestRegistryRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(r *Registry)
+		expected Status
+	}{
+		{
+			name:     "no checks",
+			register: func(r *Registry) {},
+			expected: StatusPass,
+		},
+		{
+			name: "all pass",
+			register: func(r *Registry) {
+				r.Register("a", true, pass)
+				r.Register("b", false, pass)
+			},
+			expected: StatusPass,
+		},
+		{
+			name: "non-critical failure degrades",
+			register: func(r *Registry) {
+				r.Register("a", true, pass)
+				r.Register("b", false, fail)
+			},
+			expected: StatusWarn,
+		},
+		{
+			name: "critical warning degrades",
+			register: func(r *Registry) {
+				r.Register("a", true, warn)
+			},
+			expected: StatusWarn,
+		},
+		{
+			name: "critical failure fails",
+			register: func(r *Registry) {
+				r.Register("a", false, warn)
+				r.Register("b", true, fail)
+				r.Register("c", false, pass)
+			},
+			expected: StatusFail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(time.Second)
+			tt.register(r)
+
+			report := r.Run(context.Background())
+			if report.Status != tt.expected {
+				t.Errorf("expected status %q, got %q", tt.expected, report.Status)
+			}
+		})
+	}
+}
+
+func TestRegistryResults(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("database", false, func(ctx context.Context) Result {
+		time.Sleep(5 * time.Millisecond)
+		return Result{Status: StatusFail, Message: "connection refused", Details: map[string]int{"attempts": 1}}
+	})
+	r.Register("limiter", true, pass)
+
+	report := r.Run(context.Background())
+	if len(report.Checks) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(report.Checks))
+	}
+
+	db := report.Checks[0]
+	if db.Name != "database" || db.Status != StatusFail || db.Critical || db.Message != "connection refused" || db.Details == nil {
+		t.Errorf("unexpected database result: %+v", db)
+	}
+	if db.LatencyMs < 5 {
+		t.Errorf("expected latency of at least 5ms, got %v", db.LatencyMs)
+	}
+
+	if limiter := report.Checks[1]; limiter.Name != "limiter" || !limiter.Critical || limiter.Status != StatusPass {
+		t.Errorf("unexpected limiter result: %+v", limiter)
+	}
+}
+
+func TestRegistryTimeout(t *testing.T) {
+	r := NewRegistry(20 * time.Millisecond)
+	r.Register("slow", true, func(ctx context.Context) Result {
+		time.Sleep(time.Second)
+		return Result{Status: StatusPass}
+	})
+
+	start := time.Now()
+	report := r.Run(context.Background())
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected run to stop at the timeout, took %v", elapsed)
+	}
+	if report.Status != StatusFail || report.Checks[0].Message != "check timed out" {
+		t.Errorf("expected timed out critical failure, got %+v", report)
+	}
+}
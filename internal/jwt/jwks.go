@@ -0,0 +1,191 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// minRSABits is the smallest RSA modulus accepted from a key set
+const minRSABits = 2048
+
+// KeySet holds the keys tokens are verified with. Each key serves exactly one algorithm, so a
+// token cannot have an RSA public key used as an HMAC secret.
+type KeySet struct {
+	keys []verificationKey
+}
+
+// verificationKey is one key and the algorithm it verifies
+type verificationKey struct {
+	id        string
+	algorithm string
+	// material is a []byte secret for HS256, *rsa.PublicKey for RS256, *ecdsa.PublicKey for ES256
+	material crypto.PublicKey
+}
+
+// NewSecretKeySet returns a key set verifying HS256 tokens signed with secret
+func NewSecretKeySet(secret []byte) *KeySet {
+	return &KeySet{keys: []verificationKey{{algorithm: HS256, material: secret}}}
+}
+
+// LoadJWKS reads a JSON Web Key Set from path; see ParseJWKS
+func LoadJWKS(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// jwk is the subset of a JSON Web Key the API understands
+type jwk struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+	// Symmetric
+	K string `json:"k"`
+}
+
+// ParseJWKS decodes a JSON Web Key Set. RSA keys verify RS256, P-256 EC keys ES256 and "oct"
+// keys HS256. Encryption keys, other key types and curves, and keys whose "alg" names another
+// algorithm are skipped; malformed keys are an error, as is a set with no usable key.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	ks := &KeySet{}
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.verificationKey()
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d (kid %q): %w", i, k.KeyID, err)
+		}
+		if key == nil || (k.Algorithm != "" && k.Algorithm != key.algorithm) {
+			continue
+		}
+		ks.keys = append(ks.keys, *key)
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, errors.New("JWKS contains no RS256, ES256 or HS256 signing key")
+	}
+	return ks, nil
+}
+
+// Len returns the number of keys in the set
+func (ks *KeySet) Len() int {
+	return len(ks.keys)
+}
+
+// candidates returns the keys that may have signed a token with alg and kid. A key without an
+// ID matches any kid; a token without a kid is tried against every key for alg.
+func (ks *KeySet) candidates(alg, kid string) []crypto.PublicKey {
+	if ks == nil {
+		return nil
+	}
+
+	var keys []crypto.PublicKey
+	for _, key := range ks.keys {
+		if key.algorithm != alg {
+			continue
+		}
+		if kid != "" && key.id != "" && key.id != kid {
+			continue
+		}
+		keys = append(keys, key.material)
+	}
+	return keys
+}
+
+// verificationKey decodes k, returning nil for key types and curves that are not supported
+func (k *jwk) verificationKey() (*verificationKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("e: %w", err)
+		}
+		if n.BitLen() < minRSABits {
+			return nil, fmt.Errorf("RSA modulus of %d bits is shorter than %d", n.BitLen(), minRSABits)
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("e: unsupported RSA exponent")
+		}
+		return &verificationKey{
+			id:        k.KeyID,
+			algorithm: RS256,
+			material:  &rsa.PublicKey{N: n, E: int(e.Int64())},
+		}, nil
+
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != 32 {
+			return nil, errors.New("x: must be 32 base64url-encoded bytes")
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil || len(y) != 32 {
+			return nil, errors.New("y: must be 32 base64url-encoded bytes")
+		}
+		// crypto/ecdh rejects points that are not on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("invalid P-256 point: %w", err)
+		}
+		return &verificationKey{
+			id:        k.KeyID,
+			algorithm: ES256,
+			material: &ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(x),
+				Y:     new(big.Int).SetBytes(y),
+			},
+		}, nil
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errors.New("k: must be base64url-encoded")
+		}
+		return &verificationKey{id: k.KeyID, algorithm: HS256, material: secret}, nil
+	}
+	return nil, nil
+}
+
+// decodeBigInt decodes a base64url unsigned big-endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("must be a base64url-encoded integer")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
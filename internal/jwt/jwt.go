@@ -0,0 +1,267 @@
+// Package jwt verifies JSON Web Tokens (RFC 7519) in compact serialization. It supports the
+// HS256, RS256 and ES256 algorithms, checks the registered exp, nbf, aud and iss claims and reads
+// OAuth scopes. Keys come from a KeySet: a shared secret or a JSON Web Key Set (RFC 7517).
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Errors returned by Validator.Validate. Each is wrapped with details of the failing token.
+var (
+	ErrMalformed            = errors.New("jwt: malformed token")
+	ErrUnsupportedAlgorithm = errors.New("jwt: unsupported algorithm")
+	ErrUnknownKey           = errors.New("jwt: no key matches the token")
+	ErrSignature            = errors.New("jwt: invalid signature")
+	ErrExpired              = errors.New("jwt: token is expired")
+	ErrMissingClaim         = errors.New("jwt: required claim is missing")
+	ErrNotYetValid          = errors.New("jwt: token is not valid yet")
+	ErrAudience             = errors.New("jwt: token is not intended for this audience")
+	ErrIssuer               = errors.New("jwt: token is from an untrusted issuer")
+)
+
+// Supported signing algorithms
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+// Claims are the claims of a verified token that the API relies on
+type Claims struct {
+	// Subject identifies the principal the token was issued to ("sub")
+	Subject string
+	// Issuer identifies who issued the token ("iss")
+	Issuer string
+	// Audience lists the recipients the token is intended for ("aud")
+	Audience []string
+	// ExpiresAt is when the token expires ("exp")
+	ExpiresAt time.Time
+	// NotBefore is when the token becomes valid ("nbf"), zero when absent
+	NotBefore time.Time
+	// Scopes are the space-separated "scope" claim, or the "scp" claim as a string or list
+	Scopes []string
+}
+
+// HasScope reports whether the token grants scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// Validator verifies tokens against Keys and checks their registered claims.
+// Tokens without an exp claim are rejected.
+type Validator struct {
+	// Keys verifies signatures
+	Keys *KeySet
+	// Audience, if set, must be listed in the token's aud claim
+	Audience string
+	// Issuer, if set, must equal the token's iss claim
+	Issuer string
+	// Leeway tolerates clock skew when checking exp and nbf
+	Leeway time.Duration
+	// Now returns the current time; time.Now when nil
+	Now func() time.Time
+}
+
+// header is the decoded JOSE header
+type header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// rawClaims is the JSON payload before aud and the scopes are normalised
+type rawClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scp       json.RawMessage `json:"scp"`
+}
+
+// Validate verifies token's signature and claims and returns the claims
+func (v *Validator) Validate(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: expected 3 segments, got %d", ErrMalformed, len(parts))
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformed, err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrMalformed, err)
+	}
+
+	if err := v.verify(h, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var raw rawClaims
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrMalformed, err)
+	}
+	claims, err := raw.normalise()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verify checks signature over signingInput with the key the header selects
+func (v *Validator) verify(h header, signingInput string, signature []byte) error {
+	switch h.Algorithm {
+	case HS256, RS256, ES256:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, h.Algorithm)
+	}
+
+	keys := v.Keys.candidates(h.Algorithm, h.KeyID)
+	if len(keys) == 0 {
+		return fmt.Errorf("%w: alg %s, kid %q", ErrUnknownKey, h.Algorithm, h.KeyID)
+	}
+
+	digest := sha256.Sum256([]byte(signingInput))
+	for _, key := range keys {
+		if verifySignature(h.Algorithm, key, signingInput, digest[:], signature) {
+			return nil
+		}
+	}
+	return ErrSignature
+}
+
+// verifySignature reports whether signature is valid for alg and key, which candidates has
+// already matched to alg
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, digest, signature []byte) bool {
+	switch alg {
+	case HS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(signature, mac.Sum(nil))
+	case RS256:
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), crypto.SHA256, digest, signature) == nil
+	case ES256:
+		// JWS encodes the signature as R || S, each left-padded to 32 bytes
+		if len(signature) != 64 {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(key.(*ecdsa.PublicKey), digest, r, s)
+	}
+	return false
+}
+
+// checkClaims applies the time, audience and issuer checks
+func (v *Validator) checkClaims(claims *Claims) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+
+	if claims.ExpiresAt.IsZero() {
+		return fmt.Errorf("%w: exp", ErrMissingClaim)
+	}
+	if !now.Before(claims.ExpiresAt.Add(v.Leeway)) {
+		return fmt.Errorf("%w: expired at %s", ErrExpired, claims.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if !claims.NotBefore.IsZero() && now.Add(v.Leeway).Before(claims.NotBefore) {
+		return fmt.Errorf("%w: valid from %s", ErrNotYetValid, claims.NotBefore.UTC().Format(time.RFC3339))
+	}
+	if v.Audience != "" && !slices.Contains(claims.Audience, v.Audience) {
+		return fmt.Errorf("%w: want %q", ErrAudience, v.Audience)
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return fmt.Errorf("%w: %q", ErrIssuer, claims.Issuer)
+	}
+	return nil
+}
+
+// normalise converts the payload into Claims: aud and scp may each be a string or a list,
+// and the NumericDate claims may have a fractional part
+func (raw *rawClaims) normalise() (*Claims, error) {
+	claims := &Claims{Subject: raw.Subject, Issuer: raw.Issuer}
+
+	var err error
+	if claims.Audience, err = stringOrList(raw.Audience); err != nil {
+		return nil, fmt.Errorf("%w: aud: %v", ErrMalformed, err)
+	}
+	if claims.ExpiresAt, err = numericDate(raw.ExpiresAt); err != nil {
+		return nil, fmt.Errorf("%w: exp: %v", ErrMalformed, err)
+	}
+	if claims.NotBefore, err = numericDate(raw.NotBefore); err != nil {
+		return nil, fmt.Errorf("%w: nbf: %v", ErrMalformed, err)
+	}
+
+	claims.Scopes = append(claims.Scopes, strings.Fields(raw.Scope)...)
+	scp, err := stringOrList(raw.Scp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: scp: %v", ErrMalformed, err)
+	}
+	for _, value := range scp {
+		claims.Scopes = append(claims.Scopes, strings.Fields(value)...)
+	}
+
+	return claims, nil
+}
+
+// stringOrList decodes a JSON string or array of strings; an absent value gives nil
+func stringOrList(data json.RawMessage) ([]string, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		return []string{single}, nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, errors.New("must be a string or an array of strings")
+	}
+	return list, nil
+}
+
+// numericDate converts seconds since the epoch into a time; an absent value gives the zero time
+func numericDate(n *json.Number) (time.Time, error) {
+	if n == nil {
+		return time.Time{}, nil
+	}
+	seconds, err := n.Float64()
+	if err != nil || math.IsInf(seconds, 0) || seconds <= 0 {
+		return time.Time{}, errors.New("must be a positive number of seconds")
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9)), nil
+}
+
+// decodeSegment decodes a base64url JSON segment into dst, keeping numbers exact
+func decodeSegment(segment string, dst any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	return dec.Decode(dst)
+}
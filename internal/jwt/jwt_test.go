@@ -0,0 +1,217 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sign builds a compact token over header and claims with key: a []byte secret,
+// *rsa.PrivateKey or *ecdsa.PrivateKey
+func sign(t *testing.T, header, claims map[string]any, key any) string {
+	t.Helper()
+
+	encode := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func TestValidator(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": %q, "e": %q},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": %q, "y": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": %q, "e": "AQAB"}
+	]}`, b64(rsaKey.N.Bytes()), b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))),
+		b64(rsaKey.N.Bytes()))
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(jwks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := LoadJWKS(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keySet.Len() != 2 {
+		t.Fatalf("expected the encryption key to be skipped, got %d keys", keySet.Len())
+	}
+
+	jwksValidator := &Validator{Keys: keySet, Audience: "fizzbuzz", Issuer: "https://auth.example.com", Now: func() time.Time { return now }}
+	secretValidator := &Validator{Keys: NewSecretKeySet(secret), Leeway: time.Minute, Now: func() time.Time { return now }}
+
+	claims := func(overrides map[string]any) map[string]any {
+		c := map[string]any{
+			"sub":   "acme",
+			"iss":   "https://auth.example.com",
+			"aud":   []string{"fizzbuzz", "other"},
+			"exp":   now.Add(time.Hour).Unix(),
+			"scope": "fizzbuzz:write statistics:read",
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+	rs256 := map[string]any{"alg": "RS256", "kid": "rsa-1"}
+	es256 := map[string]any{"alg": "ES256", "kid": "ec-1"}
+	hs256 := map[string]any{"alg": "HS256"}
+
+	tests := []struct {
+		name      string
+		validator *Validator
+		token     string
+		wantErr   error
+	}{
+		{"RS256", jwksValidator, sign(t, rs256, claims(nil), rsaKey), nil},
+		{"ES256", jwksValidator, sign(t, es256, claims(nil), ecKey), nil},
+		{"ES256 without kid", jwksValidator, sign(t, map[string]any{"alg": "ES256"}, claims(nil), ecKey), nil},
+		{"HS256", secretValidator, sign(t, hs256, claims(nil), secret), nil},
+		{"expired within leeway", secretValidator, sign(t, hs256, claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()}), secret), nil},
+		{"single audience string", jwksValidator, sign(t, rs256, claims(map[string]any{"aud": "fizzbuzz"}), rsaKey), nil},
+		{"expired", jwksValidator, sign(t, rs256, claims(map[string]any{"exp": now.Add(-time.Second).Unix()}), rsaKey), ErrExpired},
+		{"no exp", jwksValidator, sign(t, rs256, claims(map[string]any{"exp": nil}), rsaKey), ErrMissingClaim},
+		{"not yet valid", jwksValidator, sign(t, rs256, claims(map[string]any{"nbf": now.Add(time.Minute).Unix()}), rsaKey), ErrNotYetValid},
+		{"wrong audience", jwksValidator, sign(t, rs256, claims(map[string]any{"aud": "billing"}), rsaKey), ErrAudience},
+		{"wrong issuer", jwksValidator, sign(t, rs256, claims(map[string]any{"iss": "https://evil.example.com"}), rsaKey), ErrIssuer},
+		{"wrong secret", secretValidator, sign(t, hs256, claims(nil), []byte("another secret of thirty-two bytes")), ErrSignature},
+		{"unknown kid", jwksValidator, sign(t, map[string]any{"alg": "RS256", "kid": "rsa-2"}, claims(nil), rsaKey), ErrUnknownKey},
+		{"RSA key used as HMAC secret", jwksValidator, sign(t, hs256, claims(nil), rsaKey.N.Bytes()), ErrUnknownKey},
+		{"none algorithm", jwksValidator, sign(t, map[string]any{"alg": "none"}, claims(nil), []byte{}), ErrUnsupportedAlgorithm},
+		{"two segments", jwksValidator, "abc.def", ErrMalformed},
+		{"malformed claims", secretValidator, sign(t, hs256, claims(map[string]any{"exp": "tomorrow"}), secret), ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.validator.Validate(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && got.Subject != "acme" {
+				t.Errorf("unexpected claims: %+v", got)
+			}
+		})
+	}
+}
+
+func TestClaimsScopes(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	validator := &Validator{Keys: NewSecretKeySet(secret)}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		want   []string
+	}{
+		{"scope string", map[string]any{"scope": "a b"}, []string{"a", "b"}},
+		{"scp list", map[string]any{"scp": []string{"a", "b"}}, []string{"a", "b"}},
+		{"scp string", map[string]any{"scp": "a"}, []string{"a"}},
+		{"none", map[string]any{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.claims["exp"] = exp
+			claims, err := validator.Validate(sign(t, map[string]any{"alg": "HS256"}, tt.claims, secret))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(claims.Scopes, tt.want) {
+				t.Errorf("expected scopes %v, got %v", tt.want, claims.Scopes)
+			}
+			for _, scope := range tt.want {
+				if !claims.HasScope(scope) {
+					t.Errorf("expected HasScope(%q)", scope)
+				}
+			}
+		})
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	tests := []struct {
+		name    string
+		jwks    string
+		wantErr bool
+	}{
+		{"oct key", `{"keys": [{"kty": "oct", "k": "c2VjcmV0"}]}`, false},
+		{"unsupported curve skipped", `{"keys": [{"kty": "EC", "crv": "P-384", "x": "", "y": ""}, {"kty": "oct", "k": "c2VjcmV0"}]}`, false},
+		{"no usable key", `{"keys": [{"kty": "OKP", "crv": "Ed25519", "x": "abc"}]}`, true},
+		{"mismatched alg skipped", `{"keys": [{"kty": "oct", "alg": "RS256", "k": "c2VjcmV0"}]}`, true},
+		{"short RSA modulus", fmt.Sprintf(`{"keys": [{"kty": "RSA", "n": %q, "e": "AQAB"}]}`, b64(make([]byte, 128))), true},
+		{"point not on curve", fmt.Sprintf(`{"keys": [{"kty": "EC", "crv": "P-256", "x": %q, "y": %q}]}`, b64(make([]byte, 32)), b64(make([]byte, 32))), true},
+		{"not JSON", `keys`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseJWKS([]byte(tt.jwks))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
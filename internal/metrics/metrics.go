@@ -0,0 +1,380 @@
+// Package metrics implements a minimal Prometheus-compatible metrics registry.
+// Supports labelled counters and histograms plus callback metrics evaluated at scrape time,
+// alone or as a collector reporting several families from one snapshot,
+// rendered in the Prometheus text exposition format (version 0.0.4).
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefBuckets are the default histogram buckets, in seconds, suited to HTTP request latencies
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metric is implemented by every metric family held in a Registry
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and renders them in registration order
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo renders every registered metric family in the text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	buf := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(buf)
+	}
+	err := buf.Flush()
+	return cw.n, err
+}
+
+// Handler returns an http.Handler serving the registry for Prometheus scrapes
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		r.WriteTo(w)
+	})
+}
+
+// desc holds the identity shared by every metric family
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.name, d.typ)
+}
+
+// labelKey joins label values into a map key; \xff cannot appear in valid UTF-8
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// checkLabels panics on a label count mismatch, which is always a programming error
+func (d desc) checkLabels(values []string) {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+}
+
+// CounterVec is a family of monotonically increasing counters partitioned by labels
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labelValues []string
+	value       float64
+}
+
+// NewCounterVec registers a counter family with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		values: make(map[string]*counterValue),
+	}
+	r.register(c)
+	return c
+}
+
+// Inc adds one to the counter identified by labelValues
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter identified by labelValues
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.checkLabels(labelValues)
+	if v < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.name))
+	}
+
+	key := labelKey(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	value, exists := c.values[key]
+	if !exists {
+		value = &counterValue{labelValues: append([]string(nil), labelValues...)}
+		c.values[key] = value
+	}
+	value.value += v
+}
+
+// Value returns the current value of the counter identified by labelValues
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if value, exists := c.values[labelKey(labelValues)]; exists {
+		return value.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.writeHeader(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range sortedKeys(c.values) {
+		value := c.values[key]
+		writeSample(w, c.name, c.labels, value.labelValues, "", "", value.value)
+	}
+}
+
+// HistogramVec is a family of histograms partitioned by labels
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labelValues []string
+	counts      []uint64 // cumulative count per bucket upper bound
+	count       uint64
+	sum         float64
+}
+
+// NewHistogramVec registers a histogram family with the given bucket upper bounds and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*histogramValue),
+	}
+	r.register(h)
+	return h
+}
+
+// Observe records v in the histogram identified by labelValues
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.checkLabels(labelValues)
+	key := labelKey(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	value, exists := h.values[key]
+	if !exists {
+		value = &histogramValue{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.values[key] = value
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			value.counts[i]++
+		}
+	}
+	value.count++
+	value.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.writeHeader(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range sortedKeys(h.values) {
+		value := h.values[key]
+		for i, upper := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labels, value.labelValues, "le", formatFloat(upper), float64(value.counts[i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, value.labelValues, "le", "+Inf", float64(value.count))
+		writeSample(w, h.name+"_sum", h.labels, value.labelValues, "", "", value.sum)
+		writeSample(w, h.name+"_count", h.labels, value.labelValues, "", "", float64(value.count))
+	}
+}
+
+// Sample is one value reported by a callback metric
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// funcMetric reports samples computed by a callback at scrape time
+type funcMetric struct {
+	desc
+	collect func() []Sample
+}
+
+// NewGaugeFunc registers a gauge family whose samples are computed by collect on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func() []Sample) {
+	r.register(&funcMetric{desc: desc{name: name, help: help, typ: "gauge", labels: labels}, collect: collect})
+}
+
+// NewCounterFunc registers a counter family whose samples are read by collect on every scrape.
+// collect must report monotonically increasing values, e.g. from an existing atomic counter.
+func (r *Registry) NewCounterFunc(name, help string, labels []string, collect func() []Sample) {
+	r.register(&funcMetric{desc: desc{name: name, help: help, typ: "counter", labels: labels}, collect: collect})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	samples := f.collect()
+	f.writeHeader(w)
+	for _, sample := range samples {
+		f.checkLabels(sample.LabelValues)
+		writeSample(w, f.name, f.labels, sample.LabelValues, "", "", sample.Value)
+	}
+}
+
+// GaugeDesc names one gauge family reported by a gauge collector
+type GaugeDesc struct {
+	Name   string
+	Help   string
+	Labels []string
+}
+
+// collectorMetric reports several gauge families from a single callback
+type collectorMetric struct {
+	descs   []desc
+	collect func() [][]Sample
+}
+
+// NewGaugeCollector registers gauge families whose samples are computed together by one call to
+// collect per scrape, so families read from the same source describe the same snapshot.
+// collect returns the samples of each family in the order of families; nil reports none.
+func (r *Registry) NewGaugeCollector(families []GaugeDesc, collect func() [][]Sample) {
+	descs := make([]desc, len(families))
+	for i, family := range families {
+		descs[i] = desc{name: family.Name, help: family.Help, typ: "gauge", labels: family.Labels}
+	}
+	r.register(&collectorMetric{descs: descs, collect: collect})
+}
+
+func (c *collectorMetric) write(w *bufio.Writer) {
+	samples := c.collect()
+	for i, d := range c.descs {
+		d.writeHeader(w)
+		if i >= len(samples) {
+			continue
+		}
+		for _, sample := range samples[i] {
+			d.checkLabels(sample.LabelValues)
+			writeSample(w, d.name, d.labels, sample.LabelValues, "", "", sample.Value)
+		}
+	}
+}
+
+// writeSample writes one sample line, appending the extra label (e.g. le) when set
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, escapeLabelValue(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+// formatFloat renders v the way Prometheus expects, including +Inf, -Inf and NaN
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// sortedKeys returns the keys of m in order, so output is stable between scrapes
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// countingWriter counts bytes written, for WriteTo's return value
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("failed to write metrics: %v", err)
+	}
+	return buf.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Total requests.", "method", "status")
+
+	c.Inc("GET", "200")
+	c.Inc("GET", "200")
+	c.Add(3, "POST", "422")
+
+	if v := c.Value("GET", "200"); v != 2 {
+		t.Errorf("expected 2, got %v", v)
+	}
+
+	expected := `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{method="GET",status="200"} 2
+requests_total{method="POST",status="422"} 3
+`
+	if got := render(t, r); got != expected {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestCounterVecPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("requests_total", "Total requests.", "method")
+
+	for name, fn := range map[string]func(){
+		"wrong label count": func() { c.Inc("GET", "200") },
+		"negative add":      func() { c.Add(-1, "GET") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic")
+				}
+			}()
+			fn()
+		})
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("latency_seconds", "Request latency.", []float64{1, 0.1}, "route")
+
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(2, "/a")
+
+	expected := `# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 1
+latency_seconds_bucket{route="/a",le="1"} 2
+latency_seconds_bucket{route="/a",le="+Inf"} 3
+latency_seconds_sum{route="/a"} 2.55
+latency_seconds_count{route="/a"} 3
+`
+	if got := render(t, r); got != expected {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestFuncMetrics(t *testing.T) {
+	r := NewRegistry()
+	r.NewGaugeFunc("pool_connections", "Pool connections by state.", []string{"state"}, func() []Sample {
+		return []Sample{{LabelValues: []string{"idle"}, Value: 4}, {LabelValues: []string{"active"}, Value: 1}}
+	})
+	r.NewCounterFunc("rejections_total", "Rejected requests.", nil, func() []Sample {
+		return []Sample{{Value: 7}}
+	})
+
+	expected := `# HELP pool_connections Pool connections by state.
+# TYPE pool_connections gauge
+pool_connections{state="idle"} 4
+pool_connections{state="active"} 1
+# HELP rejections_total Rejected requests.
+# TYPE rejections_total counter
+rejections_total 7
+`
+	if got := render(t, r); got != expected {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestGaugeCollector(t *testing.T) {
+	r := NewRegistry()
+	calls := 0
+	r.NewGaugeCollector([]GaugeDesc{
+		{Name: "pool_connections", Help: "Pool connections by state.", Labels: []string{"state"}},
+		{Name: "pool_status", Help: "Pool status.", Labels: []string{"status"}},
+	}, func() [][]Sample {
+		calls++
+		return [][]Sample{
+			{{LabelValues: []string{"idle"}, Value: 4}},
+			{{LabelValues: []string{"healthy"}, Value: 1}},
+		}
+	})
+
+	expected := `# HELP pool_connections Pool connections by state.
+# TYPE pool_connections gauge
+pool_connections{state="idle"} 4
+# HELP pool_status Pool status.
+# TYPE pool_status gauge
+pool_status{status="healthy"} 1
+`
+	if got := render(t, r); got != expected {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", got, expected)
+	}
+	if calls != 1 {
+		t.Errorf("expected one collect call per scrape, got %d", calls)
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("escaped_total", "Help with \\ and\nnewline.", "value")
+	c.Inc("quote \" backslash \\ newline \n")
+
+	out := render(t, r)
+	if !strings.Contains(out, `# HELP escaped_total Help with \\ and\nnewline.`) {
+		t.Errorf("help not escaped:\n%s", out)
+	}
+	if !strings.Contains(out, `escaped_total{value="quote \" backslash \\ newline \n"} 1`) {
+		t.Errorf("label value not escaped:\n%s", out)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("hits_total", "Hits.").Inc()
+
+	rr := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rr.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("expected content type %q, got %q", ContentType, ct)
+	}
+	if !strings.Contains(rr.Body.String(), "hits_total 1\n") {
+		t.Errorf("unexpected body:\n%s", rr.Body.String())
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("hits_total", "Hits.", "route")
+	h := r.NewHistogramVec("latency_seconds", "Latency.", DefBuckets, "route")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Inc("/a")
+			h.Observe(0.01, "/a")
+			render(t, r)
+		}()
+	}
+	wg.Wait()
+
+	if v := c.Value("/a"); v != 50 {
+		t.Errorf("expected 50, got %v", v)
+	}
+}
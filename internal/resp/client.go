@@ -0,0 +1,226 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by Do after Close
+var ErrClosed = errors.New("resp: client closed")
+
+// Options configures a Client
+type Options struct {
+	// Addr is the server's host:port
+	Addr string
+	// Password, if set, is sent with AUTH on every new connection
+	Password string
+	// DB, if not zero, is selected with SELECT on every new connection
+	DB int
+	// PoolSize is the number of idle connections kept open (default 10)
+	PoolSize int
+	// Timeout bounds dialling and each command when the context has no earlier deadline (default 1s)
+	Timeout time.Duration
+}
+
+// Client sends commands over a pool of connections. It is safe for concurrent use.
+type Client struct {
+	opts Options
+	idle chan *conn
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// conn is one connection with its buffered reader and writer
+type conn struct {
+	netConn net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+}
+
+// NewClient creates a client; connections are opened lazily by Do
+func NewClient(opts Options) *Client {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 10
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 1 * time.Second
+	}
+	return &Client{opts: opts, idle: make(chan *conn, opts.PoolSize)}
+}
+
+// Do sends one command and returns its reply. Error replies are returned as an Error.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.roundTrip(ctx, c.opts.Timeout, args)
+	if err != nil {
+		// The stream may be out of sync; never reuse the connection
+		cn.netConn.Close()
+		return nil, err
+	}
+	c.put(cn)
+
+	if replyErr, ok := reply.(Error); ok {
+		return nil, replyErr
+	}
+	return reply, nil
+}
+
+// Ping checks that the server answers
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.Do(ctx, "PING")
+	return err
+}
+
+// Close closes idle connections; connections in use are closed when returned
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.idle)
+	for cn := range c.idle {
+		cn.netConn.Close()
+	}
+	return nil
+}
+
+// get takes an idle connection or dials a new one
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	select {
+	case cn, ok := <-c.idle:
+		if ok {
+			return cn, nil
+		}
+		return nil, ErrClosed
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: c.opts.Timeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", c.opts.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("resp: failed to connect to %s: %w", c.opts.Addr, err)
+	}
+	cn := &conn{netConn: netConn, r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)}
+
+	if err := cn.setup(ctx, c.opts); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return cn, nil
+}
+
+// put returns a healthy connection to the pool, closing it when the pool is full or closed
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		cn.netConn.Close()
+		return
+	}
+	select {
+	case c.idle <- cn:
+	default:
+		cn.netConn.Close()
+	}
+}
+
+// setup authenticates and selects the database on a new connection
+func (cn *conn) setup(ctx context.Context, opts Options) error {
+	if opts.Password != "" {
+		if err := cn.expectOK(ctx, opts.Timeout, "AUTH", opts.Password); err != nil {
+			return fmt.Errorf("resp: AUTH failed: %w", err)
+		}
+	}
+	if opts.DB != 0 {
+		if err := cn.expectOK(ctx, opts.Timeout, "SELECT", strconv.Itoa(opts.DB)); err != nil {
+			return fmt.Errorf("resp: SELECT failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// expectOK sends a command whose reply must not be an error
+func (cn *conn) expectOK(ctx context.Context, timeout time.Duration, args ...string) error {
+	reply, err := cn.roundTrip(ctx, timeout, args)
+	if err != nil {
+		return err
+	}
+	if replyErr, ok := reply.(Error); ok {
+		return replyErr
+	}
+	return nil
+}
+
+// roundTrip writes a command and reads its reply within the context deadline or timeout
+func (cn *conn) roundTrip(ctx context.Context, timeout time.Duration, args []string) (any, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := cn.netConn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if err := WriteCommand(cn.w, args...); err != nil {
+		return nil, err
+	}
+	return ReadValue(cn.r)
+}
+
+// Script is a Lua script run with EVALSHA, falling back to EVAL the first time a server
+// has not seen it
+type Script struct {
+	src  string
+	hash string
+}
+
+// NewScript wraps Lua source
+func NewScript(src string) *Script {
+	sum := sha1.Sum([]byte(src))
+	return &Script{src: src, hash: hex.EncodeToString(sum[:])}
+}
+
+// Hash returns the script's SHA-1, the name EVALSHA knows it by
+func (s *Script) Hash() string {
+	return s.hash
+}
+
+// Run executes the script atomically on the server with the given keys and arguments
+func (s *Script) Run(ctx context.Context, c *Client, keys []string, args ...string) (any, error) {
+	command := make([]string, 0, 3+len(keys)+len(args))
+	command = append(command, "EVALSHA", s.hash, strconv.Itoa(len(keys)))
+	command = append(command, keys...)
+	command = append(command, args...)
+
+	reply, err := c.Do(ctx, command...)
+	var replyErr Error
+	if errors.As(err, &replyErr) && replyErr.Prefix() == "NOSCRIPT" {
+		command[0], command[1] = "EVAL", s.src
+		return c.Do(ctx, command...)
+	}
+	return reply, err
+}
@@ -0,0 +1,152 @@
+// Package resp implements a minimal client for the Redis serialization protocol (RESP2).
+// It covers what the API needs from a shared store: commands sent as arrays of bulk strings,
+// replies decoded into Go values, and a small connection pool. Servers speaking the protocol
+// include Redis, Valkey and KeyDB; package resptest provides an in-process stand-in for tests.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Error is an error reply sent by the server, such as "NOSCRIPT No matching script"
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Prefix returns the error code, the first word of the reply ("NOSCRIPT", "ERR", ...)
+func (e Error) Prefix() string {
+	for i, c := range e {
+		if c == ' ' {
+			return string(e[:i])
+		}
+	}
+	return string(e)
+}
+
+// ErrProtocol is returned when a reply cannot be decoded
+var ErrProtocol = errors.New("resp: protocol error")
+
+// WriteCommand encodes args as a RESP array of bulk strings
+func WriteCommand(w *bufio.Writer, args ...string) error {
+	w.WriteByte('*')
+	w.WriteString(strconv.Itoa(len(args)))
+	w.WriteString("\r\n")
+	for _, arg := range args {
+		w.WriteByte('$')
+		w.WriteString(strconv.Itoa(len(arg)))
+		w.WriteString("\r\n")
+		w.WriteString(arg)
+		w.WriteString("\r\n")
+	}
+	return w.Flush()
+}
+
+// WriteValue encodes a reply: string as a bulk string, int64 or int as an integer, Error as an
+// error reply, []any as an array, nil as a null bulk string and SimpleString as a status reply.
+// It does not flush w.
+func WriteValue(w *bufio.Writer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case SimpleString:
+		w.WriteString("+" + string(v) + "\r\n")
+	case Error:
+		w.WriteString("-" + string(v) + "\r\n")
+	case int:
+		w.WriteString(":" + strconv.Itoa(v) + "\r\n")
+	case int64:
+		w.WriteString(":" + strconv.FormatInt(v, 10) + "\r\n")
+	case string:
+		w.WriteString("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case []any:
+		w.WriteString("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, item := range v {
+			if err := WriteValue(w, item); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("resp: cannot encode %T", value)
+	}
+	return nil
+}
+
+// SimpleString is a status reply such as "OK" or "PONG"
+type SimpleString string
+
+// ReadValue decodes one reply. Status replies are returned as SimpleString, error replies as
+// Error values (not as the error result), integers as int64, bulk strings as string, null bulk
+// strings and arrays as nil, and arrays as []any.
+func ReadValue(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, ErrProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return SimpleString(line[1:]), nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		return n, nil
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < -1 {
+			return nil, ErrProtocol
+		}
+		if size == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[size] != '\r' || buf[size+1] != '\n' {
+			return nil, ErrProtocol
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < -1 {
+			return nil, ErrProtocol
+		}
+		if count == -1 {
+			return nil, nil
+		}
+		items := make([]any, count)
+		for i := range items {
+			if items[i], err = ReadValue(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, ErrProtocol
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", ErrProtocol
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,129 @@
+package resp_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"fizzbuzz/internal/resp"
+	"fizzbuzz/internal/resp/resptest"
+)
+
+func TestReadWriteValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{"status", resp.SimpleString("OK"), resp.SimpleString("OK")},
+		{"error", resp.Error("ERR boom"), resp.Error("ERR boom")},
+		{"integer", int64(-42), int64(-42)},
+		{"int", 7, int64(7)},
+		{"bulk string", "hello\r\nworld", "hello\r\nworld"},
+		{"null", nil, nil},
+		{"array", []any{int64(1), "two", []any{"three"}}, []any{int64(1), "two", []any{"three"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			if err := resp.WriteValue(w, tt.value); err != nil {
+				t.Fatal(err)
+			}
+			w.Flush()
+
+			got, err := resp.ReadValue(bufio.NewReader(&buf))
+			if err != nil {
+				t.Fatalf("failed to read %q: %v", buf.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+
+	for _, malformed := range []string{"", "?1\r\n", ":abc\r\n", "$5\r\nab\r\n", "+OK\n"} {
+		if _, err := resp.ReadValue(bufio.NewReader(bytes.NewBufferString(malformed))); err == nil {
+			t.Errorf("expected an error reading %q", malformed)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	server := resptest.NewServer()
+	defer server.Close()
+
+	client := resp.NewClient(resp.Options{Addr: server.Addr, Password: "secret", DB: 2, PoolSize: 1})
+	defer client.Close()
+	ctx := context.Background()
+
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+
+	_, err := client.Do(ctx, "NOPE")
+	var replyErr resp.Error
+	if !errors.As(err, &replyErr) || replyErr.Prefix() != "ERR" {
+		t.Errorf("expected an ERR reply, got %v", err)
+	}
+
+	// The connection survives an error reply and is set up only once
+	if err := client.Ping(ctx); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+	want := []string{"AUTH", "SELECT", "PING", "NOPE", "PING"}
+	if got := server.Commands(); !slices.Equal(got, want) {
+		t.Errorf("expected commands %v, got %v", want, got)
+	}
+
+	client.Close()
+	if err := client.Ping(ctx); !errors.Is(err, resp.ErrClosed) {
+		t.Errorf("expected ErrClosed after Close, got %v", err)
+	}
+}
+
+func TestClientUnreachable(t *testing.T) {
+	server := resptest.NewServer()
+	addr := server.Addr
+	server.Close()
+
+	client := resp.NewClient(resp.Options{Addr: addr, Timeout: 100 * time.Millisecond})
+	defer client.Close()
+
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("expected an error from a closed server")
+	}
+}
+
+func TestScriptRun(t *testing.T) {
+	const src = "return redis.call('HGET', KEYS[1], ARGV[1])"
+
+	server := resptest.NewServer()
+	defer server.Close()
+	server.RegisterScript(src, func(data map[string]map[string]string, now time.Time, keys, args []string) any {
+		return keys[0] + ":" + args[0]
+	})
+
+	client := resp.NewClient(resp.Options{Addr: server.Addr})
+	defer client.Close()
+	script := resp.NewScript(src)
+
+	for i := 0; i < 2; i++ {
+		reply, err := script.Run(context.Background(), client, []string{"bucket"}, "tokens")
+		if err != nil || reply != "bucket:tokens" {
+			t.Fatalf("run %d: unexpected reply %v, %v", i, reply, err)
+		}
+	}
+
+	// The first run falls back to EVAL, after which the server knows the script by hash
+	want := []string{"EVALSHA", "EVAL", "EVALSHA"}
+	if got := server.Commands(); !slices.Equal(got, want) {
+		t.Errorf("expected commands %v, got %v", want, got)
+	}
+}
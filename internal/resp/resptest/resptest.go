@@ -0,0 +1,267 @@
+// Package resptest provides an in-process server speaking the Redis protocol, for tests of code
+// built on package resp. It keeps hashes in memory and runs scripts registered as Go functions
+// in place of Lua, each under the server lock so they are atomic like their Redis counterparts.
+package resptest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fizzbuzz/internal/resp"
+)
+
+// ScriptFunc stands in for a Lua script. It reads and writes hashes through data, where a
+// missing key is a missing hash, and returns the reply to send.
+type ScriptFunc func(data map[string]map[string]string, now time.Time, keys, args []string) any
+
+// Server is a RESP server listening on a local port
+type Server struct {
+	// Addr is the host:port the server listens on
+	Addr string
+
+	listener net.Listener
+	wg       sync.WaitGroup
+
+	mu       sync.Mutex
+	now      func() time.Time
+	conns    map[net.Conn]struct{}
+	data     map[string]map[string]string
+	scripts  map[string]ScriptFunc // Registered implementations by script SHA-1
+	loaded   map[string]bool       // Scripts the server has seen, by SHA-1
+	commands []string
+	down     bool
+}
+
+// NewServer starts a server on a random local port; call Close when done
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("resptest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+		now:      time.Now,
+		conns:    make(map[net.Conn]struct{}),
+		data:     make(map[string]map[string]string),
+		scripts:  make(map[string]ScriptFunc),
+		loaded:   make(map[string]bool),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// RegisterScript makes fn the implementation of the Lua script src
+func (s *Server) RegisterScript(src string, fn ScriptFunc) {
+	sum := sha1.Sum([]byte(src))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scripts[hex.EncodeToString(sum[:])] = fn
+}
+
+// SetClock replaces the server's clock, used by TIME and passed to scripts
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetDown makes the server answer every command with an error, as a failing store would
+func (s *Server) SetDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// Commands returns the names of the commands received so far, in order
+func (s *Server) Commands() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+// Hash returns a copy of the hash stored at key, or nil
+func (s *Server) Hash(key string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.data[key] == nil {
+		return nil
+	}
+	hash := make(map[string]string, len(s.data[key]))
+	for field, value := range s.data[key] {
+		hash[field] = value
+	}
+	return hash
+}
+
+// Close stops the server, closes its connections and waits for them to finish
+func (s *Server) Close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	for netConn := range s.conns {
+		netConn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[netConn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(netConn)
+	}
+}
+
+// serveConn answers commands on one connection until it is closed
+func (s *Server) serveConn(netConn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, netConn)
+		s.mu.Unlock()
+		netConn.Close()
+	}()
+
+	r := bufio.NewReader(netConn)
+	w := bufio.NewWriter(netConn)
+
+	for {
+		value, err := resp.ReadValue(r)
+		if err != nil {
+			return
+		}
+
+		var reply any
+		items, ok := value.([]any)
+		if !ok || len(items) == 0 {
+			reply = resp.Error("ERR expected a command array")
+		} else {
+			args := make([]string, len(items))
+			for i, item := range items {
+				args[i], _ = item.(string)
+			}
+			reply = s.execute(args)
+		}
+
+		if err := resp.WriteValue(w, reply); err != nil {
+			return
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// execute runs one command under the server lock
+func (s *Server) execute(args []string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := strings.ToUpper(args[0])
+	s.commands = append(s.commands, name)
+	if s.down {
+		return resp.Error("ERR server is down")
+	}
+
+	switch name {
+	case "PING":
+		return resp.SimpleString("PONG")
+	case "AUTH", "SELECT":
+		return resp.SimpleString("OK")
+	case "TIME":
+		now := s.now()
+		return []any{strconv.FormatInt(now.Unix(), 10), strconv.Itoa(now.Nanosecond() / 1000)}
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, exists := s.data[key]; exists {
+				delete(s.data, key)
+				deleted++
+			}
+		}
+		return deleted
+	case "HGETALL":
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		var reply []any
+		for field, value := range s.data[args[1]] {
+			reply = append(reply, field, value)
+		}
+		if reply == nil {
+			reply = []any{}
+		}
+		return reply
+	case "SCRIPT":
+		if len(args) != 3 || !strings.EqualFold(args[1], "LOAD") {
+			return resp.Error("ERR only SCRIPT LOAD is supported")
+		}
+		sum := sha1.Sum([]byte(args[2]))
+		hash := hex.EncodeToString(sum[:])
+		s.loaded[hash] = true
+		return hash
+	case "EVAL":
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+		sum := sha1.Sum([]byte(args[1]))
+		hash := hex.EncodeToString(sum[:])
+		s.loaded[hash] = true
+		return s.runScript(hash, args[2:])
+	case "EVALSHA":
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+		hash := strings.ToLower(args[1])
+		if !s.loaded[hash] {
+			return resp.Error("NOSCRIPT No matching script. Please use EVAL.")
+		}
+		return s.runScript(hash, args[2:])
+	default:
+		return resp.Error("ERR unknown command '" + args[0] + "'")
+	}
+}
+
+// runScript calls a registered script with "numkeys key... arg..."
+func (s *Server) runScript(hash string, args []string) any {
+	fn, exists := s.scripts[hash]
+	if !exists {
+		return resp.Error("ERR script not registered with resptest")
+	}
+
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys < 0 || numKeys > len(args)-1 {
+		return resp.Error("ERR Number of keys can't be greater than number of args")
+	}
+	keys, scriptArgs := args[1:1+numKeys], args[1+numKeys:]
+
+	return fn(s.data, s.now(), keys, scriptArgs)
+}
+
+func wrongArgs(command string) resp.Error {
+	return resp.Error("ERR wrong number of arguments for '" + strings.ToLower(command) + "' command")
+}
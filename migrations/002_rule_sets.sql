@@ -0,0 +1,107 @@
+-- FizzBuzz Rule Sets
+-- Version: 1.1
+-- Description: Persist arbitrary ordered divisor/word rule sets alongside legacy parameters
+
+-- Rule-based requests store their full ordered rule list; NULL for legacy two-rule requests
+ALTER TABLE fizzbuzz_statistics ADD COLUMN rules JSONB;
+
+-- Atomic increment function accepting an optional rule set
+CREATE OR REPLACE FUNCTION increment_statistics(
+    p_hash VARCHAR(64),
+    p_int1 INTEGER,
+    p_int2 INTEGER,
+    p_limit INTEGER,
+    p_str1 VARCHAR(255),
+    p_str2 VARCHAR(255),
+    p_rules JSONB
+) RETURNS BIGINT AS $$
+DECLARE
+    current_hits BIGINT;
+BEGIN
+    -- Atomic upsert: insert new or increment existing
+    INSERT INTO fizzbuzz_statistics
+    (parameters_hash, int1, int2, limit_value, str1, str2, rules, hits)
+    VALUES (p_hash, p_int1, p_int2, p_limit, p_str1, p_str2, p_rules, 1)
+    ON CONFLICT (parameters_hash)
+    DO UPDATE SET
+        hits = fizzbuzz_statistics.hits + 1,
+        updated_at = NOW()
+    RETURNING hits INTO current_hits;
+
+    RETURN current_hits;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Return types change, so the read functions must be dropped and recreated
+DROP FUNCTION IF EXISTS get_most_frequent_request();
+DROP FUNCTION IF EXISTS get_top_requests(INTEGER);
+
+CREATE OR REPLACE FUNCTION get_most_frequent_request()
+RETURNS TABLE(
+    parameters_hash VARCHAR(64),
+    int1 INTEGER,
+    int2 INTEGER,
+    limit_value INTEGER,
+    str1 VARCHAR(255),
+    str2 VARCHAR(255),
+    hits BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    rules JSONB
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        s.parameters_hash,
+        s.int1,
+        s.int2,
+        s.limit_value,
+        s.str1,
+        s.str2,
+        s.hits,
+        s.created_at,
+        s.updated_at,
+        s.rules
+    FROM fizzbuzz_statistics s
+    ORDER BY s.hits DESC, s.created_at ASC
+    LIMIT 1;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION get_top_requests(n INTEGER)
+RETURNS TABLE(
+    parameters_hash VARCHAR(64),
+    int1 INTEGER,
+    int2 INTEGER,
+    limit_value INTEGER,
+    str1 VARCHAR(255),
+    str2 VARCHAR(255),
+    hits BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    rules JSONB
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        s.parameters_hash,
+        s.int1,
+        s.int2,
+        s.limit_value,
+        s.str1,
+        s.str2,
+        s.hits,
+        s.created_at,
+        s.updated_at,
+        s.rules
+    FROM fizzbuzz_statistics s
+    ORDER BY s.hits DESC, s.created_at ASC
+    LIMIT n;
+END;
+$$ LANGUAGE plpgsql;
+
+GRANT EXECUTE ON FUNCTION increment_statistics(VARCHAR(64), INTEGER, INTEGER, INTEGER, VARCHAR(255), VARCHAR(255), JSONB) TO fizzbuzz_user;
+GRANT EXECUTE ON FUNCTION get_most_frequent_request() TO fizzbuzz_user;
+GRANT EXECUTE ON FUNCTION get_top_requests(INTEGER) TO fizzbuzz_user;
+
+SELECT 'FizzBuzz rule set migration applied successfully' AS status;
@@ -0,0 +1,98 @@
+-- FizzBuzz Statistics History
+-- Version: 1.2
+-- Description: Hourly hit buckets so popularity can be queried over arbitrary time windows
+
+-- One row per parameter combination per hour in which it was requested
+CREATE TABLE fizzbuzz_statistics_history (
+    parameters_hash VARCHAR(64) NOT NULL REFERENCES fizzbuzz_statistics (parameters_hash) ON DELETE CASCADE,
+    bucket_start TIMESTAMP WITH TIME ZONE NOT NULL, -- Start of the hour, truncated in UTC
+    hits BIGINT NOT NULL DEFAULT 1,
+    PRIMARY KEY (parameters_hash, bucket_start)
+);
+
+-- Window queries scan by time first, then aggregate per parameter combination
+CREATE INDEX idx_statistics_history_bucket ON fizzbuzz_statistics_history (bucket_start);
+
+-- Atomic increment function now also bumps the current hourly bucket
+CREATE OR REPLACE FUNCTION increment_statistics(
+    p_hash VARCHAR(64),
+    p_int1 INTEGER,
+    p_int2 INTEGER,
+    p_limit INTEGER,
+    p_str1 VARCHAR(255),
+    p_str2 VARCHAR(255),
+    p_rules JSONB
+) RETURNS BIGINT AS $$
+DECLARE
+    current_hits BIGINT;
+BEGIN
+    -- Atomic upsert: insert new or increment existing
+    INSERT INTO fizzbuzz_statistics
+    (parameters_hash, int1, int2, limit_value, str1, str2, rules, hits)
+    VALUES (p_hash, p_int1, p_int2, p_limit, p_str1, p_str2, p_rules, 1)
+    ON CONFLICT (parameters_hash)
+    DO UPDATE SET
+        hits = fizzbuzz_statistics.hits + 1,
+        updated_at = NOW()
+    RETURNING hits INTO current_hits;
+
+    -- Record the hit in its hourly bucket
+    INSERT INTO fizzbuzz_statistics_history (parameters_hash, bucket_start, hits)
+    VALUES (p_hash, date_trunc('hour', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', 1)
+    ON CONFLICT (parameters_hash, bucket_start)
+    DO UPDATE SET hits = fizzbuzz_statistics_history.hits + 1;
+
+    RETURN current_hits;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Function to get the most frequent request within [p_from, p_to).
+-- Buckets are hourly, so p_from is rounded down to the start of its hour.
+-- hits is the number of requests inside the window, not the cumulative count.
+CREATE OR REPLACE FUNCTION get_most_frequent_request_in_window(
+    p_from TIMESTAMP WITH TIME ZONE,
+    p_to TIMESTAMP WITH TIME ZONE
+)
+RETURNS TABLE(
+    parameters_hash VARCHAR(64),
+    int1 INTEGER,
+    int2 INTEGER,
+    limit_value INTEGER,
+    str1 VARCHAR(255),
+    str2 VARCHAR(255),
+    hits BIGINT,
+    created_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE,
+    rules JSONB
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        s.parameters_hash,
+        s.int1,
+        s.int2,
+        s.limit_value,
+        s.str1,
+        s.str2,
+        w.hits,
+        s.created_at,
+        s.updated_at,
+        s.rules
+    FROM (
+        SELECT h.parameters_hash, SUM(h.hits)::BIGINT AS hits
+        FROM fizzbuzz_statistics_history h
+        WHERE h.bucket_start >= date_trunc('hour', p_from AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'
+          AND h.bucket_start < p_to
+        GROUP BY h.parameters_hash
+    ) w
+    JOIN fizzbuzz_statistics s ON s.parameters_hash = w.parameters_hash
+    ORDER BY w.hits DESC, s.created_at ASC
+    LIMIT 1;
+END;
+$$ LANGUAGE plpgsql;
+
+GRANT SELECT, INSERT, UPDATE ON fizzbuzz_statistics_history TO fizzbuzz_user;
+GRANT EXECUTE ON FUNCTION increment_statistics(VARCHAR(64), INTEGER, INTEGER, INTEGER, VARCHAR(255), VARCHAR(255), JSONB) TO fizzbuzz_user;
+GRANT EXECUTE ON FUNCTION get_most_frequent_request_in_window(TIMESTAMP WITH TIME ZONE, TIMESTAMP WITH TIME ZONE) TO fizzbuzz_user;
+
+SELECT 'FizzBuzz statistics history migration applied successfully' AS status;
@@ -0,0 +1,40 @@
+-- FizzBuzz Statistics Batch Writes
+-- Version: 1.3
+-- Description: Multi-row upsert used by the background statistics writer
+
+-- Applies pre-aggregated hit counts for many parameter combinations in one round trip.
+-- Arrays are parallel: element i of every array describes the same parameter combination,
+-- and each hash must appear at most once per call.
+CREATE OR REPLACE FUNCTION increment_statistics_batch(
+    p_hashes VARCHAR(64)[],
+    p_int1s INTEGER[],
+    p_int2s INTEGER[],
+    p_limits INTEGER[],
+    p_str1s VARCHAR(255)[],
+    p_str2s VARCHAR(255)[],
+    p_rules TEXT[],
+    p_hits BIGINT[]
+) RETURNS VOID AS $$
+BEGIN
+    INSERT INTO fizzbuzz_statistics
+    (parameters_hash, int1, int2, limit_value, str1, str2, rules, hits)
+    SELECT b.hash, b.int1, b.int2, b.limit_value, b.str1, b.str2, b.rules::JSONB, b.hits
+    FROM unnest(p_hashes, p_int1s, p_int2s, p_limits, p_str1s, p_str2s, p_rules, p_hits)
+        AS b(hash, int1, int2, limit_value, str1, str2, rules, hits)
+    ON CONFLICT (parameters_hash)
+    DO UPDATE SET
+        hits = fizzbuzz_statistics.hits + EXCLUDED.hits,
+        updated_at = NOW();
+
+    -- Record the hits in the current hourly bucket
+    INSERT INTO fizzbuzz_statistics_history (parameters_hash, bucket_start, hits)
+    SELECT b.hash, date_trunc('hour', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', b.hits
+    FROM unnest(p_hashes, p_hits) AS b(hash, hits)
+    ON CONFLICT (parameters_hash, bucket_start)
+    DO UPDATE SET hits = fizzbuzz_statistics_history.hits + EXCLUDED.hits;
+END;
+$$ LANGUAGE plpgsql;
+
+GRANT EXECUTE ON FUNCTION increment_statistics_batch(VARCHAR(64)[], INTEGER[], INTEGER[], INTEGER[], VARCHAR(255)[], VARCHAR(255)[], TEXT[], BIGINT[]) TO fizzbuzz_user;
+
+SELECT 'FizzBuzz statistics batch migration applied successfully' AS status;
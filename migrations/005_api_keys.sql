@@ -0,0 +1,22 @@
+-- FizzBuzz API Keys
+-- Version: 1.4
+-- Description: Hashed API keys identifying the clients allowed to call the API
+
+-- Keys are stored as their SHA-256 only; the plaintext is shown once when a key is issued.
+-- A client may hold several keys at a time so keys can be rotated without downtime.
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    client_id VARCHAR(64) NOT NULL,   -- Identity attached to requests made with the key
+    name VARCHAR(255) NOT NULL DEFAULT '',
+    key_prefix VARCHAR(16) NOT NULL,  -- First characters of the key, to recognise it in listings
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_api_keys_client_id ON api_keys (client_id);
+
+GRANT SELECT, INSERT, UPDATE ON api_keys TO fizzbuzz_user;
+GRANT USAGE, SELECT ON SEQUENCE api_keys_id_seq TO fizzbuzz_user;
+
+SELECT 'FizzBuzz API keys migration applied successfully' AS status;
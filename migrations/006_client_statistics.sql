@@ -0,0 +1,96 @@
+-- FizzBuzz Per-Client Statistics
+-- Version: 1.5
+-- Description: Hit counts per client (API key client ID or client IP) and parameter combination
+
+-- One row per client per parameter combination it requested
+CREATE TABLE fizzbuzz_client_statistics (
+    client VARCHAR(255) NOT NULL,
+    parameters_hash VARCHAR(64) NOT NULL REFERENCES fizzbuzz_statistics (parameters_hash) ON DELETE CASCADE,
+    hits BIGINT NOT NULL DEFAULT 1,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (client, parameters_hash)
+);
+
+-- "Top requests of a client" and "top clients of a request" both order by hits
+CREATE INDEX idx_client_statistics_client_hits ON fizzbuzz_client_statistics (client, hits DESC);
+CREATE INDEX idx_client_statistics_hash_hits ON fizzbuzz_client_statistics (parameters_hash, hits DESC);
+
+-- Records one hit like increment_statistics and attributes it to p_client
+CREATE OR REPLACE FUNCTION increment_client_statistics(
+    p_client VARCHAR(255),
+    p_hash VARCHAR(64),
+    p_int1 INTEGER,
+    p_int2 INTEGER,
+    p_limit INTEGER,
+    p_str1 VARCHAR(255),
+    p_str2 VARCHAR(255),
+    p_rules JSONB
+) RETURNS BIGINT AS $$
+DECLARE
+    current_hits BIGINT;
+BEGIN
+    current_hits := increment_statistics(p_hash, p_int1, p_int2, p_limit, p_str1, p_str2, p_rules);
+
+    INSERT INTO fizzbuzz_client_statistics (client, parameters_hash, hits)
+    VALUES (p_client, p_hash, 1)
+    ON CONFLICT (client, parameters_hash)
+    DO UPDATE SET
+        hits = fizzbuzz_client_statistics.hits + 1,
+        updated_at = NOW();
+
+    RETURN current_hits;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The batch upsert gains a parallel array of per-client hit counts, each a JSON object
+-- mapping client to hits (NULL when no hit of that combination is attributed)
+DROP FUNCTION IF EXISTS increment_statistics_batch(VARCHAR(64)[], INTEGER[], INTEGER[], INTEGER[], VARCHAR(255)[], VARCHAR(255)[], TEXT[], BIGINT[]);
+
+CREATE OR REPLACE FUNCTION increment_statistics_batch(
+    p_hashes VARCHAR(64)[],
+    p_int1s INTEGER[],
+    p_int2s INTEGER[],
+    p_limits INTEGER[],
+    p_str1s VARCHAR(255)[],
+    p_str2s VARCHAR(255)[],
+    p_rules TEXT[],
+    p_hits BIGINT[],
+    p_clients TEXT[]
+) RETURNS VOID AS $$
+BEGIN
+    INSERT INTO fizzbuzz_statistics
+    (parameters_hash, int1, int2, limit_value, str1, str2, rules, hits)
+    SELECT b.hash, b.int1, b.int2, b.limit_value, b.str1, b.str2, b.rules::JSONB, b.hits
+    FROM unnest(p_hashes, p_int1s, p_int2s, p_limits, p_str1s, p_str2s, p_rules, p_hits)
+        AS b(hash, int1, int2, limit_value, str1, str2, rules, hits)
+    ON CONFLICT (parameters_hash)
+    DO UPDATE SET
+        hits = fizzbuzz_statistics.hits + EXCLUDED.hits,
+        updated_at = NOW();
+
+    -- Record the hits in the current hourly bucket
+    INSERT INTO fizzbuzz_statistics_history (parameters_hash, bucket_start, hits)
+    SELECT b.hash, date_trunc('hour', NOW() AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', b.hits
+    FROM unnest(p_hashes, p_hits) AS b(hash, hits)
+    ON CONFLICT (parameters_hash, bucket_start)
+    DO UPDATE SET hits = fizzbuzz_statistics_history.hits + EXCLUDED.hits;
+
+    -- Attribute hits to their clients
+    INSERT INTO fizzbuzz_client_statistics (client, parameters_hash, hits)
+    SELECT c.key, b.hash, c.value::BIGINT
+    FROM unnest(p_hashes, p_clients) AS b(hash, clients)
+    CROSS JOIN LATERAL jsonb_each_text(b.clients::JSONB) AS c
+    WHERE b.clients IS NOT NULL
+    ON CONFLICT (client, parameters_hash)
+    DO UPDATE SET
+        hits = fizzbuzz_client_statistics.hits + EXCLUDED.hits,
+        updated_at = NOW();
+END;
+$$ LANGUAGE plpgsql;
+
+GRANT SELECT, INSERT, UPDATE ON fizzbuzz_client_statistics TO fizzbuzz_user;
+GRANT EXECUTE ON FUNCTION increment_client_statistics(VARCHAR(255), VARCHAR(64), INTEGER, INTEGER, INTEGER, VARCHAR(255), VARCHAR(255), JSONB) TO fizzbuzz_user;
+GRANT EXECUTE ON FUNCTION increment_statistics_batch(VARCHAR(64)[], INTEGER[], INTEGER[], INTEGER[], VARCHAR(255)[], VARCHAR(255)[], TEXT[], BIGINT[], TEXT[]) TO fizzbuzz_user;
+
+SELECT 'FizzBuzz per-client statistics migration applied successfully' AS status;